@@ -30,13 +30,26 @@ import (
 	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
 )
 
+// AccountDistributedDetailsProvider is the interface for accounts that can provide their distributed details,
+// returning an error rather than an unusable value if the account is malformed.
+type AccountDistributedDetailsProvider interface {
+	// ObtainCompositePublicKey provides the composite public key for the account.
+	ObtainCompositePublicKey() (e2types.PublicKey, error)
+	// ObtainVerificationVector provides the verification vector for the account.
+	ObtainVerificationVector() ([]e2types.PublicKey, error)
+	// ObtainParticipants provides the endpoints of the participants in the account.
+	ObtainParticipants() (map[uint64]string, error)
+}
+
 // account contains the details of the account.
 type account struct {
 	id                 uuid.UUID
 	name               string
 	verificationVector []e2types.PublicKey
+	publicShares       bool
 	signingThreshold   uint32
-	participants       map[uint64]string
+	participants       map[uint64]*Participant
+	watchOnly          bool
 	crypto             map[string]any
 	unlocked           bool
 	secretKey          e2types.PrivateKey
@@ -44,6 +57,8 @@ type account struct {
 	version            uint
 	wallet             *wallet
 	encryptor          e2wtypes.Encryptor
+	keyWrapper         KeyWrapper
+	metadata           map[string]string
 	mutex              sync.RWMutex
 }
 
@@ -67,20 +82,36 @@ func (a *account) MarshalJSON() ([]byte, error) {
 	data["uuid"] = a.id.String()
 	data["name"] = a.name
 	data["pubkey"] = fmt.Sprintf("%x", a.publicKey.Marshal())
-	verificationKeys := make([]string, len(a.verificationVector))
-	for i := range a.verificationVector {
-		verificationKeys[i] = fmt.Sprintf("%x", a.verificationVector[i].Marshal())
+	if !a.publicShares {
+		verificationKeys := make([]string, len(a.verificationVector))
+		for i := range a.verificationVector {
+			verificationKeys[i] = fmt.Sprintf("%x", a.verificationVector[i].Marshal())
+		}
+		data["verificationvector"] = verificationKeys
 	}
-	data["verificationvector"] = verificationKeys
 	data["signing_threshold"] = a.signingThreshold
-	participants := make(map[string]string, len(a.participants))
-	for k, v := range a.participants {
-		participants[fmt.Sprintf("%d", k)] = v
+	data["participants"] = participantsJSON(a.participants)
+	if len(a.metadata) > 0 {
+		data["metadata"] = a.metadata
+	}
+	if a.watchOnly {
+		// Watch-only accounts have no secret key, so nothing to encrypt.
+		data["watch_only"] = true
+
+		res, err := json.Marshal(data)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal account")
+		}
+
+		return res, nil
 	}
-	data["participants"] = participants
 	data["crypto"] = a.crypto
-	data["encryptor"] = a.encryptor.Name()
-	data["version"] = a.version
+	if a.keyWrapper != nil {
+		data["key_wrapper"] = a.keyWrapper.Name()
+	} else {
+		data["encryptor"] = a.encryptor.Name()
+		data["version"] = a.version
+	}
 
 	res, err := json.Marshal(data)
 	if err != nil {
@@ -158,25 +189,31 @@ func (a *account) UnmarshalJSON(data []byte) error {
 			verificationVector[i] = tmp
 		}
 		a.verificationVector = verificationVector
+		a.publicShares = false
 	} else {
-		return errors.New("account verificationvector missing")
+		// Verification vector is derived from the participants' share public keys below.
+		a.verificationVector = nil
+		a.publicShares = true
 	}
 	if val, exists := v["participants"]; exists {
 		participantData, ok := val.(map[string]any)
 		if !ok {
 			return errors.New("account participants invalid")
 		}
-		participants := make(map[uint64]string, len(participantData))
+		participants := make(map[uint64]*Participant, len(participantData))
 		for k, v := range participantData {
 			id, err := strconv.ParseUint(k, 10, 64)
 			if err != nil {
 				return errors.New("account participant ID invalid")
 			}
-			val, ok := v.(string)
-			if !ok {
-				return errors.New("account participant value invalid")
+			if id == 0 {
+				return errors.New("account participant ID invalid")
+			}
+			participant, err := participantFromData(v)
+			if err != nil {
+				return err
 			}
-			participants[id] = val
+			participants[id] = participant
 		}
 		a.participants = participants
 	} else {
@@ -188,13 +225,39 @@ func (a *account) UnmarshalJSON(data []byte) error {
 			return errors.New("account signing threshold invalid")
 		}
 		a.signingThreshold = uint32(signingThreshold)
-		if a.signingThreshold <= uint32(len(a.participants)/2) {
+		if a.signingThreshold == 0 {
 			return errors.New("account signing threshold too low")
 		}
 	} else {
 		return errors.New("account signing threshold missing")
 	}
-	if val, exists := v["crypto"]; exists {
+	if a.publicShares {
+		if participantSharePublicKeys(a.participants) == nil {
+			return errors.New("account verificationvector missing")
+		}
+		verificationVector, err := verificationVectorFromParticipants(a.signingThreshold, a.participants)
+		if err != nil {
+			return err
+		}
+		a.verificationVector = verificationVector
+	}
+	a.watchOnly = false
+	if val, exists := v["watch_only"]; exists {
+		watchOnly, ok := val.(bool)
+		if !ok {
+			return errors.New("account watch-only flag invalid")
+		}
+		a.watchOnly = watchOnly
+	}
+	if a.watchOnly {
+		if _, exists := v["crypto"]; exists {
+			return errors.New("watch-only account has crypto")
+		}
+		// The public key of a watch-only account is its composite public key.
+		if len(a.verificationVector) == 0 || !bytes.Equal(a.publicKey.Marshal(), a.verificationVector[0].Marshal()) {
+			return errors.New("watch-only account pubkey does not match verification vector")
+		}
+	} else if val, exists := v["crypto"]; exists {
 		crypto, ok := val.(map[string]any)
 		if !ok {
 			return errors.New("account crypto invalid")
@@ -203,6 +266,40 @@ func (a *account) UnmarshalJSON(data []byte) error {
 	} else {
 		return errors.New("account crypto missing")
 	}
+	if val, exists := v["metadata"]; exists {
+		metadataData, ok := val.(map[string]any)
+		if !ok {
+			return errors.New("account metadata invalid")
+		}
+		metadata := make(map[string]string, len(metadataData))
+		for k, v := range metadataData {
+			val, ok := v.(string)
+			if !ok {
+				return errors.New("account metadata value invalid")
+			}
+			metadata[k] = val
+		}
+		a.metadata = metadata
+	}
+	if a.watchOnly {
+		// Watch-only accounts are not encrypted, so have no key wrapper or encryptor.
+		a.keyWrapper = nil
+
+		return nil
+	}
+	if val, exists := v["key_wrapper"]; exists {
+		keyWrapperName, ok := val.(string)
+		if !ok {
+			return errors.New("account key wrapper invalid")
+		}
+		if a.keyWrapper == nil || a.keyWrapper.Name() != keyWrapperName {
+			return &unavailableKeyWrapperError{msg: fmt.Sprintf("account key wrapper %q unavailable", keyWrapperName)}
+		}
+
+		return nil
+	}
+	// Not a wrapped account, so ensure any key wrapper is not used.
+	a.keyWrapper = nil
 	if val, exists := v["version"]; exists {
 		version, ok := val.(float64)
 		if !ok {
@@ -216,7 +313,7 @@ func (a *account) UnmarshalJSON(data []byte) error {
 	if a.version == 4 {
 		a.encryptor = keystorev4.New()
 	} else {
-		return errors.New("unsupported keystore version")
+		return newUnsupportedVersionError(a.version, "unsupported keystore version")
 	}
 
 	return nil
@@ -230,6 +327,14 @@ func (a *account) ID() uuid.UUID {
 	return a.id
 }
 
+// WalletID provides the ID for the wallet holding the account.
+func (a *account) WalletID() uuid.UUID {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	return a.wallet.ID()
+}
+
 // Name provides the ID for the account.
 func (a *account) Name() string {
 	a.mutex.RLock()
@@ -247,10 +352,16 @@ func (a *account) PublicKey() e2types.PublicKey {
 }
 
 // CompositePublicKey provides the composite public key for the account.
+// This returns nil if the account has no verification vector; use ObtainCompositePublicKey()
+// to obtain an error in this situation.
 func (a *account) CompositePublicKey() e2types.PublicKey {
 	a.mutex.RLock()
 	defer a.mutex.RUnlock()
 
+	if len(a.verificationVector) == 0 {
+		return nil
+	}
+
 	return a.verificationVector[0]
 }
 
@@ -267,15 +378,78 @@ func (a *account) VerificationVector() []e2types.PublicKey {
 	a.mutex.RLock()
 	defer a.mutex.RUnlock()
 
-	return a.verificationVector
+	return copyVerificationVector(a.verificationVector)
 }
 
-// Participants provides the participants in this distributed account.
+// Participants provides the endpoints of the participants in this distributed account.
+// Full details of the participants are available from ParticipantDescriptors().
 func (a *account) Participants() map[uint64]string {
 	a.mutex.RLock()
 	defer a.mutex.RUnlock()
 
-	return a.participants
+	return a.participantEndpoints()
+}
+
+// ObtainCompositePublicKey provides the composite public key for the account.
+// This will error if the account has no verification vector.
+func (a *account) ObtainCompositePublicKey() (e2types.PublicKey, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	if len(a.verificationVector) == 0 {
+		return nil, errors.New("account has no verification vector")
+	}
+
+	return a.verificationVector[0], nil
+}
+
+// ObtainVerificationVector provides the verification vector for the account.
+// This will error if the verification vector is missing or its length does not match the signing threshold.
+func (a *account) ObtainVerificationVector() ([]e2types.PublicKey, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	if len(a.verificationVector) == 0 {
+		return nil, errors.New("account has no verification vector")
+	}
+	if uint32(len(a.verificationVector)) != a.signingThreshold {
+		return nil, fmt.Errorf("verification vector length %d does not match signing threshold %d",
+			len(a.verificationVector), a.signingThreshold)
+	}
+
+	return copyVerificationVector(a.verificationVector), nil
+}
+
+// ObtainParticipants provides the endpoints of the participants in this distributed account.
+// This will error if the account has no participants.
+func (a *account) ObtainParticipants() (map[uint64]string, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	if len(a.participants) == 0 {
+		return nil, errors.New("account has no participants")
+	}
+
+	return a.participantEndpoints(), nil
+}
+
+// participantEndpoints returns the endpoints of the participants.
+// This assumes that the account mutex is held.
+func (a *account) participantEndpoints() map[uint64]string {
+	participants := make(map[uint64]string, len(a.participants))
+	for k, v := range a.participants {
+		participants[k] = v.Endpoint
+	}
+
+	return participants
+}
+
+// copyVerificationVector returns a copy of a verification vector.
+func copyVerificationVector(verificationVector []e2types.PublicKey) []e2types.PublicKey {
+	res := make([]e2types.PublicKey, len(verificationVector))
+	copy(res, verificationVector)
+
+	return res
 }
 
 // PrivateKey provides the private key for the account.
@@ -283,8 +457,11 @@ func (a *account) PrivateKey(_ context.Context) (e2types.PrivateKey, error) {
 	a.mutex.RLock()
 	defer a.mutex.RUnlock()
 
+	if a.watchOnly {
+		return nil, newWatchOnlyError(a.name, "watch-only account has no private key")
+	}
 	if !a.unlocked {
-		return nil, errors.New("cannot provide private key when account is locked")
+		return nil, newLockedError("account", a.name, "cannot provide private key when account is locked")
 	}
 
 	return a.secretKey, nil
@@ -313,6 +490,9 @@ func (a *account) Unlock(ctx context.Context, passphrase []byte) error {
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 
+	if a.watchOnly {
+		return newWatchOnlyError(a.name, "watch-only account cannot be unlocked")
+	}
 	if a.unlocked {
 		// The account is already unlocked; nothing to do.
 		return nil
@@ -323,13 +503,13 @@ func (a *account) Unlock(ctx context.Context, passphrase []byte) error {
 		if a.crypto == nil {
 			// This is a batch account, decrypt the batch.
 			if err := a.wallet.batchDecrypt(ctx, passphrase); err != nil {
-				return errors.New("incorrect batch pasphrase")
+				return err
 			}
 		} else {
 			// This is an individual account, decrypt the account.
-			secretKeyBytes, err := a.encryptor.Decrypt(a.crypto, string(passphrase))
+			secretKeyBytes, err := a.decryptSecret(ctx, passphrase)
 			if err != nil {
-				return errors.New("incorrect passphrase")
+				return err
 			}
 			secretKey, err := e2types.BLSPrivateKeyFromBytes(secretKeyBytes)
 			if err != nil {
@@ -342,7 +522,7 @@ func (a *account) Unlock(ctx context.Context, passphrase []byte) error {
 		publicKey := a.secretKey.PublicKey()
 		if !bytes.Equal(publicKey.Marshal(), a.publicKey.Marshal()) {
 			a.secretKey = nil
-			return errors.New("private key does not correspond to public key")
+			return newCorruptDataError(nil, "private key does not correspond to public key")
 		}
 	}
 
@@ -366,13 +546,47 @@ func (a *account) Sign(_ context.Context, data []byte) (e2types.Signature, error
 	a.mutex.RLock()
 	defer a.mutex.RUnlock()
 
+	if a.watchOnly {
+		return nil, newWatchOnlyError(a.name, "watch-only account cannot sign")
+	}
 	if !a.unlocked {
-		return nil, errors.New("cannot sign when account is locked")
+		return nil, newLockedError("account", a.name, "cannot sign when account is locked")
 	}
 
 	return a.secretKey.Sign(data), nil
 }
 
+// updateStoredAccount applies an update to the account as held in the store.
+// The wallet must be unlocked.
+func (a *account) updateStoredAccount(ctx context.Context, update func(stored *account) error) error {
+	w := a.wallet
+	unlocked, err := w.IsUnlocked(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to obtain wallet lock status")
+	}
+	if !unlocked {
+		return newLockedError("wallet", w.name, "wallet must be unlocked to update accounts")
+	}
+
+	// Accounts obtained from a batch do not hold their encrypted secret key, so
+	// update the account as held in the store.
+	data, err := w.store.RetrieveAccount(w.id, a.ID())
+	if err != nil {
+		return newNotFoundError("account", a.ID().String(), err, "failed to retrieve account")
+	}
+	stored, err := deserializeAccount(w, data)
+	if err != nil {
+		return err
+	}
+	if err := update(stored); err != nil {
+		return err
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return stored.storeAccount(ctx)
+}
+
 // storeAccount stores the account.
 func (a *account) storeAccount(ctx context.Context) error {
 	data, err := json.Marshal(a)
@@ -406,8 +620,9 @@ func deserializeAccount(w *wallet, data []byte) (*account, error) {
 	}
 	a.wallet = w
 	a.encryptor = w.encryptor
+	a.keyWrapper = w.keyWrapper
 	if err := json.Unmarshal(data, a); err != nil {
-		return nil, errors.Wrap(err, "failed to unmarshal account")
+		return nil, unmarshalError(err, "failed to unmarshal account")
 	}
 
 	return a, nil
@@ -14,6 +14,7 @@
 package distributed
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"os"
@@ -139,14 +140,24 @@ func TestUnmarshalAccount(t *testing.T) {
 		},
 		{
 			name:  "BadSigningThreshold",
-			input: []byte(`{"crypto":{"checksum":{"function":"sha256","message":"5b2b545965b45bca2ea3cc47d3ec948e7b2270117f480886804fb8f38659538c","params":{}},"cipher":{"function":"aes-128-ctr","message":"e102b4647c602d58ceecd16c58b5001fb9cfae987664081cc47d73d22e2e12f4","params":{"iv":"a268c48c48bd568f1b03153b45669f31"}},"kdf":{"function":"pbkdf2","message":"","params":{"c":16,"dklen":32,"prf":"hmac-sha256","salt":"344d372d72bdabecd89d30d3cb14d5355b2801b2aa75b08dfeb0711f60f91c07"}}},"encryptor":"keystore","name":"Test account","participants":{"1":"signer-l01.attestant.io:8881","2":"signer-l02.attestant.io:8882","3":"signer-l03.attestant.io:8883"},"pubkey":"a304edb3fd6517ac7b58b9fdba472315adc1fcf9a519a081d0d855e0d65c0e23ea01f801951afa933507f98fc2a900d4","signing_threshold":1,"uuid":"0ea52ae0-b04a-4582-adc7-149b0a83c030","verificationvector":["b71f3dc08d96fa8b6afacc3d4c9942ec8c8eab6a2b4ee6e885ec34629e672a0f8b7741226df2071ff39afb8b9a08054e","a3a586504cfd4ccca23d0e4b4d198a59f54b5eb1a65e0c7ff2d14f1e8e6667aa45ac0eceb58b805a13e39ab76a2e601e"],"version":4}`),
+			input: []byte(`{"crypto":{"checksum":{"function":"sha256","message":"5b2b545965b45bca2ea3cc47d3ec948e7b2270117f480886804fb8f38659538c","params":{}},"cipher":{"function":"aes-128-ctr","message":"e102b4647c602d58ceecd16c58b5001fb9cfae987664081cc47d73d22e2e12f4","params":{"iv":"a268c48c48bd568f1b03153b45669f31"}},"kdf":{"function":"pbkdf2","message":"","params":{"c":16,"dklen":32,"prf":"hmac-sha256","salt":"344d372d72bdabecd89d30d3cb14d5355b2801b2aa75b08dfeb0711f60f91c07"}}},"encryptor":"keystore","name":"Test account","participants":{"1":"signer-l01.attestant.io:8881","2":"signer-l02.attestant.io:8882","3":"signer-l03.attestant.io:8883"},"pubkey":"a304edb3fd6517ac7b58b9fdba472315adc1fcf9a519a081d0d855e0d65c0e23ea01f801951afa933507f98fc2a900d4","signing_threshold":0,"uuid":"0ea52ae0-b04a-4582-adc7-149b0a83c030","verificationvector":["b71f3dc08d96fa8b6afacc3d4c9942ec8c8eab6a2b4ee6e885ec34629e672a0f8b7741226df2071ff39afb8b9a08054e","a3a586504cfd4ccca23d0e4b4d198a59f54b5eb1a65e0c7ff2d14f1e8e6667aa45ac0eceb58b805a13e39ab76a2e601e"],"version":4}`),
 			err:   "account signing threshold too low",
 		},
+		{
+			name:  "ParticipantIDZero",
+			input: []byte(`{"crypto":{"checksum":{"function":"sha256","message":"5b2b545965b45bca2ea3cc47d3ec948e7b2270117f480886804fb8f38659538c","params":{}},"cipher":{"function":"aes-128-ctr","message":"e102b4647c602d58ceecd16c58b5001fb9cfae987664081cc47d73d22e2e12f4","params":{"iv":"a268c48c48bd568f1b03153b45669f31"}},"kdf":{"function":"pbkdf2","message":"","params":{"c":16,"dklen":32,"prf":"hmac-sha256","salt":"344d372d72bdabecd89d30d3cb14d5355b2801b2aa75b08dfeb0711f60f91c07"}}},"encryptor":"keystore","name":"Test account","participants":{"0":"signer-l01.attestant.io:8881","2":"signer-l02.attestant.io:8882","3":"signer-l03.attestant.io:8883"},"pubkey":"a304edb3fd6517ac7b58b9fdba472315adc1fcf9a519a081d0d855e0d65c0e23ea01f801951afa933507f98fc2a900d4","signing_threshold":2,"uuid":"0ea52ae0-b04a-4582-adc7-149b0a83c030","verificationvector":["b71f3dc08d96fa8b6afacc3d4c9942ec8c8eab6a2b4ee6e885ec34629e672a0f8b7741226df2071ff39afb8b9a08054e","a3a586504cfd4ccca23d0e4b4d198a59f54b5eb1a65e0c7ff2d14f1e8e6667aa45ac0eceb58b805a13e39ab76a2e601e"],"version":4}`),
+			err:   "account participant ID invalid",
+		},
 		{
 			name:  "WrongSigningThreshold",
 			input: []byte(`{"crypto":{"checksum":{"function":"sha256","message":"5b2b545965b45bca2ea3cc47d3ec948e7b2270117f480886804fb8f38659538c","params":{}},"cipher":{"function":"aes-128-ctr","message":"e102b4647c602d58ceecd16c58b5001fb9cfae987664081cc47d73d22e2e12f4","params":{"iv":"a268c48c48bd568f1b03153b45669f31"}},"kdf":{"function":"pbkdf2","message":"","params":{"c":16,"dklen":32,"prf":"hmac-sha256","salt":"344d372d72bdabecd89d30d3cb14d5355b2801b2aa75b08dfeb0711f60f91c07"}}},"encryptor":"keystore","name":"Test account","participants":{"1":"signer-l01.attestant.io:8881","2":"signer-l02.attestant.io:8882","3":"signer-l03.attestant.io:8883"},"pubkey":"a304edb3fd6517ac7b58b9fdba472315adc1fcf9a519a081d0d855e0d65c0e23ea01f801951afa933507f98fc2a900d4","signing_threshold":"two","uuid":"0ea52ae0-b04a-4582-adc7-149b0a83c030","verificationvector":["b71f3dc08d96fa8b6afacc3d4c9942ec8c8eab6a2b4ee6e885ec34629e672a0f8b7741226df2071ff39afb8b9a08054e","a3a586504cfd4ccca23d0e4b4d198a59f54b5eb1a65e0c7ff2d14f1e8e6667aa45ac0eceb58b805a13e39ab76a2e601e"],"version":4}`),
 			err:   "account signing threshold invalid",
 		},
+		{
+			name:  "WatchOnlyWithCrypto",
+			input: []byte(`{"crypto":{"checksum":{"function":"sha256","message":"5b2b545965b45bca2ea3cc47d3ec948e7b2270117f480886804fb8f38659538c","params":{}},"cipher":{"function":"aes-128-ctr","message":"e102b4647c602d58ceecd16c58b5001fb9cfae987664081cc47d73d22e2e12f4","params":{"iv":"a268c48c48bd568f1b03153b45669f31"}},"kdf":{"function":"pbkdf2","message":"","params":{"c":16,"dklen":32,"prf":"hmac-sha256","salt":"344d372d72bdabecd89d30d3cb14d5355b2801b2aa75b08dfeb0711f60f91c07"}}},"encryptor":"keystore","name":"Test account","participants":{"1":"signer-l01.attestant.io:8881","2":"signer-l02.attestant.io:8882","3":"signer-l03.attestant.io:8883"},"pubkey":"a304edb3fd6517ac7b58b9fdba472315adc1fcf9a519a081d0d855e0d65c0e23ea01f801951afa933507f98fc2a900d4","signing_threshold":2,"watch_only":true,"uuid":"0ea52ae0-b04a-4582-adc7-149b0a83c030","verificationvector":["b71f3dc08d96fa8b6afacc3d4c9942ec8c8eab6a2b4ee6e885ec34629e672a0f8b7741226df2071ff39afb8b9a08054e","a3a586504cfd4ccca23d0e4b4d198a59f54b5eb1a65e0c7ff2d14f1e8e6667aa45ac0eceb58b805a13e39ab76a2e601e"],"version":4}`),
+			err:   "watch-only account has crypto",
+		},
 		{
 			name:  "MissingVerificationVector",
 			input: []byte(`{"crypto":{"checksum":{"function":"sha256","message":"5b2b545965b45bca2ea3cc47d3ec948e7b2270117f480886804fb8f38659538c","params":{}},"cipher":{"function":"aes-128-ctr","message":"e102b4647c602d58ceecd16c58b5001fb9cfae987664081cc47d73d22e2e12f4","params":{"iv":"a268c48c48bd568f1b03153b45669f31"}},"kdf":{"function":"pbkdf2","message":"","params":{"c":16,"dklen":32,"prf":"hmac-sha256","salt":"344d372d72bdabecd89d30d3cb14d5355b2801b2aa75b08dfeb0711f60f91c07"}}},"encryptor":"keystore","name":"Test account","participants":{"1":"signer-l01.attestant.io:8881","2":"signer-l02.attestant.io:8882","3":"signer-l03.attestant.io:8883"},"pubkey":"a304edb3fd6517ac7b58b9fdba472315adc1fcf9a519a081d0d855e0d65c0e23ea01f801951afa933507f98fc2a900d4","signing_threshold":2,"uuid":"0ea52ae0-b04a-4582-adc7-149b0a83c030","version":4}`),
@@ -172,6 +183,28 @@ func TestUnmarshalAccount(t *testing.T) {
 			input: []byte(`{"crypto":{"checksum":{"function":"sha256","message":"5b2b545965b45bca2ea3cc47d3ec948e7b2270117f480886804fb8f38659538c","params":{}},"cipher":{"function":"aes-128-ctr","message":"e102b4647c602d58ceecd16c58b5001fb9cfae987664081cc47d73d22e2e12f4","params":{"iv":"a268c48c48bd568f1b03153b45669f31"}},"kdf":{"function":"pbkdf2","message":"","params":{"c":16,"dklen":32,"prf":"hmac-sha256","salt":"344d372d72bdabecd89d30d3cb14d5355b2801b2aa75b08dfeb0711f60f91c07"}}},"encryptor":"keystore","name":"Test account","participants":{"1":"signer-l01.attestant.io:8881","2":"signer-l02.attestant.io:8882","3":"signer-l03.attestant.io:8883"},"pubkey":"a304edb3fd6517ac7b58b9fdba472315adc1fcf9a519a081d0d855e0d65c0e23ea01f801951afa933507f98fc2a900d4","signing_threshold":2,"uuid":"0ea52ae0-b04a-4582-adc7-149b0a83c030","verificationvector":["w71f3dc08d96fa8b6afacc3d4c9942ec8c8eab6a2b4ee6e885ec34629e672a0f8b7741226df2071ff39afb8b9a08054e","a3a586504cfd4ccca23d0e4b4d198a59f54b5eb1a65e0c7ff2d14f1e8e6667aa45ac0eceb58b805a13e39ab76a2e601e"],"version":4}`),
 			err:   "failed to decode verification vector element 0: encoding/hex: invalid byte: U+0077 'w'",
 		},
+		{
+			name:  "MetadataInvalid",
+			input: []byte(`{"crypto":{"checksum":{"function":"sha256","message":"5b2b545965b45bca2ea3cc47d3ec948e7b2270117f480886804fb8f38659538c","params":{}},"cipher":{"function":"aes-128-ctr","message":"e102b4647c602d58ceecd16c58b5001fb9cfae987664081cc47d73d22e2e12f4","params":{"iv":"a268c48c48bd568f1b03153b45669f31"}},"kdf":{"function":"pbkdf2","message":"","params":{"c":16,"dklen":32,"prf":"hmac-sha256","salt":"344d372d72bdabecd89d30d3cb14d5355b2801b2aa75b08dfeb0711f60f91c07"}}},"encryptor":"keystore","metadata":"foo","name":"Test account","participants":{"1":"signer-l01.attestant.io:8881","2":"signer-l02.attestant.io:8882","3":"signer-l03.attestant.io:8883"},"pubkey":"a304edb3fd6517ac7b58b9fdba472315adc1fcf9a519a081d0d855e0d65c0e23ea01f801951afa933507f98fc2a900d4","signing_threshold":2,"uuid":"0ea52ae0-b04a-4582-adc7-149b0a83c030","verificationvector":["b71f3dc08d96fa8b6afacc3d4c9942ec8c8eab6a2b4ee6e885ec34629e672a0f8b7741226df2071ff39afb8b9a08054e","a3a586504cfd4ccca23d0e4b4d198a59f54b5eb1a65e0c7ff2d14f1e8e6667aa45ac0eceb58b805a13e39ab76a2e601e"],"version":4}`),
+			err:   "account metadata invalid",
+		},
+		{
+			name:  "MetadataValueInvalid",
+			input: []byte(`{"crypto":{"checksum":{"function":"sha256","message":"5b2b545965b45bca2ea3cc47d3ec948e7b2270117f480886804fb8f38659538c","params":{}},"cipher":{"function":"aes-128-ctr","message":"e102b4647c602d58ceecd16c58b5001fb9cfae987664081cc47d73d22e2e12f4","params":{"iv":"a268c48c48bd568f1b03153b45669f31"}},"kdf":{"function":"pbkdf2","message":"","params":{"c":16,"dklen":32,"prf":"hmac-sha256","salt":"344d372d72bdabecd89d30d3cb14d5355b2801b2aa75b08dfeb0711f60f91c07"}}},"encryptor":"keystore","metadata":{"foo":1},"name":"Test account","participants":{"1":"signer-l01.attestant.io:8881","2":"signer-l02.attestant.io:8882","3":"signer-l03.attestant.io:8883"},"pubkey":"a304edb3fd6517ac7b58b9fdba472315adc1fcf9a519a081d0d855e0d65c0e23ea01f801951afa933507f98fc2a900d4","signing_threshold":2,"uuid":"0ea52ae0-b04a-4582-adc7-149b0a83c030","verificationvector":["b71f3dc08d96fa8b6afacc3d4c9942ec8c8eab6a2b4ee6e885ec34629e672a0f8b7741226df2071ff39afb8b9a08054e","a3a586504cfd4ccca23d0e4b4d198a59f54b5eb1a65e0c7ff2d14f1e8e6667aa45ac0eceb58b805a13e39ab76a2e601e"],"version":4}`),
+			err:   "account metadata value invalid",
+		},
+		{
+			name:  "ParticipantSharePubkeyInvalid",
+			input: []byte(`{"crypto":{"checksum":{"function":"sha256","message":"5b2b545965b45bca2ea3cc47d3ec948e7b2270117f480886804fb8f38659538c","params":{}},"cipher":{"function":"aes-128-ctr","message":"e102b4647c602d58ceecd16c58b5001fb9cfae987664081cc47d73d22e2e12f4","params":{"iv":"a268c48c48bd568f1b03153b45669f31"}},"kdf":{"function":"pbkdf2","message":"","params":{"c":16,"dklen":32,"prf":"hmac-sha256","salt":"344d372d72bdabecd89d30d3cb14d5355b2801b2aa75b08dfeb0711f60f91c07"}}},"encryptor":"keystore","name":"Test account","participants":{"1":{"endpoint":"signer-l01.attestant.io:8881","share_pubkey":"zz"},"2":"signer-l02.attestant.io:8882","3":"signer-l03.attestant.io:8883"},"pubkey":"a304edb3fd6517ac7b58b9fdba472315adc1fcf9a519a081d0d855e0d65c0e23ea01f801951afa933507f98fc2a900d4","signing_threshold":2,"uuid":"0ea52ae0-b04a-4582-adc7-149b0a83c030","verificationvector":["b71f3dc08d96fa8b6afacc3d4c9942ec8c8eab6a2b4ee6e885ec34629e672a0f8b7741226df2071ff39afb8b9a08054e","a3a586504cfd4ccca23d0e4b4d198a59f54b5eb1a65e0c7ff2d14f1e8e6667aa45ac0eceb58b805a13e39ab76a2e601e"],"version":4}`),
+			err:   "account participant value invalid: invalid share public key: encoding/hex: invalid byte: U+007A 'z'",
+		},
+		{
+			name:      "GoodParticipantDescriptors",
+			input:     []byte(`{"crypto":{"checksum":{"function":"sha256","message":"5b2b545965b45bca2ea3cc47d3ec948e7b2270117f480886804fb8f38659538c","params":{}},"cipher":{"function":"aes-128-ctr","message":"e102b4647c602d58ceecd16c58b5001fb9cfae987664081cc47d73d22e2e12f4","params":{"iv":"a268c48c48bd568f1b03153b45669f31"}},"kdf":{"function":"pbkdf2","message":"","params":{"c":16,"dklen":32,"prf":"hmac-sha256","salt":"344d372d72bdabecd89d30d3cb14d5355b2801b2aa75b08dfeb0711f60f91c07"}}},"encryptor":"keystore","name":"Test account","participants":{"1":{"endpoint":"signer-l01.attestant.io:8881","name":"Signer 1","certificate_fingerprint":"0102","share_pubkey":"a304edb3fd6517ac7b58b9fdba472315adc1fcf9a519a081d0d855e0d65c0e23ea01f801951afa933507f98fc2a900d4"},"2":"signer-l02.attestant.io:8882","3":{"endpoint":"signer-l03.attestant.io:8883","identity_key":"0x0304"}},"pubkey":"a304edb3fd6517ac7b58b9fdba472315adc1fcf9a519a081d0d855e0d65c0e23ea01f801951afa933507f98fc2a900d4","signing_threshold":2,"uuid":"0ea52ae0-b04a-4582-adc7-149b0a83c030","verificationvector":["b71f3dc08d96fa8b6afacc3d4c9942ec8c8eab6a2b4ee6e885ec34629e672a0f8b7741226df2071ff39afb8b9a08054e","a3a586504cfd4ccca23d0e4b4d198a59f54b5eb1a65e0c7ff2d14f1e8e6667aa45ac0eceb58b805a13e39ab76a2e601e"],"version":4}`),
+			id:        uuid.MustParse("0ea52ae0-b04a-4582-adc7-149b0a83c030"),
+			publicKey: []byte{0xb7, 0x1f, 0x3d, 0xc0, 0x8d, 0x96, 0xfa, 0x8b, 0x6a, 0xfa, 0xcc, 0x3d, 0x4c, 0x99, 0x42, 0xec, 0x8c, 0x8e, 0xab, 0x6a, 0x2b, 0x4e, 0xe6, 0xe8, 0x85, 0xec, 0x34, 0x62, 0x9e, 0x67, 0x2a, 0x0f, 0x8b, 0x77, 0x41, 0x22, 0x6d, 0xf2, 0x07, 0x1f, 0xf3, 0x9a, 0xfb, 0x8b, 0x9a, 0x08, 0x05, 0x4e},
+			version:   4,
+		},
 		{
 			name:      "Good",
 			input:     []byte(`{"crypto":{"checksum":{"function":"sha256","message":"5b2b545965b45bca2ea3cc47d3ec948e7b2270117f480886804fb8f38659538c","params":{}},"cipher":{"function":"aes-128-ctr","message":"e102b4647c602d58ceecd16c58b5001fb9cfae987664081cc47d73d22e2e12f4","params":{"iv":"a268c48c48bd568f1b03153b45669f31"}},"kdf":{"function":"pbkdf2","message":"","params":{"c":16,"dklen":32,"prf":"hmac-sha256","salt":"344d372d72bdabecd89d30d3cb14d5355b2801b2aa75b08dfeb0711f60f91c07"}}},"encryptor":"keystore","name":"Test account","participants":{"1":"signer-l01.attestant.io:8881","2":"signer-l02.attestant.io:8882","3":"signer-l03.attestant.io:8883"},"pubkey":"a304edb3fd6517ac7b58b9fdba472315adc1fcf9a519a081d0d855e0d65c0e23ea01f801951afa933507f98fc2a900d4","signing_threshold":2,"uuid":"0ea52ae0-b04a-4582-adc7-149b0a83c030","verificationvector":["b71f3dc08d96fa8b6afacc3d4c9942ec8c8eab6a2b4ee6e885ec34629e672a0f8b7741226df2071ff39afb8b9a08054e","a3a586504cfd4ccca23d0e4b4d198a59f54b5eb1a65e0c7ff2d14f1e8e6667aa45ac0eceb58b805a13e39ab76a2e601e"],"version":4}`),
@@ -267,3 +300,70 @@ func TestUnlock(t *testing.T) {
 		})
 	}
 }
+
+func TestAccessors(t *testing.T) {
+	input := []byte(`{"crypto":{"checksum":{"function":"sha256","message":"5b2b545965b45bca2ea3cc47d3ec948e7b2270117f480886804fb8f38659538c","params":{}},"cipher":{"function":"aes-128-ctr","message":"e102b4647c602d58ceecd16c58b5001fb9cfae987664081cc47d73d22e2e12f4","params":{"iv":"a268c48c48bd568f1b03153b45669f31"}},"kdf":{"function":"pbkdf2","message":"","params":{"c":16,"dklen":32,"prf":"hmac-sha256","salt":"344d372d72bdabecd89d30d3cb14d5355b2801b2aa75b08dfeb0711f60f91c07"}}},"encryptor":"keystore","name":"Test account","participants":{"1":"signer-l01.attestant.io:8881","2":"signer-l02.attestant.io:8882","3":"signer-l03.attestant.io:8883"},"pubkey":"a304edb3fd6517ac7b58b9fdba472315adc1fcf9a519a081d0d855e0d65c0e23ea01f801951afa933507f98fc2a900d4","signing_threshold":2,"uuid":"0ea52ae0-b04a-4582-adc7-149b0a83c030","verificationvector":["b71f3dc08d96fa8b6afacc3d4c9942ec8c8eab6a2b4ee6e885ec34629e672a0f8b7741226df2071ff39afb8b9a08054e","a3a586504cfd4ccca23d0e4b4d198a59f54b5eb1a65e0c7ff2d14f1e8e6667aa45ac0eceb58b805a13e39ab76a2e601e"],"version":4}`)
+
+	t.Run("Copies", func(t *testing.T) {
+		account, err := newAccount()
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(input, account))
+
+		verificationVector := account.VerificationVector()
+		verificationVector[0] = verificationVector[1]
+		require.NotEqual(t, account.VerificationVector()[0].Marshal(), account.VerificationVector()[1].Marshal())
+
+		participants := account.Participants()
+		participants[1] = "changed"
+		delete(participants, 2)
+		require.Equal(t, map[uint64]string{
+			1: "signer-l01.attestant.io:8881",
+			2: "signer-l02.attestant.io:8882",
+			3: "signer-l03.attestant.io:8883",
+		}, account.Participants())
+
+		compositePublicKey, err := account.ObtainCompositePublicKey()
+		require.NoError(t, err)
+		require.Equal(t, account.CompositePublicKey(), compositePublicKey)
+		verificationVector, err = account.ObtainVerificationVector()
+		require.NoError(t, err)
+		require.Len(t, verificationVector, 2)
+		participants, err = account.ObtainParticipants()
+		require.NoError(t, err)
+		require.Len(t, participants, 3)
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		account, err := newAccount()
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(input, account))
+		account.verificationVector = nil
+		account.participants = nil
+
+		require.Nil(t, account.CompositePublicKey())
+		require.Empty(t, account.VerificationVector())
+		require.Empty(t, account.Participants())
+		_, err = account.ObtainCompositePublicKey()
+		require.EqualError(t, err, "account has no verification vector")
+		_, err = account.ObtainVerificationVector()
+		require.EqualError(t, err, "account has no verification vector")
+		_, err = account.ObtainParticipants()
+		require.EqualError(t, err, "account has no participants")
+
+		account.verificationVector = make([]e2types.PublicKey, 1)
+		_, err = account.ObtainVerificationVector()
+		require.EqualError(t, err, "verification vector length 1 does not match signing threshold 2")
+	})
+
+	t.Run("EmptyVerificationVector", func(t *testing.T) {
+		account, err := newAccount()
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(bytes.Replace(input,
+			[]byte(`["b71f3dc08d96fa8b6afacc3d4c9942ec8c8eab6a2b4ee6e885ec34629e672a0f8b7741226df2071ff39afb8b9a08054e","a3a586504cfd4ccca23d0e4b4d198a59f54b5eb1a65e0c7ff2d14f1e8e6667aa45ac0eceb58b805a13e39ab76a2e601e"]`),
+			[]byte(`[]`), 1), account))
+		require.Nil(t, account.CompositePublicKey())
+		account.unlocked = true
+		_, _, err = account.ExportKeystore(context.Background(), []byte("pass"))
+		require.EqualError(t, err, "account has no verification vector")
+	})
+}
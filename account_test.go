@@ -61,18 +61,6 @@ func TestImportAccount(t *testing.T) {
 			accountName: "_bad",
 			err:         `invalid account name "_bad"`,
 		},
-		{
-			name:             "KeyMissing",
-			accountName:      "test",
-			signingThreshold: 2,
-			verificationVector: [][]byte{
-				_byteArray("b5f7f572e3f50a970af6c13f02e2c20900cda0dffdcf8b2e2a06c78ba2bae667bfa7aab01b36fba268da4aa2aba5c68f"),
-				_byteArray("a88427e16f45b632f83247220bd885241cff6fd035803e976fe96c6352933d01a6205d6f3e87a96789cddcca64bbcf25"),
-			},
-			participants: map[uint64]string{1: "foo", 2: "bar", 3: "baz"},
-			passphrase:   []byte("test passphrase"),
-			err:          "private key missing",
-		},
 		{
 			name:             "VerificationVectorMissing",
 			accountName:      "test",
@@ -119,6 +107,33 @@ func TestImportAccount(t *testing.T) {
 			passphrase:   []byte("test passphrase"),
 			err:          "invalid signing threshold:participant ratio",
 		},
+		{
+			name:             "ParticipantIDZero",
+			accountName:      "test",
+			key:              _byteArray("220091d10843519cd1c452a4ec721d378d7d4c5ece81c4b5556092d410e5e0e1"),
+			signingThreshold: 2,
+			verificationVector: [][]byte{
+				_byteArray("b5f7f572e3f50a970af6c13f02e2c20900cda0dffdcf8b2e2a06c78ba2bae667bfa7aab01b36fba268da4aa2aba5c68f"),
+				_byteArray("a88427e16f45b632f83247220bd885241cff6fd035803e976fe96c6352933d01a6205d6f3e87a96789cddcca64bbcf25"),
+			},
+			participants: map[uint64]string{0: "foo", 1: "bar", 2: "baz"},
+			passphrase:   []byte("test passphrase"),
+			err:          "participant ID 0 invalid",
+		},
+		{
+			name:             "SigningThresholdTooHigh",
+			accountName:      "test",
+			key:              _byteArray("220091d10843519cd1c452a4ec721d378d7d4c5ece81c4b5556092d410e5e0e1"),
+			signingThreshold: 3,
+			verificationVector: [][]byte{
+				_byteArray("b5f7f572e3f50a970af6c13f02e2c20900cda0dffdcf8b2e2a06c78ba2bae667bfa7aab01b36fba268da4aa2aba5c68f"),
+				_byteArray("a88427e16f45b632f83247220bd885241cff6fd035803e976fe96c6352933d01a6205d6f3e87a96789cddcca64bbcf25"),
+				_byteArray("a4da59f92bea77d3950cb578c2b8c8ee65e12040e9efd4c82cb4b0ac6138fef5d8f4bb53971bafdf6285f22f91b22b2f"),
+			},
+			participants: map[uint64]string{1: "foo", 2: "bar"},
+			passphrase:   []byte("test passphrase"),
+			err:          "signing threshold 3 exceeds number of participants 2",
+		},
 		{
 			name:             "ImbalancedParticipants",
 			accountName:      "test",
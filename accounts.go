@@ -0,0 +1,340 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+	"github.com/wealdtech/go-indexer"
+)
+
+// AccountResult is the result of obtaining a single account when iterating over a wallet.
+// Exactly one of Account and Err is set.
+type AccountResult struct {
+	// Account is the account, if it was obtained successfully.
+	Account e2wtypes.Account
+	// ID is the ID of the account, if known.
+	ID uuid.UUID
+	// Name is the name of the account, if known.
+	Name string
+	// Err is the error encountered when obtaining the account.
+	Err error
+}
+
+// AccountOrder is the order in which accounts are provided when iterating over a wallet.
+// There is no order by insertion: ordered accounts are obtained from the wallet's accounts index,
+// which does not record when accounts were added, and the index format is shared with other wallet
+// types so cannot be extended to do so.
+type AccountOrder int
+
+const (
+	// OrderNone provides accounts in no particular order.
+	OrderNone AccountOrder = iota
+	// OrderByName provides accounts ordered by name.
+	OrderByName
+	// OrderByID provides accounts ordered by ID.
+	OrderByID
+)
+
+// iterateOptions are the options for iterating over accounts.
+type iterateOptions struct {
+	strict   bool
+	order    AccountOrder
+	offset   int
+	limit    int
+	metadata map[string]string
+}
+
+// IterateOption gives options to IterateAccounts.
+type IterateOption interface {
+	apply(*iterateOptions)
+}
+
+type iterateOptionFunc func(*iterateOptions)
+
+func (f iterateOptionFunc) apply(o *iterateOptions) {
+	f(o)
+}
+
+// validate checks that the options are valid.
+func (o *iterateOptions) validate() error {
+	if o.offset < 0 {
+		return fmt.Errorf("offset %d is negative", o.offset)
+	}
+	if o.limit < 0 {
+		return fmt.Errorf("limit %d is negative", o.limit)
+	}
+
+	return nil
+}
+
+// WithStrict stops iteration at the first error.
+func WithStrict() IterateOption {
+	return iterateOptionFunc(func(o *iterateOptions) {
+		o.strict = true
+	})
+}
+
+// WithOrder provides accounts in the given order.
+// Ordered accounts are obtained from the wallet's accounts index, so accounts missing from
+// the index are not provided; use Verify to find and repair such accounts.
+func WithOrder(order AccountOrder) IterateOption {
+	return iterateOptionFunc(func(o *iterateOptions) {
+		o.order = order
+	})
+}
+
+// WithOffset skips the given number of results.
+// Paging is only stable when used in conjunction with WithOrder.
+func WithOffset(offset int) IterateOption {
+	return iterateOptionFunc(func(o *iterateOptions) {
+		o.offset = offset
+	})
+}
+
+// WithLimit provides at most the given number of results.
+// Paging is only stable when used in conjunction with WithOrder.
+func WithLimit(limit int) IterateOption {
+	return iterateOptionFunc(func(o *iterateOptions) {
+		o.limit = limit
+	})
+}
+
+// WalletAccountsIterator is the interface for wallets that can report errors when iterating over their accounts.
+type WalletAccountsIterator interface {
+	// IterateAccounts provides all accounts in the wallet, along with any errors encountered obtaining them.
+	IterateAccounts(ctx context.Context, opts ...IterateOption) <-chan *AccountResult
+}
+
+// Accounts provides all accounts in the wallet.
+// Accounts that cannot be obtained are omitted; use IterateAccounts to find out about them.
+func (w *wallet) Accounts(ctx context.Context) <-chan e2wtypes.Account {
+	ch := make(chan e2wtypes.Account, 1024)
+
+	go func(ch chan e2wtypes.Account) {
+		for res := range w.IterateAccounts(ctx) {
+			if res.Err == nil {
+				ch <- res.Account
+			}
+		}
+		close(ch)
+	}(ch)
+
+	return ch
+}
+
+// IterateAccounts provides all accounts in the wallet, along with any errors encountered obtaining them.
+// By default iteration continues past errors; WithStrict stops iteration after the first error.
+// Invalid options result in a single error and no accounts.
+// A store that fails to supply a batch is not reported, as this is indistinguishable from the wallet
+// not having a batch, and accounts are read individually instead.
+func (w *wallet) IterateAccounts(ctx context.Context, opts ...IterateOption) <-chan *AccountResult {
+	options := &iterateOptions{}
+	for _, o := range opts {
+		o.apply(options)
+	}
+
+	ch := make(chan *AccountResult, 1024)
+
+	if err := options.validate(); err != nil {
+		ch <- &AccountResult{Err: err}
+		close(ch)
+
+		return ch
+	}
+
+	go func(ch chan *AccountResult) {
+		defer close(ch)
+
+		// send sends a result, returning false if iteration should stop.
+		send := func(res *AccountResult) bool {
+			select {
+			case <-ctx.Done():
+				return false
+			case ch <- res:
+			}
+
+			return res.Err == nil || !options.strict
+		}
+
+		p := &pager{options: options, send: send}
+
+		if options.order != OrderNone {
+			w.iterateOrderedAccounts(ctx, p)
+
+			return
+		}
+
+		if err := w.retrieveBatchIfRequired(ctx); err != nil && reportableBatchError(err) {
+			if !p.page(&AccountResult{Err: err}) {
+				return
+			}
+		}
+
+		if w.batch != nil && len(w.batch.entries) > 0 {
+			// Batch present, use pre-loaded accounts.
+			for _, account := range w.accounts {
+				if !p.page(&AccountResult{Account: account, ID: account.id, Name: account.name}) {
+					return
+				}
+			}
+
+			return
+		}
+
+		// No batch; fall back to individual accounts on the store.
+		for data := range w.store.RetrieveAccounts(w.ID()) {
+			account, err := deserializeAccount(w, data)
+			var res *AccountResult
+			if err == nil {
+				res = &AccountResult{Account: account, ID: account.id, Name: account.name}
+			} else {
+				res = &AccountResult{Err: err}
+				res.ID, res.Name = accountIdentity(data)
+			}
+			if !p.page(res) {
+				return
+			}
+		}
+	}(ch)
+
+	return ch
+}
+
+// iterateOrderedAccounts sends the accounts in the wallet's index in the requested order.
+// If accounts are not filtered only the accounts in the requested page are obtained.
+func (w *wallet) iterateOrderedAccounts(ctx context.Context, p *pager) {
+	options := p.options
+	entries, err := indexEntries(w.index)
+	if err != nil {
+		p.send(&AccountResult{Err: err})
+
+		return
+	}
+
+	switch options.order {
+	case OrderByName:
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Name < entries[j].Name
+		})
+	case OrderByID:
+		sort.Slice(entries, func(i, j int) bool {
+			return bytes.Compare(entries[i].ID[:], entries[j].ID[:]) < 0
+		})
+	default:
+	}
+
+	filtered := len(options.metadata) > 0
+	if !filtered {
+		// Page the entries up front, so that only the required accounts are obtained.
+		if options.offset >= len(entries) {
+			return
+		}
+		entries = entries[options.offset:]
+		if options.limit > 0 && options.limit < len(entries) {
+			entries = entries[:options.limit]
+		}
+	}
+
+	for _, entry := range entries {
+		res := &AccountResult{ID: entry.ID, Name: entry.Name}
+		res.Account, res.Err = w.AccountByID(ctx, entry.ID)
+		if res.Err != nil {
+			res.Account = nil
+		}
+		if filtered {
+			if !p.page(res) {
+				return
+			}
+		} else if !p.send(res) {
+			return
+		}
+	}
+}
+
+// pager applies filtering, offset and limit to results as they are obtained.
+type pager struct {
+	options *iterateOptions
+	send    func(*AccountResult) bool
+	skipped int
+	sent    int
+}
+
+// page sends the result if required, returning false if iteration should stop.
+func (p *pager) page(res *AccountResult) bool {
+	if res.Err == nil && !p.options.selects(res.Account) {
+		return true
+	}
+	if p.skipped < p.options.offset {
+		p.skipped++
+
+		return res.Err == nil || !p.options.strict
+	}
+	if !p.send(res) {
+		return false
+	}
+	p.sent++
+
+	return p.options.limit == 0 || p.sent < p.options.limit
+}
+
+// indexEntry is an entry in the accounts index.
+type indexEntry struct {
+	ID   uuid.UUID `json:"uuid"`
+	Name string    `json:"name"`
+}
+
+// indexEntries provides the entries in an accounts index.
+func indexEntries(index *indexer.Index) ([]*indexEntry, error) {
+	data, err := index.Serialize()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to serialize index")
+	}
+	entries := make([]*indexEntry, 0)
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal index")
+	}
+
+	return entries, nil
+}
+
+// reportableBatchError returns true if the error from obtaining a batch should be reported.
+func reportableBatchError(err error) bool {
+	return errors.Is(err, ErrCorruptData) || errors.Is(err, ErrUnsupportedVersion)
+}
+
+// accountIdentity obtains what it can of the ID and name of a serialized account
+// that could not otherwise be deserialized.
+func accountIdentity(data []byte) (uuid.UUID, string) {
+	identity := struct {
+		UUID string `json:"uuid"`
+		Name string `json:"name"`
+	}{}
+	if err := json.Unmarshal(data, &identity); err != nil {
+		return uuid.Nil, ""
+	}
+	id, err := uuid.Parse(identity.UUID)
+	if err != nil {
+		id = uuid.Nil
+	}
+
+	return id, identity.Name
+}
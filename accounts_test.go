@@ -0,0 +1,225 @@
+// Copyright © 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	scratch "github.com/wealdtech/go-eth2-wallet-store-scratch"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+func TestIterateAccounts(t *testing.T) {
+	ctx := context.Background()
+	participants := map[uint64]string{1: "foo", 2: "bar", 3: "baz"}
+
+	// setup creates a wallet with two accounts, the second of which is corrupt.
+	setup := func(t *testing.T) (e2wtypes.Store, e2wtypes.Wallet, e2wtypes.Account) {
+		t.Helper()
+		store := scratch.New()
+		wallet, err := distributed.CreateWallet(ctx, "test wallet", store, keystorev4.New())
+		require.NoError(t, err)
+		require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+		importer := wallet.(e2wtypes.WalletDistributedAccountImporter)
+		_, err = importer.ImportDistributedAccount(ctx,
+			"Account 1",
+			_byteArray("01e748d098d3bcb477d636f19d510399ae18205fadf9814ee67052f88c1f77c0"),
+			3,
+			[][]byte{
+				_byteArray("a0633864987df6f7a0f40fbecbe0d15fe5317c00adccc0b816266bcf3d3d1ab6a365b3d79461b5da5a8ea09e37644731"),
+				_byteArray("a7430ae4717f511e473ed87a6510c5dfdbf289b7c7c4e083270da487aa146b0291ee701e0c38033aa157a612b8fd488b"),
+				_byteArray("b5e95fbcf45c9f2730f04abab22672dddca4b10e32467ab8522d7d153a382c9656d28268b5e75361e4e39a5d12513d8c"),
+			},
+			participants,
+			[]byte("pass"))
+		require.NoError(t, err)
+		corrupt, err := importer.ImportDistributedAccount(ctx,
+			"Account 2",
+			_byteArray("376880b8079dca3bbd06c93958b5208929cbc169c9ce4caf8731be10e94f710e"),
+			3,
+			[][]byte{
+				_byteArray("a0633864987df6f7a0f40fbecbe0d15fe5317c00adccc0b816266bcf3d3d1ab6a365b3d79461b5da5a8ea09e37644731"),
+				_byteArray("b13d6e14cce66b3827b816c974e8f52a76e86611de58bbcdac116a9e97b00240a29714646202a65ae72df480bdfa5329"),
+				_byteArray("81a00aee312320aa82316ea14b6615eb56f531ecdabc1effca2a55d0282f3c2463124b792da5ec0207d16119360bd896"),
+			},
+			participants,
+			[]byte("pass"))
+		require.NoError(t, err)
+		require.NoError(t, store.StoreAccount(wallet.ID(), corrupt.ID(),
+			[]byte(`{"uuid":"`+corrupt.ID().String()+`","name":"Account 2"}`)))
+
+		wallet, err = distributed.OpenWallet(ctx, "test wallet", store, keystorev4.New())
+		require.NoError(t, err)
+
+		return store, wallet, corrupt
+	}
+
+	t.Run("Lenient", func(t *testing.T) {
+		_, wallet, corrupt := setup(t)
+		names := make([]string, 0)
+		errs := make([]*distributed.AccountResult, 0)
+		for res := range wallet.(distributed.WalletAccountsIterator).IterateAccounts(ctx) {
+			if res.Err != nil {
+				errs = append(errs, res)
+				continue
+			}
+			names = append(names, res.Account.Name())
+		}
+		require.Equal(t, []string{"Account 1"}, names)
+		require.Len(t, errs, 1)
+		require.ErrorIs(t, errs[0].Err, distributed.ErrCorruptData)
+		require.Equal(t, corrupt.ID(), errs[0].ID)
+		require.Equal(t, "Account 2", errs[0].Name)
+	})
+
+	t.Run("Strict", func(t *testing.T) {
+		_, wallet, _ := setup(t)
+		var last *distributed.AccountResult
+		for res := range wallet.(distributed.WalletAccountsIterator).IterateAccounts(ctx, distributed.WithStrict()) {
+			require.Nil(t, last, "result received after error")
+			if res.Err != nil {
+				last = res
+			}
+		}
+		require.NotNil(t, last)
+		require.ErrorIs(t, last.Err, distributed.ErrCorruptData)
+	})
+
+	t.Run("Accounts", func(t *testing.T) {
+		_, wallet, _ := setup(t)
+		names := make([]string, 0)
+		for account := range wallet.Accounts(ctx) {
+			names = append(names, account.Name())
+		}
+		require.Equal(t, []string{"Account 1"}, names)
+	})
+
+	t.Run("CorruptBatch", func(t *testing.T) {
+		store, wallet, _ := setup(t)
+		require.NoError(t, store.(e2wtypes.BatchStorer).StoreBatch(ctx, wallet.ID(), wallet.Name(), []byte(`{"version":1,"entries":[{}]}`)))
+		wallet, err := distributed.OpenWallet(ctx, "test wallet", store, keystorev4.New())
+		require.NoError(t, err)
+		errs := 0
+		names := make([]string, 0)
+		for res := range wallet.(distributed.WalletAccountsIterator).IterateAccounts(ctx) {
+			if res.Err != nil {
+				errs++
+				continue
+			}
+			names = append(names, res.Account.Name())
+		}
+		// Both the batch and the corrupt account are reported, and the
+		// good account is still provided from the store.
+		require.Equal(t, 2, errs)
+		require.Equal(t, []string{"Account 1"}, names)
+	})
+}
+
+func TestIterateAccountsOrdered(t *testing.T) {
+	ctx := context.Background()
+	store := scratch.New()
+	wallet, err := distributed.CreateWallet(ctx, "test wallet", store, keystorev4.New())
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+	names := []string{"Account 3", "Account 1", "Account 5", "Account 2", "Account 4"}
+	ids := make([]string, 0, len(names))
+	for _, name := range names {
+		account, err := wallet.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+			name,
+			_byteArray("01e748d098d3bcb477d636f19d510399ae18205fadf9814ee67052f88c1f77c0"),
+			3,
+			[][]byte{
+				_byteArray("a0633864987df6f7a0f40fbecbe0d15fe5317c00adccc0b816266bcf3d3d1ab6a365b3d79461b5da5a8ea09e37644731"),
+				_byteArray("a7430ae4717f511e473ed87a6510c5dfdbf289b7c7c4e083270da487aa146b0291ee701e0c38033aa157a612b8fd488b"),
+				_byteArray("b5e95fbcf45c9f2730f04abab22672dddca4b10e32467ab8522d7d153a382c9656d28268b5e75361e4e39a5d12513d8c"),
+			},
+			map[uint64]string{1: "foo", 2: "bar", 3: "baz"},
+			[]byte("pass"))
+		require.NoError(t, err)
+		ids = append(ids, account.ID().String())
+	}
+	sortedNames := []string{"Account 1", "Account 2", "Account 3", "Account 4", "Account 5"}
+	sort.Strings(ids)
+
+	// list lists the names and IDs of accounts provided by the iterator.
+	list := func(t *testing.T, wallet e2wtypes.Wallet, opts ...distributed.IterateOption) ([]string, []string) {
+		t.Helper()
+		names := make([]string, 0)
+		ids := make([]string, 0)
+		for res := range wallet.(distributed.WalletAccountsIterator).IterateAccounts(ctx, opts...) {
+			require.NoError(t, res.Err)
+			names = append(names, res.Account.Name())
+			ids = append(ids, res.Account.ID().String())
+		}
+
+		return names, ids
+	}
+
+	t.Run("ByName", func(t *testing.T) {
+		res, _ := list(t, wallet, distributed.WithOrder(distributed.OrderByName))
+		require.Equal(t, sortedNames, res)
+	})
+
+	t.Run("ByID", func(t *testing.T) {
+		_, res := list(t, wallet, distributed.WithOrder(distributed.OrderByID))
+		require.Equal(t, ids, res)
+	})
+
+	t.Run("Paged", func(t *testing.T) {
+		res, _ := list(t, wallet, distributed.WithOrder(distributed.OrderByName), distributed.WithOffset(1), distributed.WithLimit(2))
+		require.Equal(t, sortedNames[1:3], res)
+		res, _ = list(t, wallet, distributed.WithOrder(distributed.OrderByName), distributed.WithOffset(3), distributed.WithLimit(5))
+		require.Equal(t, sortedNames[3:], res)
+		res, _ = list(t, wallet, distributed.WithOrder(distributed.OrderByName), distributed.WithOffset(5))
+		require.Empty(t, res)
+	})
+
+	t.Run("PagedUnordered", func(t *testing.T) {
+		res, _ := list(t, wallet, distributed.WithLimit(2))
+		require.Len(t, res, 2)
+		res, _ = list(t, wallet, distributed.WithOffset(4))
+		require.Len(t, res, 1)
+	})
+
+	t.Run("NegativePaging", func(t *testing.T) {
+		for _, order := range []distributed.AccountOrder{distributed.OrderNone, distributed.OrderByName} {
+			results := make([]*distributed.AccountResult, 0)
+			for res := range wallet.(distributed.WalletAccountsIterator).IterateAccounts(ctx, distributed.WithOrder(order), distributed.WithOffset(-1)) {
+				results = append(results, res)
+			}
+			require.Len(t, results, 1)
+			require.EqualError(t, results[0].Err, "offset -1 is negative")
+
+			results = make([]*distributed.AccountResult, 0)
+			for res := range wallet.(distributed.WalletAccountsIterator).IterateAccounts(ctx, distributed.WithOrder(order), distributed.WithLimit(-1)) {
+				results = append(results, res)
+			}
+			require.Len(t, results, 1)
+			require.EqualError(t, results[0].Err, "limit -1 is negative")
+		}
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		require.NoError(t, wallet.(e2wtypes.WalletBatchCreator).BatchWallet(ctx, []string{"pass"}, "batch"))
+		batchWallet, err := distributed.OpenWallet(ctx, "test wallet", store, keystorev4.New())
+		require.NoError(t, err)
+		res, _ := list(t, batchWallet, distributed.WithOrder(distributed.OrderByName), distributed.WithOffset(2), distributed.WithLimit(2))
+		require.Equal(t, sortedNames[2:4], res)
+	})
+}
@@ -18,11 +18,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	e2types "github.com/wealdtech/go-eth2-types/v2"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
 	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
 )
 
@@ -31,14 +32,17 @@ type batchEntry struct {
 	name               string
 	verificationVector [][]byte
 	signingThreshold   uint32
-	participants       map[string]string
+	participants       map[uint64]*Participant
 	pubkey             []byte
+	watchOnly          bool
+	metadata           map[string]string
 }
 
 type batch struct {
-	entries   []*batchEntry
-	crypto    map[string]any
-	encryptor e2wtypes.Encryptor
+	entries    []*batchEntry
+	crypto     map[string]any
+	encryptor  e2wtypes.Encryptor
+	keyWrapper KeyWrapper
 }
 
 // BatchWallet encrypts all accounts in to a single file, allowing for faster
@@ -57,11 +61,22 @@ func (w *wallet) BatchWallet(ctx context.Context, passphrases []string, batchPas
 	// Obtain and decrypt individual accounts directly from store.
 	for data := range w.store.RetrieveAccounts(w.ID()) {
 		if account, err := deserializeAccount(w, data); err == nil {
+			if account.watchOnly {
+				// Watch-only accounts have no secret key to decrypt.
+				accounts = append(accounts, account)
+
+				continue
+			}
 			unlocked := false
-			for _, passphrase := range passphrases {
-				if err := account.Unlock(ctx, []byte(passphrase)); err == nil {
-					unlocked = true
-					break
+			if account.keyWrapper != nil {
+				// Wrapped accounts do not require a passphrase.
+				unlocked = account.Unlock(ctx, nil) == nil
+			} else {
+				for _, passphrase := range passphrases {
+					if err := account.Unlock(ctx, []byte(passphrase)); err == nil {
+						unlocked = true
+						break
+					}
 				}
 			}
 			if !unlocked {
@@ -75,34 +90,34 @@ func (w *wallet) BatchWallet(ctx context.Context, passphrases []string, batchPas
 	batchEntries := make([]*batchEntry, len(accounts))
 	secretKeys := make([]byte, 0, 32*len(accounts))
 	for i, account := range accounts {
-		verificationVector := make([][]byte, 0, len(account.verificationVector))
-		for _, v := range account.verificationVector {
-			verificationVector = append(verificationVector, v.Marshal())
-		}
-		participants := make(map[string]string, len(account.participants))
-		for k, v := range account.participants {
-			participants[fmt.Sprintf("%d", k)] = v
-		}
 		batchEntries[i] = &batchEntry{
 			id:                 account.id,
 			name:               account.name,
-			verificationVector: verificationVector,
+			verificationVector: account.storedVerificationVector(),
 			signingThreshold:   account.signingThreshold,
-			participants:       participants,
+			participants:       copyParticipants(account.participants),
 			pubkey:             account.publicKey.Marshal(),
+			watchOnly:          account.watchOnly,
+			metadata:           copyMetadata(account.metadata),
+		}
+		if account.watchOnly {
+			// Keep the secret keys aligned with the entries.
+			secretKeys = append(secretKeys, make([]byte, 32)...)
+		} else {
+			secretKeys = append(secretKeys, account.secretKey.Marshal()...)
 		}
-		secretKeys = append(secretKeys, account.secretKey.Marshal()...)
 	}
 
-	crypto, err := w.encryptor.Encrypt(secretKeys, batchPassphrase)
+	crypto, err := w.encryptSecret(ctx, secretKeys, batchPassphrase)
 	if err != nil {
 		return errors.Wrap(err, "failed to encrypt batch")
 	}
 
 	data := &batch{
-		entries:   batchEntries,
-		crypto:    crypto,
-		encryptor: w.encryptor,
+		entries:    batchEntries,
+		crypto:     crypto,
+		encryptor:  w.encryptor,
+		keyWrapper: w.keyWrapper,
 	}
 	batch, err := json.Marshal(data)
 	if err != nil {
@@ -115,76 +130,241 @@ func (w *wallet) BatchWallet(ctx context.Context, passphrases []string, batchPas
 	return nil
 }
 
-// retrieveAccountsBatch retrieves the batched accounts for a wallet.
+// dropBatch replaces any batch in the store with an empty batch.  This is used when stored accounts
+// change in a way that the batch cannot follow without the passphrases of the accounts; accounts are
+// then obtained from the store until BatchWallet is called again.
+func (w *wallet) dropBatch(ctx context.Context) error {
+	batchRetriever, isBatchRetriever := w.store.(e2wtypes.BatchRetriever)
+	if !isBatchRetriever {
+		return nil
+	}
+	batchStorer, isBatchStorer := w.store.(e2wtypes.BatchStorer)
+	if !isBatchStorer {
+		return nil
+	}
+
+	w.batchMutex.Lock()
+	defer w.batchMutex.Unlock()
+
+	existing, err := batchRetriever.RetrieveBatch(ctx, w.id)
+	if err != nil {
+		// No batch to drop.
+		return nil
+	}
+	// The empty batch holds no secrets, so it keeps the protection of the batch that it replaces
+	// rather than relying on the wallet's encryptor, which is not required by wallets that wrap keys.
+	protection := &struct {
+		Encryptor  string `json:"encryptor"`
+		KeyWrapper string `json:"key_wrapper"`
+	}{}
+	if err := json.Unmarshal(existing, protection); err != nil || (protection.Encryptor == "" && protection.KeyWrapper == "") {
+		// The existing batch is unreadable; an empty batch is never decrypted, so any supported encryptor will do.
+		protection.Encryptor = keystorev4.New().String()
+		protection.KeyWrapper = ""
+	}
+	data, err := json.Marshal(&batchJSON{
+		Entries:    make([]*batchEntry, 0),
+		Encryptor:  protection.Encryptor,
+		KeyWrapper: protection.KeyWrapper,
+		Version:    version,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal batch")
+	}
+	if err := batchStorer.StoreBatch(ctx, w.id, w.name, data); err != nil {
+		return errors.Wrap(err, "failed to store batch")
+	}
+
+	// Accounts from the old batch may be stale, so obtain them from the store from now on.
+	if w.batch != nil {
+		for _, entry := range w.batch.entries {
+			delete(w.accounts, entry.id)
+		}
+	}
+	w.batch = nil
+	w.batchDecrypted = false
+	w.batchRetrieved = false
+
+	return nil
+}
+
+// WalletBatchRefresher is the interface for wallets that can refresh their batch from the store.
+type WalletBatchRefresher interface {
+	// RefreshBatch reloads the wallet's batch from the store.
+	RefreshBatch(ctx context.Context) error
+	// BatchError returns the error from the most recent attempt to retrieve the batch, if any.
+	BatchError() error
+}
+
+// RefreshBatch reloads the wallet's batch from the store.
+// If the refresh fails any previously-retrieved batch remains in use.
+// Accounts obtained from a replaced batch must be unlocked again.
+func (w *wallet) RefreshBatch(ctx context.Context) error {
+	if _, isBatchRetriever := w.store.(e2wtypes.BatchRetriever); !isBatchRetriever {
+		return fmt.Errorf("store %s cannot retrieve batches", w.store.Name())
+	}
+
+	w.batchMutex.Lock()
+	err := w.loadBatch(ctx)
+	w.batchMutex.Unlock()
+	if err != nil {
+		return err
+	}
+	// Accounts have been replaced, so rebuild the key index on next use.
+	// This is carried out without holding the batch mutex, as building the
+	// key index can require the batch.
+	w.resetAccountKeys()
+
+	return nil
+}
+
+// BatchError returns the error from the most recent attempt to retrieve the batch, if any.
+// Note that an error is also returned if the wallet does not have a batch.
+func (w *wallet) BatchError() error {
+	w.batchMutex.Lock()
+	defer w.batchMutex.Unlock()
+
+	return w.batchErr
+}
+
+// retrieveAccountsBatch retrieves the batched accounts for a wallet, if
+// they have not already been retrieved.
 func (w *wallet) retrieveAccountsBatch(ctx context.Context) error {
 	w.batchMutex.Lock()
 	defer w.batchMutex.Unlock()
 
+	if w.batchRetrieved {
+		// The batch has already been retrieved, possibly whilst we were
+		// waiting for the lock.
+		return w.batchErr
+	}
+
+	return w.loadBatch(ctx)
+}
+
+// loadBatch loads the batch from the store according to the wallet's retry
+// policy, and creates accounts from it.
+// This assumes that the batch mutex is held.
+func (w *wallet) loadBatch(ctx context.Context) error {
+	res, accounts, err := w.fetchBatch(ctx)
+	for attempt := 1; err != nil && attempt < w.batchRetryAttempts && !finalBatchError(err); attempt++ {
+		if waitErr := sleepWithContext(ctx, w.batchRetryInterval); waitErr != nil {
+			err = errors.Wrap(waitErr, "failed to retrieve batch")
+
+			break
+		}
+		res, accounts, err = w.fetchBatch(ctx)
+	}
+	w.batchErr = err
+	// A cancelled retrieval does not count, so the batch will be retrieved again on next use.
+	w.batchRetrieved = ctx.Err() == nil
+	if err != nil {
+		return err
+	}
+
+	// Remove accounts from any previous batch before adding the new ones.
 	if w.batch != nil {
-		// The batch has been retrieved whilst we were waiting for the lock.
-		return nil
+		for _, entry := range w.batch.entries {
+			delete(w.accounts, entry.id)
+		}
+	}
+	w.batch = res
+	w.batchDecrypted = false
+	for id, account := range accounts {
+		w.accounts[id] = account
 	}
 
-	// Place a marker on the batch so that if we error out we don't
-	// keep coming back and trying again.
-	w.batch = &batch{}
+	return nil
+}
+
+// finalBatchError returns true if the error from obtaining a batch will not change on retry.
+func finalBatchError(err error) bool {
+	return reportableBatchError(err) || errors.Is(err, ErrNotFound)
+}
+
+// sleepWithContext waits for the given duration, returning early with an error
+// if the context is done.
+func sleepWithContext(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
 
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// fetchBatch fetches the batch from the store and creates accounts from it.
+func (w *wallet) fetchBatch(ctx context.Context) (*batch, map[uuid.UUID]*account, error) {
 	batchRetriever, isBatchRetriever := w.store.(e2wtypes.BatchRetriever)
 	if !isBatchRetriever {
-		return errors.New("not a batch retriever")
+		return nil, nil, errors.New("not a batch retriever")
 	}
 
 	serializedBatch, err := batchRetriever.RetrieveBatch(ctx, w.id)
 	if err != nil {
-		return errors.Wrap(err, "failed to retrieve batch")
+		if missingBatchError(err) {
+			return nil, nil, newNotFoundError("batch", w.name, err, "failed to retrieve batch")
+		}
+
+		return nil, nil, errors.Wrap(err, "failed to retrieve batch")
+	}
+	res := &batch{
+		keyWrapper: w.keyWrapper,
 	}
-	res := &batch{}
 	if err := json.Unmarshal(serializedBatch, res); err != nil {
-		return errors.Wrap(err, "failed to unmarshal batch")
+		return nil, nil, unmarshalError(err, "failed to unmarshal batch")
 	}
-	w.batch = res
 
 	// Create individual accounts from the batch.
+	accounts := make(map[uuid.UUID]*account, len(res.entries))
 	for i := range res.entries {
+		if w.index != nil && !w.index.IDKnown(res.entries[i].id) {
+			// Account has been removed from the wallet since the batch was created.
+			continue
+		}
 		publicKey, err := e2types.BLSPublicKeyFromBytes(res.entries[i].pubkey)
 		if err != nil {
-			return errors.Wrap(err, "invalid public key")
+			return nil, nil, newCorruptDataError(err, "invalid public key")
 		}
 		verificationVector := make([]e2types.PublicKey, len(res.entries[i].verificationVector))
 		for j, v := range res.entries[i].verificationVector {
 			verificationVector[j], err = e2types.BLSPublicKeyFromBytes(v)
 			if err != nil {
-				return errors.Wrapf(err, "invalid verification vector %d", j)
+				return nil, nil, newCorruptDataError(err, fmt.Sprintf("invalid verification vector %d", j))
 			}
 		}
-		participants := make(map[uint64]string, len(res.entries[i].participants))
-		for k, v := range res.entries[i].participants {
-			id, err := strconv.ParseUint(k, 10, 64)
+		publicShares := len(verificationVector) == 0
+		if publicShares {
+			verificationVector, err = verificationVectorFromParticipants(res.entries[i].signingThreshold, res.entries[i].participants)
 			if err != nil {
-				return errors.Wrap(err, "invalid participant ID")
+				return nil, nil, newCorruptDataError(err, "invalid public shares")
 			}
-			participants[id] = v
 		}
-		account := &account{
+		accounts[res.entries[i].id] = &account{
 			id:   res.entries[i].id,
 			name: res.entries[i].name,
 			// We do not populate crypto, as the secret is in the batch.
 			verificationVector: verificationVector,
+			publicShares:       publicShares,
 			signingThreshold:   res.entries[i].signingThreshold,
-			participants:       participants,
+			participants:       res.entries[i].participants,
+			watchOnly:          res.entries[i].watchOnly,
 			publicKey:          publicKey,
 			version:            version,
 			wallet:             w,
 			encryptor:          w.encryptor,
+			metadata:           res.entries[i].metadata,
 		}
-		w.accounts[account.id] = account
 	}
 
-	return nil
+	return res, accounts, nil
 }
 
 // batchDecrypt decrypts a batch of accounts.
-func (w *wallet) batchDecrypt(_ context.Context, passphrase []byte) error {
+func (w *wallet) batchDecrypt(ctx context.Context, passphrase []byte) error {
 	w.batchMutex.Lock()
 	defer w.batchMutex.Unlock()
 
@@ -197,27 +377,59 @@ func (w *wallet) batchDecrypt(_ context.Context, passphrase []byte) error {
 		return errors.New("no batch to decrypt")
 	}
 
-	secretBytes, err := w.encryptor.Decrypt(w.batch.crypto, string(passphrase))
+	secretBytes, err := w.batch.decrypt(ctx, passphrase)
 	if err != nil {
-		return errors.Wrap(err, "failed to decrypt data")
+		return err
 	}
 	for i := range w.batch.entries {
-		if w.accounts[w.batch.entries[i].id].secretKey != nil {
-			// Already have this key.
+		acc, exists := w.accounts[w.batch.entries[i].id]
+		if !exists {
+			// Account has been removed from the wallet.
+			continue
+		}
+		if acc.secretKey != nil || acc.watchOnly {
+			// Already have this key, or there is no key.
 			continue
 		}
 		secretKey, err := e2types.BLSPrivateKeyFromBytes(secretBytes[i*32 : (i+1)*32])
 		if err != nil {
-			return errors.Wrap(err, "invalid private key")
+			return newCorruptDataError(err, "invalid private key")
 		}
 		publicKey := secretKey.PublicKey()
-		if !bytes.Equal(publicKey.Marshal(), w.accounts[w.batch.entries[i].id].publicKey.Marshal()) {
-			return errors.New("secret key does not correspond to public key")
+		if !bytes.Equal(publicKey.Marshal(), acc.publicKey.Marshal()) {
+			return newCorruptDataError(nil, "secret key does not correspond to public key")
 		}
-		w.accounts[w.batch.entries[i].id].secretKey = secretKey
+		acc.secretKey = secretKey
 	}
 
 	w.batchDecrypted = true
 
 	return nil
 }
+
+// decrypt decrypts the secret keys of the batch.
+// Errors are returned as ErrWrongPassphrase only if the passphrase is incorrect.
+func (b *batch) decrypt(ctx context.Context, passphrase []byte) ([]byte, error) {
+	var secretBytes []byte
+	var err error
+	if b.keyWrapper != nil {
+		secretBytes, err = b.keyWrapper.Unwrap(ctx, b.crypto)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to unwrap batch")
+		}
+	} else {
+		secretBytes, err = b.encryptor.Decrypt(b.crypto, string(passphrase))
+		if err != nil {
+			if incorrectPassphrase(err) {
+				return nil, newWrongPassphraseError(nil, "incorrect batch passphrase")
+			}
+
+			return nil, newCorruptDataError(err, "failed to decrypt batch")
+		}
+	}
+	if len(secretBytes) != 32*len(b.entries) {
+		return nil, newCorruptDataError(nil, "batch secret keys do not match entries")
+	}
+
+	return secretBytes, nil
+}
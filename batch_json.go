@@ -26,12 +26,14 @@ import (
 )
 
 type batchEntryJSON struct {
-	UUID               uuid.UUID         `json:"uuid"`
-	Name               string            `json:"name"`
-	VerificationVector []string          `json:"verification_vector"`
-	SigningThreshold   string            `json:"signing_threshold"`
-	Participants       map[string]string `json:"participants"`
-	Pubkey             string            `json:"pubkey"`
+	UUID               uuid.UUID               `json:"uuid"`
+	Name               string                  `json:"name"`
+	VerificationVector []string                `json:"verification_vector,omitempty"`
+	SigningThreshold   string                  `json:"signing_threshold"`
+	Participants       map[string]*Participant `json:"participants"`
+	Pubkey             string                  `json:"pubkey"`
+	WatchOnly          bool                    `json:"watch_only,omitempty"`
+	Metadata           map[string]string       `json:"metadata,omitempty"`
 }
 
 func (b *batchEntry) MarshalJSON() ([]byte, error) {
@@ -45,8 +47,10 @@ func (b *batchEntry) MarshalJSON() ([]byte, error) {
 		Name:               b.name,
 		VerificationVector: verificationVector,
 		SigningThreshold:   fmt.Sprintf("%d", b.signingThreshold),
-		Participants:       b.participants,
+		Participants:       participantsJSON(b.participants),
 		Pubkey:             fmt.Sprintf("%x", b.pubkey),
+		WatchOnly:          b.watchOnly,
+		Metadata:           b.metadata,
 	})
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to marshal JSON")
@@ -75,7 +79,12 @@ func (b *batchEntry) UnmarshalJSON(input []byte) error {
 		return errors.Wrap(err, "failed to parse signing threshold")
 	}
 	b.signingThreshold = uint32(signingThreshold)
-	b.participants = data.Participants
+	b.participants, err = participantsFromJSON(data.Participants)
+	if err != nil {
+		return err
+	}
+	b.watchOnly = data.WatchOnly
+	b.metadata = data.Metadata
 	b.pubkey, err = hex.DecodeString(strings.TrimPrefix(data.Pubkey, "0x"))
 	if err != nil {
 		return errors.Wrap(err, "invalid pubkey")
@@ -85,19 +94,25 @@ func (b *batchEntry) UnmarshalJSON(input []byte) error {
 }
 
 type batchJSON struct {
-	Entries   []*batchEntry  `json:"entries"`
-	Crypto    map[string]any `json:"crypto"`
-	Encryptor string         `json:"encryptor"`
-	Version   int            `json:"version"`
+	Entries    []*batchEntry  `json:"entries"`
+	Crypto     map[string]any `json:"crypto"`
+	Encryptor  string         `json:"encryptor,omitempty"`
+	KeyWrapper string         `json:"key_wrapper,omitempty"`
+	Version    int            `json:"version"`
 }
 
 func (b *batch) MarshalJSON() ([]byte, error) {
-	res, err := json.Marshal(&batchJSON{
-		Entries:   b.entries,
-		Crypto:    b.crypto,
-		Encryptor: b.encryptor.String(),
-		Version:   version,
-	})
+	data := &batchJSON{
+		Entries: b.entries,
+		Crypto:  b.crypto,
+		Version: version,
+	}
+	if b.keyWrapper != nil {
+		data.KeyWrapper = b.keyWrapper.Name()
+	} else {
+		data.Encryptor = b.encryptor.String()
+	}
+	res, err := json.Marshal(data)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to marshal JSON")
 	}
@@ -111,16 +126,25 @@ func (b *batch) UnmarshalJSON(input []byte) error {
 		return errors.Wrap(err, "invalid JSON")
 	}
 	if data.Version != version {
-		return fmt.Errorf("unsupported version %d", data.Version)
+		return newUnsupportedVersionError(uint(data.Version), fmt.Sprintf("unsupported version %d", data.Version))
 	}
 	b.entries = data.Entries
+	b.crypto = data.Crypto
+	if data.KeyWrapper != "" {
+		if b.keyWrapper == nil || b.keyWrapper.Name() != data.KeyWrapper {
+			return &unavailableKeyWrapperError{msg: fmt.Sprintf("batch key wrapper %q unavailable", data.KeyWrapper)}
+		}
+
+		return nil
+	}
+	// Not a wrapped batch, so ensure any key wrapper is not used.
+	b.keyWrapper = nil
 	switch data.Encryptor {
 	case "keystorev4":
 		b.encryptor = keystorev4.New()
 	default:
 		return fmt.Errorf("unsupported encryptor %s", data.Encryptor)
 	}
-	b.crypto = data.Crypto
 
 	return nil
 }
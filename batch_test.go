@@ -15,11 +15,15 @@ package distributed_test
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
 	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	filesystem "github.com/wealdtech/go-eth2-wallet-store-filesystem"
 	scratch "github.com/wealdtech/go-eth2-wallet-store-scratch"
 	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
 )
@@ -157,3 +161,131 @@ func TestBatch(t *testing.T) {
 	}
 	require.Equal(t, 3, numAccounts)
 }
+
+// flakyBatchStore is a store that fails to retrieve batches a given number of times.
+type flakyBatchStore struct {
+	e2wtypes.Store
+	failures   int
+	retrievals int
+}
+
+func (s *flakyBatchStore) StoreBatch(ctx context.Context, walletID uuid.UUID, walletName string, data []byte) error {
+	return s.Store.(e2wtypes.BatchStorer).StoreBatch(ctx, walletID, walletName, data)
+}
+
+func (s *flakyBatchStore) RetrieveBatch(ctx context.Context, walletID uuid.UUID) ([]byte, error) {
+	s.retrievals++
+	if s.failures > 0 {
+		s.failures--
+
+		return nil, errors.New("transient failure")
+	}
+
+	return s.Store.(e2wtypes.BatchRetriever).RetrieveBatch(ctx, walletID)
+}
+
+func TestBatchRetry(t *testing.T) {
+	ctx := context.Background()
+	store := &flakyBatchStore{Store: scratch.New()}
+	encryptor := keystorev4.New()
+
+	wallet, err := distributed.CreateWallet(ctx, "test wallet", store, encryptor)
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+	_, err = wallet.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+		"account 1",
+		_byteArray("0a660b6379a25e095590edeb7688a8506653e58310336efcfc98a9e34e485faa"),
+		3,
+		[][]byte{
+			_byteArray("b5d7a0bffb025cca463898a7ff56a613402e40d43ee293b45ee9f7811c17047a43273a0cf843d75995d1150140f6b2ef"),
+			_byteArray("b82aa608cd126ff401a458be48944dc84c999cce084fd6c8da816e5548964fc1d71b05d52c528e5ce3657778c573cc31"),
+			_byteArray("a4da59f92bea77d3950cb578c2b8c8ee65e12040e9efd4c82cb4b0ac6138fef5d8f4bb53971bafdf6285f22f91b22b2f"),
+		},
+		map[uint64]string{1: "foo", 2: "bar", 3: "baz"},
+		[]byte("account passphrase"))
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletBatchCreator).BatchWallet(ctx, []string{"account passphrase"}, "batch passphrase"))
+
+	// batchUnlock returns the error from unlocking the account with the batch passphrase,
+	// which only succeeds if the account was obtained from the batch.
+	batchUnlock := func(t *testing.T, wallet e2wtypes.Wallet) error {
+		t.Helper()
+		account, err := wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "account 1")
+		require.NoError(t, err)
+
+		return account.(e2wtypes.AccountLocker).Unlock(ctx, []byte("batch passphrase"))
+	}
+
+	t.Run("Refresh", func(t *testing.T) {
+		store.failures = 1
+		wallet, err := distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+		require.NoError(t, err)
+		require.Error(t, batchUnlock(t, wallet))
+		require.EqualError(t, wallet.(distributed.WalletBatchRefresher).BatchError(), "failed to retrieve batch: transient failure")
+
+		require.NoError(t, wallet.(distributed.WalletBatchRefresher).RefreshBatch(ctx))
+		require.NoError(t, wallet.(distributed.WalletBatchRefresher).BatchError())
+		require.NoError(t, batchUnlock(t, wallet))
+	})
+
+	t.Run("RefreshFailure", func(t *testing.T) {
+		store.failures = 0
+		wallet, err := distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+		require.NoError(t, err)
+		require.NoError(t, batchUnlock(t, wallet))
+
+		// A failed refresh keeps the existing batch.
+		store.failures = 1
+		require.Error(t, wallet.(distributed.WalletBatchRefresher).RefreshBatch(ctx))
+		require.Error(t, wallet.(distributed.WalletBatchRefresher).BatchError())
+		numAccounts := 0
+		for range wallet.Accounts(ctx) {
+			numAccounts++
+		}
+		require.Equal(t, 1, numAccounts)
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		store.failures = 2
+		wallet, err := distributed.OpenWallet(ctx, "test wallet", store, encryptor, distributed.WithBatchRetry(3, time.Millisecond))
+		require.NoError(t, err)
+		require.NoError(t, batchUnlock(t, wallet))
+		require.NoError(t, wallet.(distributed.WalletBatchRefresher).BatchError())
+	})
+
+	t.Run("RetryExhausted", func(t *testing.T) {
+		store.failures = 3
+		wallet, err := distributed.OpenWallet(ctx, "test wallet", store, encryptor, distributed.WithBatchRetry(3, time.Millisecond))
+		require.NoError(t, err)
+		require.Error(t, batchUnlock(t, wallet))
+		require.Error(t, wallet.(distributed.WalletBatchRefresher).BatchError())
+	})
+
+	t.Run("NoBatch", func(t *testing.T) {
+		store.failures = 0
+		_, err := distributed.CreateWallet(ctx, "unbatched wallet", store, encryptor)
+		require.NoError(t, err)
+		wallet, err := distributed.OpenWallet(ctx, "unbatched wallet", store, encryptor, distributed.WithBatchRetry(3, time.Millisecond))
+		require.NoError(t, err)
+
+		// A missing batch is not retried.
+		store.retrievals = 0
+		for range wallet.Accounts(ctx) {
+		}
+		require.Equal(t, 1, store.retrievals)
+		require.ErrorIs(t, wallet.(distributed.WalletBatchRefresher).BatchError(), distributed.ErrNotFound)
+	})
+
+	t.Run("NoBatchFilesystem", func(t *testing.T) {
+		store := &flakyBatchStore{Store: filesystem.New(filesystem.WithLocation(t.TempDir()))}
+		_, err := distributed.CreateWallet(ctx, "unbatched wallet", store, encryptor)
+		require.NoError(t, err)
+		wallet, err := distributed.OpenWallet(ctx, "unbatched wallet", store, encryptor, distributed.WithBatchRetry(3, time.Millisecond))
+		require.NoError(t, err)
+
+		for range wallet.Accounts(ctx) {
+		}
+		require.Equal(t, 1, store.retrievals)
+		require.ErrorIs(t, wallet.(distributed.WalletBatchRefresher).BatchError(), distributed.ErrNotFound)
+	})
+}
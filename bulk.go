@@ -0,0 +1,432 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// ImportEntry describes a distributed account to be imported in bulk.
+type ImportEntry struct {
+	// Source describes where the entry was obtained, for example the name of its file.
+	Source string
+	// Name is the name of the account.
+	Name string
+	// SecretShare is this participant's share of the account's secret key.
+	// If empty the account is imported as watch-only.
+	SecretShare []byte
+	// SigningThreshold is the number of participants required to sign.
+	SigningThreshold uint32
+	// VerificationVector is the verification vector of the account.
+	VerificationVector [][]byte
+	// Participants are the participants in the account, keyed by participant ID.
+	Participants map[uint64]*Participant
+}
+
+// ImportResult is the result of importing a single entry in bulk.
+type ImportResult struct {
+	// Source is the source of the entry.
+	Source string
+	// Name is the name of the account.
+	Name string
+	// Account is the imported account, if the import succeeded.
+	Account e2wtypes.Account
+	// Err is the error encountered when importing the entry, if the import failed.
+	Err error
+}
+
+// BulkImportOption is an option for bulk imports.
+type BulkImportOption interface {
+	apply(*bulkImportOptions)
+}
+
+type bulkImportOptions struct {
+	concurrency int
+}
+
+type bulkImportOptionFunc func(*bulkImportOptions)
+
+func (f bulkImportOptionFunc) apply(o *bulkImportOptions) {
+	f(o)
+}
+
+// WithConcurrency sets the number of accounts encrypted and stored concurrently.
+// Defaults to the number of CPUs.
+func WithConcurrency(concurrency int) BulkImportOption {
+	return bulkImportOptionFunc(func(o *bulkImportOptions) {
+		o.concurrency = concurrency
+	})
+}
+
+// WalletDistributedAccountsImporter is the interface for wallets that can import distributed accounts in bulk.
+type WalletDistributedAccountsImporter interface {
+	// ImportDistributedAccounts creates new distributed accounts in the wallet from the supplied entries,
+	// returning a result for each entry.
+	ImportDistributedAccounts(ctx context.Context,
+		entries []*ImportEntry,
+		passphrase []byte,
+		opts ...BulkImportOption,
+	) (
+		[]*ImportResult,
+		error,
+	)
+}
+
+// importEntryJSON is the JSON and YAML representation of an import entry.
+type importEntryJSON struct {
+	Name               string         `json:"name"                yaml:"name"`
+	SecretShare        string         `json:"secret_share"        yaml:"secret_share"`
+	SigningThreshold   uint32         `json:"signing_threshold"   yaml:"signing_threshold"`
+	VerificationVector []string       `json:"verification_vector" yaml:"verification_vector"`
+	Participants       map[string]any `json:"participants"        yaml:"participants"`
+}
+
+// importManifestJSON is the JSON and YAML representation of an import manifest.
+type importManifestJSON struct {
+	Accounts []*importEntryJSON `json:"accounts" yaml:"accounts"`
+}
+
+// unmarshalJSONOrYAML unmarshals data that is either JSON or YAML.
+func unmarshalJSONOrYAML(data []byte, out any) error {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return json.Unmarshal(trimmed, out)
+	}
+
+	return yaml.Unmarshal(trimmed, out)
+}
+
+// importEntry obtains an import entry from its JSON or YAML representation.
+func (e *importEntryJSON) importEntry() (*ImportEntry, error) {
+	entry := &ImportEntry{
+		Name:             e.Name,
+		SigningThreshold: e.SigningThreshold,
+	}
+	var err error
+	entry.SecretShare, err = hex.DecodeString(strings.TrimPrefix(e.SecretShare, "0x"))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid secret share")
+	}
+	entry.VerificationVector = make([][]byte, len(e.VerificationVector))
+	for i := range e.VerificationVector {
+		entry.VerificationVector[i], err = hex.DecodeString(strings.TrimPrefix(e.VerificationVector[i], "0x"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid verification vector entry %d", i)
+		}
+	}
+	entry.Participants = make(map[uint64]*Participant, len(e.Participants))
+	for k, v := range e.Participants {
+		id, err := strconv.ParseUint(k, 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid participant ID")
+		}
+		entry.Participants[id], err = participantFromData(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid participant %d", id)
+		}
+	}
+
+	return entry, nil
+}
+
+// ParseImportEntry parses a single import entry, for example the output of a distributed key
+// generation for one participant, in JSON or YAML format.
+func ParseImportEntry(data []byte) (*ImportEntry, error) {
+	entryJSON := &importEntryJSON{}
+	if err := unmarshalJSONOrYAML(data, entryJSON); err != nil {
+		return nil, errors.Wrap(err, "invalid format")
+	}
+
+	return entryJSON.importEntry()
+}
+
+// ParseImportManifest parses a manifest of import entries in JSON or YAML format.
+// The manifest is an object whose "accounts" field contains the entries.
+func ParseImportManifest(data []byte) ([]*ImportEntry, error) {
+	manifest := &importManifestJSON{}
+	if err := unmarshalJSONOrYAML(data, manifest); err != nil {
+		return nil, errors.Wrap(err, "invalid format")
+	}
+
+	entries := make([]*ImportEntry, len(manifest.Accounts))
+	for i, entryJSON := range manifest.Accounts {
+		if entryJSON == nil {
+			return nil, fmt.Errorf("entry %d missing", i+1)
+		}
+		entry, err := entryJSON.importEntry()
+		if err != nil {
+			return nil, errors.Wrapf(err, "entry %d", i+1)
+		}
+		entry.Source = fmt.Sprintf("entry %d", i+1)
+		entries[i] = entry
+	}
+
+	return entries, nil
+}
+
+// ReadImportDirectory reads import entries from the JSON and YAML files in a directory, one entry per
+// file, in order of file name.  Entries without a name are named after their file.
+func ReadImportDirectory(path string) ([]*ImportEntry, error) {
+	files, err := os.ReadDir(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read directory")
+	}
+
+	entries := make([]*ImportEntry, 0, len(files))
+	for _, file := range files {
+		ext := filepath.Ext(file.Name())
+		if file.IsDir() || (ext != ".json" && ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(path, file.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", file.Name())
+		}
+		entry, err := ParseImportEntry(data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s", file.Name())
+		}
+		entry.Source = file.Name()
+		if entry.Name == "" {
+			entry.Name = strings.TrimSuffix(file.Name(), ext)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// ImportDistributedAccounts creates new distributed accounts in the wallet from the supplied entries,
+// all protected by the same passphrase.  The wallet must be unlocked.
+//
+// Every entry is validated before any are imported; in addition to the checks made by
+// ImportDistributedAccountWithParticipants, each secret share must correspond to its verification
+// vector for one of the participants, and names and public keys must be unique across the entries.
+// Valid entries are then encrypted and stored concurrently, and the accounts index is stored once
+// when they are complete.  A result is returned for each entry, in the order supplied; invalid entries
+// do not prevent the valid entries from being imported.  An error is returned only if the import as a
+// whole fails, in which case accounts may have been stored without being added to the accounts index;
+// these can be recovered by calling Verify() with the WithRepair() option.
+func (w *wallet) ImportDistributedAccounts(ctx context.Context,
+	entries []*ImportEntry,
+	passphrase []byte,
+	opts ...BulkImportOption,
+) (
+	[]*ImportResult,
+	error,
+) {
+	options := &bulkImportOptions{
+		concurrency: runtime.NumCPU(),
+	}
+	for _, o := range opts {
+		o.apply(options)
+	}
+	if options.concurrency < 1 {
+		return nil, errors.New("concurrency must be at least 1")
+	}
+
+	unlocked, err := w.IsUnlocked(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to obtain wallet lock status")
+	}
+	if !unlocked {
+		return nil, newLockedError("wallet", w.name, "wallet must be unlocked to create accounts")
+	}
+
+	results := make([]*ImportResult, len(entries))
+	accounts := make([]*account, len(entries))
+	secrets := make([][]byte, len(entries))
+	names := make(map[string]string)
+	pubKeys := make(map[string]string)
+	for i, entry := range entries {
+		results[i] = &ImportResult{
+			Source: fmt.Sprintf("entry %d", i+1),
+		}
+		if entry == nil {
+			results[i].Err = errors.New("entry missing")
+
+			continue
+		}
+		if entry.Source != "" {
+			results[i].Source = entry.Source
+		}
+		results[i].Name = entry.Name
+
+		a, secret, err := w.newBulkAccount(ctx, entry)
+		if err != nil {
+			results[i].Err = err
+
+			continue
+		}
+		if source, exists := names[a.name]; exists {
+			results[i].Err = newAlreadyExistsError("account", a.name, "account name %q also used by %s", a.name, source)
+
+			continue
+		}
+		pubKey := a.publicKey.Marshal()
+		if source, exists := pubKeys[string(pubKey)]; exists {
+			results[i].Err = newAlreadyExistsError("account", a.name, "public key %#x also used by %s", pubKey, source)
+
+			continue
+		}
+		names[a.name] = results[i].Source
+		pubKeys[string(pubKey)] = results[i].Source
+		accounts[i] = a
+		secrets[i] = secret
+	}
+
+	w.storeBulkAccounts(ctx, accounts, secrets, passphrase, results, options.concurrency)
+
+	w.mutex.Lock()
+	stored := make([]*account, 0, len(accounts))
+	for i, a := range accounts {
+		if a != nil && results[i].Err == nil {
+			w.index.Add(a.id, a.name)
+			stored = append(stored, a)
+		}
+	}
+	if len(stored) > 0 {
+		if err := w.storeAccountsIndex(); err != nil {
+			for _, a := range stored {
+				w.index.Remove(a.id, a.name)
+			}
+			w.mutex.Unlock()
+
+			return nil, errors.Wrap(err, "failed to store account index")
+		}
+	}
+	for _, a := range stored {
+		w.accounts[a.id] = a
+	}
+	w.mutex.Unlock()
+
+	for i, a := range accounts {
+		if a != nil && results[i].Err == nil {
+			w.addAccountKeys(a)
+			results[i].Account = a
+		}
+	}
+
+	return results, nil
+}
+
+// newBulkAccount validates an entry for a bulk import, returning the account to be imported along
+// with its secret key.
+func (w *wallet) newBulkAccount(ctx context.Context, entry *ImportEntry) (*account, []byte, error) {
+	if err := w.validateImport(entry.Name,
+		entry.SecretShare,
+		entry.SigningThreshold,
+		entry.VerificationVector,
+		entry.Participants,
+	); err != nil {
+		return nil, nil, err
+	}
+
+	// Ensure that we don't already have an account with this name.
+	if _, exists := w.index.ID(entry.Name); exists {
+		return nil, nil, newAlreadyExistsError("account", entry.Name, "account with name %q already exists", entry.Name)
+	}
+
+	a, secret, err := w.newImportedAccount(entry.Name,
+		entry.SecretShare,
+		entry.SigningThreshold,
+		entry.VerificationVector,
+		entry.Participants,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !a.watchOnly {
+		if _, err := a.ParticipantID(); err != nil {
+			return nil, nil, errors.New("secret share does not correspond to the verification vector for any participant")
+		}
+	}
+
+	// Ensure that we don't already have an account with this public key.
+	if _, err := w.AccountByPublicKey(ctx, a.publicKey.Marshal()); err == nil {
+		return nil, nil, newAlreadyExistsError("account", entry.Name,
+			"account with public key %#x already exists", a.publicKey.Marshal())
+	}
+
+	return a, secret, nil
+}
+
+// storeBulkAccounts encrypts and stores the accounts of a bulk import concurrently, recording any
+// errors in the results.  Nil accounts are skipped.
+func (w *wallet) storeBulkAccounts(ctx context.Context,
+	accounts []*account,
+	secrets [][]byte,
+	passphrase []byte,
+	results []*ImportResult,
+	concurrency int,
+) {
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for n := 0; n < concurrency; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				results[i].Err = w.storeBulkAccount(ctx, accounts[i], secrets[i], passphrase)
+			}
+		}()
+	}
+	for i := range accounts {
+		if accounts[i] == nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			results[i].Err = ctx.Err()
+
+			continue
+		}
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+}
+
+// storeBulkAccount encrypts and stores a single account of a bulk import.
+func (w *wallet) storeBulkAccount(ctx context.Context, a *account, secret []byte, passphrase []byte) error {
+	if !a.watchOnly {
+		var err error
+		a.crypto, err = w.encryptSecret(ctx, secret, string(passphrase))
+		if err != nil {
+			return errors.Wrap(err, "failed to encrypt private key")
+		}
+	}
+	data, err := json.Marshal(a)
+	if err != nil {
+		return errors.Wrap(err, "failed to create store format")
+	}
+	if err := w.store.StoreAccount(w.ID(), a.ID(), data); err != nil {
+		return errors.Wrap(err, "failed to store account")
+	}
+
+	return nil
+}
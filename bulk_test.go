@@ -0,0 +1,246 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	scratch "github.com/wealdtech/go-eth2-wallet-store-scratch"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+// indexCountingStore is a store that counts the number of times the accounts index is stored.
+type indexCountingStore struct {
+	e2wtypes.Store
+	indexStores int
+}
+
+func (s *indexCountingStore) StoreAccountsIndex(walletID uuid.UUID, data []byte) error {
+	s.indexStores++
+
+	return s.Store.StoreAccountsIndex(walletID, data)
+}
+
+// importEntry creates an import entry for participant 1 of a new 2-of-3 distributed account.
+func importEntry(t *testing.T, name string) *distributed.ImportEntry {
+	t.Helper()
+
+	vvec, shares := generateShares(t, 2, 1, 2, 3)
+
+	return &distributed.ImportEntry{
+		Name:               name,
+		SecretShare:        shares[1],
+		SigningThreshold:   2,
+		VerificationVector: vvec,
+		Participants: map[uint64]*distributed.Participant{
+			1: {Endpoint: "signer1:443"},
+			2: {Endpoint: "signer2:443"},
+			3: {Endpoint: "signer3:443"},
+		},
+	}
+}
+
+func TestParseImportManifest(t *testing.T) {
+	entry := importEntry(t, "Account 1")
+	vvec := fmt.Sprintf("[%#x, %#x]", entry.VerificationVector[0], entry.VerificationVector[1])
+
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{
+			name: "JSON",
+			data: fmt.Sprintf(`{"accounts":[{"name":"Account 1","secret_share":"%#x","signing_threshold":2,"verification_vector":["%#x","%#x"],"participants":{"1":{"endpoint":"signer1:443","name":"Signer 1"},"2":"signer2:443","3":"signer3:443"}}]}`,
+				entry.SecretShare, entry.VerificationVector[0], entry.VerificationVector[1]),
+		},
+		{
+			name: "YAML",
+			data: fmt.Sprintf(`accounts:
+  - name: Account 1
+    secret_share: %#x
+    signing_threshold: 2
+    verification_vector: %s
+    participants:
+      1:
+        endpoint: signer1:443
+        name: Signer 1
+      2: signer2:443
+      3: signer3:443
+`, entry.SecretShare, vvec),
+		},
+		{
+			name: "Invalid",
+			data: `{"accounts":`,
+			err:  "invalid format: unexpected end of JSON input",
+		},
+		{
+			name: "SecretShareInvalid",
+			data: `{"accounts":[{"name":"Account 1","secret_share":"0xinvalid"}]}`,
+			err:  "entry 1: invalid secret share: encoding/hex: invalid byte: U+0069 'i'",
+		},
+		{
+			name: "ParticipantIDInvalid",
+			data: `{"accounts":[{"name":"Account 1","participants":{"one":"signer1:443"}}]}`,
+			err:  `entry 1: invalid participant ID: strconv.ParseUint: parsing "one": invalid syntax`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries, err := distributed.ParseImportManifest([]byte(test.data))
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+			require.Equal(t, "entry 1", entries[0].Source)
+			require.Equal(t, "Account 1", entries[0].Name)
+			require.Equal(t, entry.SecretShare, entries[0].SecretShare)
+			require.Equal(t, uint32(2), entries[0].SigningThreshold)
+			require.Equal(t, entry.VerificationVector, entries[0].VerificationVector)
+			require.Equal(t, map[uint64]*distributed.Participant{
+				1: {Endpoint: "signer1:443", Name: "Signer 1"},
+				2: {Endpoint: "signer2:443"},
+				3: {Endpoint: "signer3:443"},
+			}, entries[0].Participants)
+		})
+	}
+}
+
+func TestReadImportDirectory(t *testing.T) {
+	dir := t.TempDir()
+	entry := importEntry(t, "")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "validator-1.json"),
+		[]byte(fmt.Sprintf(`{"secret_share":"%#x","signing_threshold":2,"verification_vector":["%#x","%#x"],"participants":{"1":"signer1:443","2":"signer2:443","3":"signer3:443"}}`,
+			entry.SecretShare, entry.VerificationVector[0], entry.VerificationVector[1])), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "validator-2.yaml"),
+		[]byte(fmt.Sprintf("name: Named\nsecret_share: %#x\nsigning_threshold: 2\n", entry.SecretShare)), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("ignored"), 0o600))
+
+	entries, err := distributed.ReadImportDirectory(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "validator-1.json", entries[0].Source)
+	require.Equal(t, "validator-1", entries[0].Name)
+	require.Equal(t, entry.VerificationVector, entries[0].VerificationVector)
+	require.Len(t, entries[0].Participants, 3)
+	require.Equal(t, "validator-2.yaml", entries[1].Source)
+	require.Equal(t, "Named", entries[1].Name)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "validator-3.json"), []byte(`{`), 0o600))
+	_, err = distributed.ReadImportDirectory(dir)
+	require.EqualError(t, err, "validator-3.json: invalid format: unexpected end of JSON input")
+
+	_, err = distributed.ReadImportDirectory(filepath.Join(dir, "missing"))
+	require.ErrorContains(t, err, "failed to read directory")
+}
+
+func TestImportDistributedAccounts(t *testing.T) {
+	ctx := context.Background()
+	store := &indexCountingStore{Store: scratch.New()}
+	wallet, err := distributed.CreateWallet(ctx, "test wallet", store, keystorev4.New())
+	require.NoError(t, err)
+	importer := wallet.(distributed.WalletDistributedAccountsImporter)
+
+	entries := []*distributed.ImportEntry{
+		importEntry(t, "Account 1"),
+		importEntry(t, "Account 2"),
+		importEntry(t, "Account 3"),
+	}
+
+	_, err = importer.ImportDistributedAccounts(ctx, entries, []byte("pass"))
+	require.EqualError(t, err, "wallet must be unlocked to create accounts")
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+	_, err = importer.ImportDistributedAccounts(ctx, entries, []byte("pass"), distributed.WithConcurrency(0))
+	require.EqualError(t, err, "concurrency must be at least 1")
+
+	existing := importEntry(t, "Existing")
+	existingAccount, err := wallet.(distributed.WalletDistributedAccountWithParticipantsImporter).ImportDistributedAccountWithParticipants(ctx,
+		existing.Name, existing.SecretShare, existing.SigningThreshold, existing.VerificationVector, existing.Participants, []byte("pass"))
+	require.NoError(t, err)
+
+	mismatched := importEntry(t, "Mismatched")
+	mismatched.VerificationVector = entries[0].VerificationVector
+	duplicateKey := importEntry(t, "Duplicate key")
+	duplicateKey.SecretShare = entries[1].SecretShare
+	duplicateKey.VerificationVector = entries[1].VerificationVector
+	existingKey := importEntry(t, "Existing key")
+	existingKey.SecretShare = existing.SecretShare
+	existingKey.VerificationVector = existing.VerificationVector
+	invalidThreshold := importEntry(t, "Invalid threshold")
+	invalidThreshold.SigningThreshold = 1
+	entries = append(entries,
+		importEntry(t, "Existing"),
+		importEntry(t, "Account 1"),
+		mismatched,
+		duplicateKey,
+		existingKey,
+		invalidThreshold,
+		nil,
+	)
+	entries[1].Source = "validator-2.json"
+
+	store.indexStores = 0
+	results, err := importer.ImportDistributedAccounts(ctx, entries, []byte("pass"), distributed.WithConcurrency(2))
+	require.NoError(t, err)
+	require.Len(t, results, len(entries))
+	require.Equal(t, 1, store.indexStores)
+
+	errs := make([]string, len(results))
+	for i, result := range results {
+		if result.Err != nil {
+			errs[i] = result.Err.Error()
+			require.Nil(t, result.Account)
+		} else {
+			require.NotNil(t, result.Account)
+			require.Equal(t, result.Name, result.Account.Name())
+		}
+	}
+	require.Equal(t, "", errs[0])
+	require.Equal(t, "validator-2.json", results[1].Source)
+	require.Equal(t, "", errs[2])
+	require.Equal(t, `account with name "Existing" already exists`, errs[3])
+	require.Equal(t, `account name "Account 1" also used by entry 1`, errs[4])
+	require.Equal(t, "secret share does not correspond to the verification vector for any participant", errs[5])
+	require.Equal(t, fmt.Sprintf("public key %#x also used by validator-2.json",
+		results[1].Account.(e2wtypes.AccountPublicKeyProvider).PublicKey().Marshal()), errs[6])
+	require.Equal(t, fmt.Sprintf("account with public key %#x already exists",
+		existingAccount.(e2wtypes.AccountPublicKeyProvider).PublicKey().Marshal()), errs[7])
+	require.Equal(t, "invalid signing threshold:participant ratio", errs[8])
+	require.Equal(t, "entry missing", errs[9])
+	require.Equal(t, "entry 10", results[9].Source)
+
+	// Reopen the wallet to ensure that the accounts have been indexed and stored.
+	wallet, err = distributed.OpenWallet(ctx, "test wallet", store, keystorev4.New())
+	require.NoError(t, err)
+	for _, name := range []string{"Account 1", "Account 2", "Account 3", "Existing"} {
+		account, err := wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, name)
+		require.NoError(t, err)
+		require.NoError(t, account.(e2wtypes.AccountLocker).Unlock(ctx, []byte("pass")))
+	}
+	report, err := wallet.(distributed.WalletVerifier).Verify(ctx)
+	require.NoError(t, err)
+	require.True(t, report.OK())
+	require.Equal(t, 4, report.Accounts)
+}
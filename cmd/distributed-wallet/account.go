@@ -0,0 +1,381 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+
+	"github.com/pkg/errors"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	"github.com/wealdtech/go-eth2-wallet-distributed/obol"
+	"github.com/wealdtech/go-eth2-wallet-distributed/ssv"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+// accountImport imports an account from a DKG output file.
+func accountImport(ctx context.Context, args []string, out io.Writer) error {
+	fs, common := newFlagSet("account import")
+	file := fs.String("file", "", "DKG output file")
+	name := fs.String("account", "", "name of the account (defaults to the name in the DKG output file)")
+	passphrase := fs.String("passphrase", "", "passphrase with which to protect the account (not required for watch-only accounts)")
+	if err := parse(fs, args, "wallet", "file"); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return errors.Wrap(err, "failed to read DKG output")
+	}
+	entry, err := distributed.ParseImportEntry(data)
+	if err != nil {
+		return errors.Wrap(err, "invalid DKG output")
+	}
+	if len(entry.SecretShare) > 0 && *passphrase == "" {
+		// Only watch-only accounts, without a secret share, can be imported without a passphrase.
+		return errors.New("--passphrase is required")
+	}
+	if *name != "" {
+		entry.Name = *name
+	}
+	if entry.Name == "" {
+		return errors.New("account name not supplied")
+	}
+
+	wallet, err := common.openUnlockedWallet(ctx)
+	if err != nil {
+		return err
+	}
+	account, err := wallet.(distributed.WalletDistributedAccountWithParticipantsImporter).ImportDistributedAccountWithParticipants(ctx,
+		entry.Name,
+		entry.SecretShare,
+		entry.SigningThreshold,
+		entry.VerificationVector,
+		entry.Participants,
+		[]byte(*passphrase),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to import account")
+	}
+	pubKey, err := compositePublicKey(account)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Imported account %q with composite public key %#x\n", account.Name(), pubKey)
+
+	return nil
+}
+
+// accountBulkImport imports accounts from a manifest or a directory of DKG output files.
+func accountBulkImport(ctx context.Context, args []string, out io.Writer) error {
+	fs, common := newFlagSet("account bulk-import")
+	manifest := fs.String("manifest", "", "manifest file listing the accounts to import")
+	dir := fs.String("dir", "", "directory containing one DKG output file per account")
+	passphrase := fs.String("passphrase", "", "passphrase with which to protect the accounts")
+	concurrency := fs.Int("concurrency", runtime.NumCPU(), "number of accounts to import concurrently")
+	if err := parse(fs, args, "wallet", "passphrase"); err != nil {
+		return err
+	}
+
+	var entries []*distributed.ImportEntry
+	switch {
+	case *manifest != "" && *dir != "":
+		return errors.New("only one of --manifest and --dir can be supplied")
+	case *manifest != "":
+		data, err := os.ReadFile(*manifest)
+		if err != nil {
+			return errors.Wrap(err, "failed to read manifest")
+		}
+		entries, err = distributed.ParseImportManifest(data)
+		if err != nil {
+			return errors.Wrap(err, "invalid manifest")
+		}
+	case *dir != "":
+		var err error
+		entries, err = distributed.ReadImportDirectory(*dir)
+		if err != nil {
+			return errors.Wrap(err, "invalid directory")
+		}
+	default:
+		return errors.New("one of --manifest and --dir is required")
+	}
+
+	wallet, err := common.openUnlockedWallet(ctx)
+	if err != nil {
+		return err
+	}
+	results, err := wallet.(distributed.WalletDistributedAccountsImporter).ImportDistributedAccounts(ctx,
+		entries,
+		[]byte(*passphrase),
+		distributed.WithConcurrency(*concurrency),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to import accounts")
+	}
+
+	return reportImport(out, results)
+}
+
+// accountObolImport imports accounts from a charon directory.
+func accountObolImport(ctx context.Context, args []string, out io.Writer) error {
+	fs, common := newFlagSet("account obol-import")
+	dir := fs.String("dir", ".charon", "charon directory containing the cluster lock and validator keys")
+	passphrase := fs.String("passphrase", "", "passphrase with which to protect the accounts")
+	concurrency := fs.Int("concurrency", runtime.NumCPU(), "number of accounts to import concurrently")
+	if err := parse(fs, args, "wallet", "passphrase"); err != nil {
+		return err
+	}
+
+	wallet, err := common.openUnlockedWallet(ctx)
+	if err != nil {
+		return err
+	}
+	results, err := obol.ImportCluster(ctx, wallet, *dir, []byte(*passphrase), obol.WithConcurrency(*concurrency))
+	if err != nil {
+		return errors.Wrap(err, "failed to import cluster")
+	}
+
+	return reportImport(out, results)
+}
+
+// accountSSVImport imports accounts from an SSV keyshares export.
+func accountSSVImport(ctx context.Context, args []string, out io.Writer) error {
+	fs, common := newFlagSet("account ssv-import")
+	file := fs.String("file", "", "keyshares export")
+	operatorID := fs.Uint64("operator-id", 0, "ID of the operator")
+	operatorKey := fs.String("operator-key", "", "file containing the operator's RSA private key")
+	passphrase := fs.String("passphrase", "", "passphrase with which to protect the accounts")
+	concurrency := fs.Int("concurrency", runtime.NumCPU(), "number of accounts to import concurrently")
+	if err := parse(fs, args, "wallet", "file", "operator-id", "operator-key", "passphrase"); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return errors.Wrap(err, "failed to read keyshares")
+	}
+	keyShares, err := ssv.ParseKeyShares(data)
+	if err != nil {
+		return errors.Wrap(err, "invalid keyshares")
+	}
+	keyData, err := os.ReadFile(*operatorKey)
+	if err != nil {
+		return errors.Wrap(err, "failed to read operator key")
+	}
+	key, err := ssv.ParseOperatorKey(keyData)
+	if err != nil {
+		return errors.Wrap(err, "invalid operator key")
+	}
+
+	wallet, err := common.openUnlockedWallet(ctx)
+	if err != nil {
+		return err
+	}
+	results, err := ssv.ImportKeyShares(ctx, wallet, keyShares, *operatorID, key, []byte(*passphrase), ssv.WithConcurrency(*concurrency))
+	if err != nil {
+		return errors.Wrap(err, "failed to import keyshares")
+	}
+
+	return reportImport(out, results)
+}
+
+// reportImport reports the results of a bulk import, returning an error if any entries failed.
+func reportImport(out io.Writer, results []*distributed.ImportResult) error {
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+			fmt.Fprintf(out, "%s: failed to import account %q: %v\n", result.Source, result.Name, result.Err)
+		} else {
+			fmt.Fprintf(out, "%s: imported account %q\n", result.Source, result.Name)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to import %d of %d accounts", failed, len(results))
+	}
+
+	return nil
+}
+
+// accountList lists the accounts in a wallet.
+func accountList(ctx context.Context, args []string, out io.Writer) error {
+	fs, common := newFlagSet("account list")
+	if err := parse(fs, args, "wallet"); err != nil {
+		return err
+	}
+
+	wallet, err := common.openWallet(ctx)
+	if err != nil {
+		return err
+	}
+	listed := 0
+	failed := 0
+	for result := range wallet.(distributed.WalletAccountsIterator).IterateAccounts(ctx, distributed.WithOrder(distributed.OrderByName)) {
+		if result.Err != nil {
+			failed++
+			name := result.Name
+			if name == "" {
+				name = result.ID.String()
+			}
+			fmt.Fprintf(out, "%s\terror: %v\n", name, result.Err)
+
+			continue
+		}
+		pubKey, err := compositePublicKey(result.Account)
+		if err != nil {
+			failed++
+			fmt.Fprintf(out, "%s\terror: %v\n", result.Account.Name(), err)
+
+			continue
+		}
+		listed++
+		fmt.Fprintf(out, "%s\t%#x\n", result.Account.Name(), pubKey)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to list %d of %d accounts", failed, listed+failed)
+	}
+
+	return nil
+}
+
+// accountShow shows the details of an account.
+func accountShow(ctx context.Context, args []string, out io.Writer) error {
+	fs, common := newFlagSet("account show")
+	name := fs.String("account", "", "name of the account")
+	if err := parse(fs, args, "wallet", "account"); err != nil {
+		return err
+	}
+
+	wallet, err := common.openWallet(ctx)
+	if err != nil {
+		return err
+	}
+	account, err := account(ctx, wallet, *name)
+	if err != nil {
+		return err
+	}
+
+	pubKey, err := compositePublicKey(account)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Name: %s\n", account.Name())
+	fmt.Fprintf(out, "ID: %s\n", account.ID())
+	fmt.Fprintf(out, "Public key: %#x\n", account.(e2wtypes.AccountPublicKeyProvider).PublicKey().Marshal())
+	fmt.Fprintf(out, "Composite public key: %#x\n", pubKey)
+	if account.(distributed.AccountWatchOnlyProvider).WatchOnly() {
+		fmt.Fprintln(out, "Watch-only: true")
+	}
+	participants := account.(distributed.AccountParticipantDescriptorsProvider).ParticipantDescriptors()
+	fmt.Fprintf(out, "Signing threshold: %d/%d\n", account.(e2wtypes.AccountSigningThresholdProvider).SigningThreshold(), len(participants))
+	ids := make([]uint64, 0, len(participants))
+	for id := range participants {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	fmt.Fprintln(out, "Participants:")
+	for _, id := range ids {
+		if participants[id].Name != "" {
+			fmt.Fprintf(out, "  %d: %s (%s)\n", id, participants[id].Endpoint, participants[id].Name)
+		} else {
+			fmt.Fprintf(out, "  %d: %s\n", id, participants[id].Endpoint)
+		}
+	}
+	metadata := account.(distributed.AccountMetadataValuesProvider).Metadata()
+	if len(metadata) > 0 {
+		keys := make([]string, 0, len(metadata))
+		for key := range metadata {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fmt.Fprintln(out, "Metadata:")
+		for _, key := range keys {
+			fmt.Fprintf(out, "  %s: %s\n", key, metadata[key])
+		}
+	}
+
+	return nil
+}
+
+// accountPassphrase changes the passphrase of an account.
+func accountPassphrase(ctx context.Context, args []string, out io.Writer) error {
+	fs, common := newFlagSet("account passphrase")
+	name := fs.String("account", "", "name of the account")
+	passphrase := fs.String("passphrase", "", "current passphrase of the account")
+	newPassphrase := fs.String("new-passphrase", "", "new passphrase of the account")
+	if err := parse(fs, args, "wallet", "account", "passphrase", "new-passphrase"); err != nil {
+		return err
+	}
+
+	wallet, err := common.openUnlockedWallet(ctx)
+	if err != nil {
+		return err
+	}
+	account, err := account(ctx, wallet, *name)
+	if err != nil {
+		return err
+	}
+	if err := account.(distributed.AccountPassphraseChanger).ChangePassphrase(ctx, []byte(*passphrase), []byte(*newPassphrase)); err != nil {
+		return errors.Wrap(err, "failed to change passphrase")
+	}
+	fmt.Fprintf(out, "Changed passphrase for account %q\n", account.Name())
+
+	return nil
+}
+
+// accountSign signs a test message with an account, and verifies the resultant partial signature.
+func accountSign(ctx context.Context, args []string, out io.Writer) error {
+	fs, common := newFlagSet("account sign")
+	name := fs.String("account", "", "name of the account")
+	passphrase := fs.String("passphrase", "", "passphrase of the account")
+	message := fs.String("message", "test", "message to sign")
+	if err := parse(fs, args, "wallet", "account", "passphrase"); err != nil {
+		return err
+	}
+
+	wallet, err := common.openWallet(ctx)
+	if err != nil {
+		return err
+	}
+	account, err := account(ctx, wallet, *name)
+	if err != nil {
+		return err
+	}
+	locker := account.(e2wtypes.AccountLocker)
+	if err := locker.Unlock(ctx, []byte(*passphrase)); err != nil {
+		return errors.Wrap(err, "failed to unlock account")
+	}
+	defer func() {
+		_ = locker.Lock(ctx)
+	}()
+
+	data := sha256.Sum256([]byte(*message))
+	signature, err := account.(e2wtypes.AccountSigner).Sign(ctx, data[:])
+	if err != nil {
+		return errors.Wrap(err, "failed to sign")
+	}
+	if !signature.Verify(data[:], account.(e2wtypes.AccountPublicKeyProvider).PublicKey()) {
+		return errors.New("signature does not verify against the account's public key")
+	}
+	fmt.Fprintf(out, "Data: %#x\n", data)
+	fmt.Fprintf(out, "Signature: %#x\n", signature.Marshal())
+
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+// batchBuild builds the batch for a wallet.
+func batchBuild(ctx context.Context, args []string, out io.Writer) error {
+	fs, common := newFlagSet("batch build")
+	passphrases := &stringsFlag{}
+	fs.Var(passphrases, "passphrase", "passphrase of the wallet's accounts (can be supplied multiple times)")
+	batchPassphrase := fs.String("batch-passphrase", "", "passphrase with which to protect the batch")
+	if err := parse(fs, args, "wallet", "passphrase", "batch-passphrase"); err != nil {
+		return err
+	}
+
+	wallet, err := common.openWallet(ctx)
+	if err != nil {
+		return err
+	}
+	if err := wallet.(e2wtypes.WalletBatchCreator).BatchWallet(ctx, *passphrases, *batchPassphrase); err != nil {
+		return errors.Wrap(err, "failed to build batch")
+	}
+	fmt.Fprintf(out, "Built batch for wallet %q\n", wallet.Name())
+
+	return nil
+}
+
+// batchVerify verifies a wallet and its batch.
+func batchVerify(ctx context.Context, args []string, out io.Writer) error {
+	fs, common := newFlagSet("batch verify")
+	passphrases := &stringsFlag{}
+	fs.Var(passphrases, "passphrase", "passphrase with which to check that secret keys can be decrypted (can be supplied multiple times)")
+	repair := fs.Bool("repair", false, "repair the accounts index")
+	if err := parse(fs, args, "wallet"); err != nil {
+		return err
+	}
+
+	// Repairing requires an unlocked wallet.
+	open := common.openWallet
+	if *repair {
+		open = common.openUnlockedWallet
+	}
+	wallet, err := open(ctx)
+	if err != nil {
+		return err
+	}
+	opts := make([]distributed.VerifyOption, 0)
+	if len(*passphrases) > 0 {
+		opts = append(opts, distributed.WithVerifyPassphrases(passphrases.bytes()...))
+	}
+	if *repair {
+		opts = append(opts, distributed.WithRepair())
+	}
+	report, err := wallet.(distributed.WalletVerifier).Verify(ctx, opts...)
+	if err != nil {
+		return errors.Wrap(err, "failed to verify wallet")
+	}
+
+	fmt.Fprintf(out, "Verified %d accounts\n", report.Accounts)
+	outstanding := 0
+	for _, problem := range report.Problems {
+		state := ""
+		if problem.Repaired {
+			state = " (repaired)"
+		} else {
+			outstanding++
+		}
+		if problem.AccountName != "" {
+			fmt.Fprintf(out, "%s: account %q: %s%s\n", problem.Kind, problem.AccountName, problem.Description, state)
+		} else {
+			fmt.Fprintf(out, "%s: %s%s\n", problem.Kind, problem.Description, state)
+		}
+	}
+	if outstanding > 0 {
+		return fmt.Errorf("wallet has %d outstanding problems", outstanding)
+	}
+
+	return nil
+}
+
+// batchRotate changes the passphrase of a wallet's batch.
+func batchRotate(ctx context.Context, args []string, out io.Writer) error {
+	fs, common := newFlagSet("batch rotate")
+	batchPassphrase := fs.String("batch-passphrase", "", "current passphrase of the batch")
+	newBatchPassphrase := fs.String("new-batch-passphrase", "", "new passphrase of the batch")
+	if err := parse(fs, args, "wallet", "batch-passphrase", "new-batch-passphrase"); err != nil {
+		return err
+	}
+
+	wallet, err := common.openWallet(ctx)
+	if err != nil {
+		return err
+	}
+	if err := wallet.(distributed.WalletBatchPassphraseChanger).ChangeBatchPassphrase(ctx,
+		[]byte(*batchPassphrase), []byte(*newBatchPassphrase)); err != nil {
+		return errors.Wrap(err, "failed to change batch passphrase")
+	}
+	fmt.Fprintf(out, "Changed batch passphrase for wallet %q\n", wallet.Name())
+
+	return nil
+}
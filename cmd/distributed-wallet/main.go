@@ -0,0 +1,229 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command distributed-wallet manages distributed wallets held in a filesystem store.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	filesystem "github.com/wealdtech/go-eth2-wallet-store-filesystem"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+// command is a command provided by the tool.
+type command struct {
+	description string
+	run         func(ctx context.Context, args []string, out io.Writer) error
+}
+
+// commands are the commands provided by the tool, by group and name.
+var commands = map[string]map[string]*command{
+	"wallet": {
+		"create": {"create a wallet", walletCreate},
+		"export": {"export a wallet to a file", walletExport},
+		"import": {"import a wallet from a file", walletImport},
+	},
+	"account": {
+		"import":      {"import an account from a DKG output file", accountImport},
+		"bulk-import": {"import accounts from a manifest or a directory of DKG output files", accountBulkImport},
+		"obol-import": {"import accounts from an Obol charon directory", accountObolImport},
+		"ssv-import":  {"import accounts from an SSV keyshares export", accountSSVImport},
+		"list":        {"list the accounts in a wallet", accountList},
+		"show":        {"show the details of an account", accountShow},
+		"passphrase":  {"change the passphrase of an account", accountPassphrase},
+		"sign":        {"sign a test message with an account", accountSign},
+	},
+	"batch": {
+		"build":  {"build the batch for a wallet", batchBuild},
+		"verify": {"verify a wallet and its batch", batchVerify},
+		"rotate": {"change the passphrase of a wallet's batch", batchRotate},
+	},
+}
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run runs the command given by the arguments.
+func run(ctx context.Context, args []string, out io.Writer) error {
+	if err := e2types.InitBLS(); err != nil {
+		return errors.Wrap(err, "failed to initialise BLS")
+	}
+	if len(args) < 2 {
+		return errors.New(usage())
+	}
+	group, exists := commands[args[0]]
+	if !exists {
+		return errors.New(usage())
+	}
+	cmd, exists := group[args[1]]
+	if !exists {
+		return errors.New(usage())
+	}
+
+	return cmd.run(ctx, args[2:], out)
+}
+
+// usage returns the usage of the tool.
+func usage() string {
+	lines := []string{"usage: distributed-wallet <group> <command> [flags]", "commands:"}
+	groups := make([]string, 0, len(commands))
+	for group := range commands {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		names := make([]string, 0, len(commands[group]))
+		for name := range commands[group] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			lines = append(lines, fmt.Sprintf("  %s %s: %s", group, name, commands[group][name].description))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// stringsFlag is a flag that can be supplied multiple times.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+
+	return nil
+}
+
+// bytes returns the values of the flag as byte slices.
+func (f *stringsFlag) bytes() [][]byte {
+	res := make([][]byte, len(*f))
+	for i := range *f {
+		res[i] = []byte((*f)[i])
+	}
+
+	return res
+}
+
+// commonFlags are the flags common to all commands.
+type commonFlags struct {
+	baseDir         string
+	storePassphrase string
+	wallet          string
+}
+
+// newFlagSet creates a flag set for a command, including the common flags.
+func newFlagSet(name string) (*flag.FlagSet, *commonFlags) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	common := &commonFlags{}
+	fs.StringVar(&common.baseDir, "base-dir", "", "base directory of the filesystem store (defaults to the store's default location)")
+	fs.StringVar(&common.storePassphrase, "store-passphrase", "", "passphrase of the filesystem store, if encrypted")
+	fs.StringVar(&common.wallet, "wallet", "", "name of the wallet")
+
+	return fs, common
+}
+
+// parse parses the arguments, ensuring that the given flags have been supplied.
+func parse(fs *flag.FlagSet, args []string, required ...string) error {
+	if err := fs.Parse(args); err != nil {
+		return errors.Wrap(err, "invalid flags")
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	supplied := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { supplied[f.Name] = true })
+	for _, name := range required {
+		if !supplied[name] {
+			return fmt.Errorf("--%s is required", name)
+		}
+	}
+
+	return nil
+}
+
+// store returns the filesystem store.
+func (c *commonFlags) store() e2wtypes.Store {
+	opts := make([]filesystem.Option, 0)
+	if c.baseDir != "" {
+		opts = append(opts, filesystem.WithLocation(c.baseDir))
+	}
+	if c.storePassphrase != "" {
+		opts = append(opts, filesystem.WithPassphrase([]byte(c.storePassphrase)))
+	}
+
+	return filesystem.New(opts...)
+}
+
+// openWallet opens the wallet.
+func (c *commonFlags) openWallet(ctx context.Context) (e2wtypes.Wallet, error) {
+	wallet, err := distributed.OpenWallet(ctx, c.wallet, c.store(), keystorev4.New())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open wallet %q", c.wallet)
+	}
+
+	return wallet, nil
+}
+
+// openUnlockedWallet opens and unlocks the wallet.
+func (c *commonFlags) openUnlockedWallet(ctx context.Context) (e2wtypes.Wallet, error) {
+	wallet, err := c.openWallet(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Distributed wallets do not have passphrases.
+	if err := wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil); err != nil {
+		return nil, errors.Wrap(err, "failed to unlock wallet")
+	}
+
+	return wallet, nil
+}
+
+// account obtains the named account from the wallet.
+func account(ctx context.Context, wallet e2wtypes.Wallet, name string) (e2wtypes.Account, error) {
+	account, err := wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to obtain account %q", name)
+	}
+
+	return account, nil
+}
+
+// compositePublicKey obtains the composite public key of an account, returning an error if the account is malformed.
+func compositePublicKey(account e2wtypes.Account) ([]byte, error) {
+	pubKey, err := account.(distributed.AccountDistributedDetailsProvider).ObtainCompositePublicKey()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to obtain composite public key for account %q", account.Name())
+	}
+
+	return pubKey.Marshal(), nil
+}
@@ -0,0 +1,278 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	bls "github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	filesystem "github.com/wealdtech/go-eth2-wallet-store-filesystem"
+)
+
+func TestMain(m *testing.M) {
+	if err := e2types.InitBLS(); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// writeDKGOutput writes a DKG output file for participant 1 of a new 2-of-3 distributed account.
+func writeDKGOutput(t *testing.T, path string, name string) {
+	t.Helper()
+
+	msk := make([]bls.SecretKey, 2)
+	for i := range msk {
+		msk[i].SetByCSPRNG()
+	}
+	mpk := bls.GetMasterPublicKey(msk)
+	verificationVector := make([]string, len(mpk))
+	for i := range mpk {
+		verificationVector[i] = fmt.Sprintf("%#x", mpk[i].Serialize())
+	}
+	var id bls.ID
+	require.NoError(t, id.SetDecString("1"))
+	var share bls.SecretKey
+	require.NoError(t, share.Set(msk, &id))
+
+	data, err := json.Marshal(map[string]any{
+		"name":                name,
+		"secret_share":        fmt.Sprintf("%#x", share.Serialize()),
+		"signing_threshold":   2,
+		"verification_vector": verificationVector,
+		"participants": map[string]any{
+			"1": map[string]any{"endpoint": "signer1:443", "name": "Signer 1"},
+			"2": "signer2:443",
+			"3": "signer3:443",
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+// runCommand runs the tool with the given arguments, returning its output.
+func runCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	out := &bytes.Buffer{}
+	err := run(context.Background(), args, out)
+
+	return out.String(), err
+}
+
+func TestUsage(t *testing.T) {
+	_, err := runCommand(t)
+	require.ErrorContains(t, err, "usage: distributed-wallet <group> <command> [flags]")
+	_, err = runCommand(t, "wallet", "unknown")
+	require.ErrorContains(t, err, "usage: distributed-wallet <group> <command> [flags]")
+	_, err = runCommand(t, "wallet", "create", "--base-dir", t.TempDir())
+	require.EqualError(t, err, "--wallet is required")
+	_, err = runCommand(t, "wallet", "create", "--unknown")
+	require.EqualError(t, err, "invalid flags: flag provided but not defined: -unknown")
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	store := []string{"--base-dir", filepath.Join(dir, "store"), "--wallet", "test wallet"}
+	withStore := func(args ...string) []string {
+		return append(append([]string{}, args...), store...)
+	}
+
+	out, err := runCommand(t, withStore("wallet", "create")...)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(out, `Created wallet "test wallet" with ID `))
+
+	dkgOutput := filepath.Join(dir, "dkg.json")
+	writeDKGOutput(t, dkgOutput, "Account 1")
+	out, err = runCommand(t, withStore("account", "import", "--file", dkgOutput, "--passphrase", "pass")...)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(out, `Imported account "Account 1" with composite public key 0x`))
+	writeDKGOutput(t, dkgOutput, "")
+	_, err = runCommand(t, withStore("account", "import", "--file", dkgOutput, "--passphrase", "pass", "--account", "Account 2")...)
+	require.NoError(t, err)
+
+	out, err = runCommand(t, withStore("account", "list")...)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 2)
+	require.True(t, strings.HasPrefix(lines[0], "Account 1\t0x"))
+	require.True(t, strings.HasPrefix(lines[1], "Account 2\t0x"))
+
+	out, err = runCommand(t, withStore("account", "show", "--account", "Account 1")...)
+	require.NoError(t, err)
+	require.Contains(t, out, "Signing threshold: 2/3\n")
+	require.Contains(t, out, "  1: signer1:443 (Signer 1)\n  2: signer2:443\n  3: signer3:443\n")
+
+	_, err = runCommand(t, withStore("account", "sign", "--account", "Account 1", "--passphrase", "wrong")...)
+	require.ErrorContains(t, err, "failed to unlock account")
+	out, err = runCommand(t, withStore("account", "sign", "--account", "Account 1", "--passphrase", "pass")...)
+	require.NoError(t, err)
+	require.Contains(t, out, "Signature: 0x")
+
+	_, err = runCommand(t, withStore("account", "passphrase", "--account", "Account 1", "--passphrase", "wrong", "--new-passphrase", "new pass")...)
+	require.ErrorContains(t, err, "incorrect passphrase")
+	_, err = runCommand(t, withStore("account", "passphrase", "--account", "Account 1", "--passphrase", "pass", "--new-passphrase", "new pass")...)
+	require.NoError(t, err)
+	_, err = runCommand(t, withStore("account", "sign", "--account", "Account 1", "--passphrase", "new pass")...)
+	require.NoError(t, err)
+
+	_, err = runCommand(t, withStore("batch", "build", "--passphrase", "pass", "--passphrase", "new pass", "--batch-passphrase", "batch pass")...)
+	require.NoError(t, err)
+	out, err = runCommand(t, withStore("batch", "verify", "--passphrase", "pass", "--passphrase", "new pass", "--passphrase", "batch pass")...)
+	require.NoError(t, err)
+	require.Equal(t, "Verified 2 accounts\n", out)
+	_, err = runCommand(t, withStore("batch", "rotate", "--batch-passphrase", "wrong", "--new-batch-passphrase", "new batch pass")...)
+	require.ErrorContains(t, err, "incorrect batch passphrase")
+	_, err = runCommand(t, withStore("batch", "rotate", "--batch-passphrase", "batch pass", "--new-batch-passphrase", "new batch pass")...)
+	require.NoError(t, err)
+
+	exportFile := filepath.Join(dir, "export.json")
+	_, err = runCommand(t, withStore("wallet", "export", "--file", exportFile, "--export-passphrase", "export pass", "--batch")...)
+	require.NoError(t, err)
+	out, err = runCommand(t, "wallet", "import", "--base-dir", filepath.Join(dir, "imported"),
+		"--file", exportFile, "--export-passphrase", "export pass")
+	require.NoError(t, err)
+	require.Equal(t, "Imported wallet \"test wallet\"\n", out)
+	out, err = runCommand(t, "account", "list", "--base-dir", filepath.Join(dir, "imported"), "--wallet", "test wallet")
+	require.NoError(t, err)
+	require.Len(t, strings.Split(strings.TrimSpace(out), "\n"), 2)
+}
+
+func TestBulkImport(t *testing.T) {
+	dir := t.TempDir()
+	store := []string{"--base-dir", filepath.Join(dir, "store"), "--wallet", "test wallet"}
+	withStore := func(args ...string) []string {
+		return append(append([]string{}, args...), store...)
+	}
+	_, err := runCommand(t, withStore("wallet", "create")...)
+	require.NoError(t, err)
+
+	outputs := filepath.Join(dir, "outputs")
+	require.NoError(t, os.Mkdir(outputs, 0o700))
+	writeDKGOutput(t, filepath.Join(outputs, "validator-1.json"), "")
+	writeDKGOutput(t, filepath.Join(outputs, "validator-2.json"), "")
+
+	_, err = runCommand(t, withStore("account", "bulk-import", "--passphrase", "pass")...)
+	require.EqualError(t, err, "one of --manifest and --dir is required")
+
+	out, err := runCommand(t, withStore("account", "bulk-import", "--dir", outputs, "--passphrase", "pass")...)
+	require.NoError(t, err)
+	require.Equal(t, "validator-1.json: imported account \"validator-1\"\nvalidator-2.json: imported account \"validator-2\"\n", out)
+
+	out, err = runCommand(t, withStore("account", "bulk-import", "--dir", outputs, "--passphrase", "pass")...)
+	require.EqualError(t, err, "failed to import 2 of 2 accounts")
+	require.Contains(t, out, "validator-1.json: failed to import account \"validator-1\": account with name \"validator-1\" already exists\n")
+
+	out, err = runCommand(t, withStore("account", "list")...)
+	require.NoError(t, err)
+	require.Len(t, strings.Split(strings.TrimSpace(out), "\n"), 2)
+}
+
+func TestBatchVerifyRepair(t *testing.T) {
+	dir := t.TempDir()
+	store := []string{"--base-dir", filepath.Join(dir, "store"), "--wallet", "test wallet"}
+	withStore := func(args ...string) []string {
+		return append(append([]string{}, args...), store...)
+	}
+	_, err := runCommand(t, withStore("wallet", "create")...)
+	require.NoError(t, err)
+	dkgOutput := filepath.Join(dir, "dkg.json")
+	writeDKGOutput(t, dkgOutput, "Account 1")
+	_, err = runCommand(t, withStore("account", "import", "--file", dkgOutput, "--passphrase", "pass")...)
+	require.NoError(t, err)
+
+	// Damage the accounts index, so that the account is orphaned.
+	fsStore := filesystem.New(filesystem.WithLocation(filepath.Join(dir, "store")))
+	walletData, err := fsStore.RetrieveWallet("test wallet")
+	require.NoError(t, err)
+	walletInfo := &struct {
+		ID uuid.UUID `json:"uuid"`
+	}{}
+	require.NoError(t, json.Unmarshal(walletData, walletInfo))
+	require.NoError(t, fsStore.StoreAccountsIndex(walletInfo.ID, []byte("[]")))
+
+	out, err := runCommand(t, withStore("batch", "verify")...)
+	require.EqualError(t, err, "wallet has 1 outstanding problems")
+	require.Contains(t, out, `orphaned account: account "Account 1": account is not present in the index`)
+
+	out, err = runCommand(t, withStore("batch", "verify", "--repair")...)
+	require.NoError(t, err)
+	require.Contains(t, out, `orphaned account: account "Account 1": account is not present in the index (repaired)`)
+
+	out, err = runCommand(t, withStore("batch", "verify")...)
+	require.NoError(t, err)
+	require.Equal(t, "Verified 1 accounts\n", out)
+}
+
+func TestMalformedAccounts(t *testing.T) {
+	dir := t.TempDir()
+	store := []string{"--base-dir", filepath.Join(dir, "store"), "--wallet", "test wallet"}
+	withStore := func(args ...string) []string {
+		return append(append([]string{}, args...), store...)
+	}
+	_, err := runCommand(t, withStore("wallet", "create")...)
+	require.NoError(t, err)
+	dkgOutput := filepath.Join(dir, "dkg.json")
+	ids := make(map[string]uuid.UUID)
+	for _, name := range []string{"Account 1", "Account 2", "Account 3"} {
+		writeDKGOutput(t, dkgOutput, name)
+		_, err = runCommand(t, withStore("account", "import", "--file", dkgOutput, "--passphrase", "pass")...)
+		require.NoError(t, err)
+		out, err := runCommand(t, withStore("account", "show", "--account", name)...)
+		require.NoError(t, err)
+		for _, line := range strings.Split(out, "\n") {
+			if strings.HasPrefix(line, "ID: ") {
+				ids[name] = uuid.MustParse(strings.TrimPrefix(line, "ID: "))
+			}
+		}
+	}
+
+	// Remove the verification vector of one account, and make another unreadable.
+	fsStore := filesystem.New(filesystem.WithLocation(filepath.Join(dir, "store")))
+	walletData, err := fsStore.RetrieveWallet("test wallet")
+	require.NoError(t, err)
+	walletInfo := &struct {
+		ID uuid.UUID `json:"uuid"`
+	}{}
+	require.NoError(t, json.Unmarshal(walletData, walletInfo))
+	data, err := fsStore.RetrieveAccount(walletInfo.ID, ids["Account 2"])
+	require.NoError(t, err)
+	stored := make(map[string]any)
+	require.NoError(t, json.Unmarshal(data, &stored))
+	stored["verificationvector"] = []string{}
+	data, err = json.Marshal(stored)
+	require.NoError(t, err)
+	require.NoError(t, fsStore.StoreAccount(walletInfo.ID, ids["Account 2"], data))
+	require.NoError(t, fsStore.StoreAccount(walletInfo.ID, ids["Account 3"], []byte("{}")))
+
+	out, err := runCommand(t, withStore("account", "list")...)
+	require.EqualError(t, err, "failed to list 2 of 3 accounts")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 3)
+	require.True(t, strings.HasPrefix(lines[0], "Account 1\t0x"))
+	require.Equal(t, `Account 2	error: failed to obtain composite public key for account "Account 2": account has no verification vector`, lines[1])
+	require.True(t, strings.HasPrefix(lines[2], "Account 3\terror: "))
+
+	_, err = runCommand(t, withStore("account", "show", "--account", "Account 2")...)
+	require.EqualError(t, err, `failed to obtain composite public key for account "Account 2": account has no verification vector`)
+}
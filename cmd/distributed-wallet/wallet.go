@@ -0,0 +1,93 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+)
+
+// walletCreate creates a wallet.
+func walletCreate(ctx context.Context, args []string, out io.Writer) error {
+	fs, common := newFlagSet("wallet create")
+	if err := parse(fs, args, "wallet"); err != nil {
+		return err
+	}
+
+	wallet, err := distributed.CreateWallet(ctx, common.wallet, common.store(), keystorev4.New())
+	if err != nil {
+		return errors.Wrap(err, "failed to create wallet")
+	}
+	fmt.Fprintf(out, "Created wallet %q with ID %s\n", wallet.Name(), wallet.ID())
+
+	return nil
+}
+
+// walletExport exports a wallet to a file.
+func walletExport(ctx context.Context, args []string, out io.Writer) error {
+	fs, common := newFlagSet("wallet export")
+	file := fs.String("file", "", "file to which to write the export")
+	passphrase := fs.String("export-passphrase", "", "passphrase with which to protect the export")
+	includeBatch := fs.Bool("batch", false, "include the wallet's batch in the export")
+	if err := parse(fs, args, "wallet", "file", "export-passphrase"); err != nil {
+		return err
+	}
+
+	wallet, err := common.openWallet(ctx)
+	if err != nil {
+		return err
+	}
+	opts := make([]distributed.ExportOption, 0)
+	if *includeBatch {
+		opts = append(opts, distributed.WithBatch())
+	}
+	data, err := wallet.(distributed.WalletOptionsExporter).ExportWithOptions(ctx, []byte(*passphrase), opts...)
+	if err != nil {
+		return errors.Wrap(err, "failed to export wallet")
+	}
+	if err := os.WriteFile(*file, data, 0o600); err != nil {
+		return errors.Wrap(err, "failed to write export")
+	}
+	fmt.Fprintf(out, "Exported wallet %q to %s\n", wallet.Name(), *file)
+
+	return nil
+}
+
+// walletImport imports a wallet from a file.
+func walletImport(ctx context.Context, args []string, out io.Writer) error {
+	fs, common := newFlagSet("wallet import")
+	file := fs.String("file", "", "file from which to read the export")
+	passphrase := fs.String("export-passphrase", "", "passphrase protecting the export")
+	if err := parse(fs, args, "file", "export-passphrase"); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return errors.Wrap(err, "failed to read export")
+	}
+	wallet, err := distributed.Import(ctx, data, []byte(*passphrase), common.store(), keystorev4.New())
+	if err != nil {
+		return errors.Wrap(err, "failed to import wallet")
+	}
+	fmt.Fprintf(out, "Imported wallet %q\n", wallet.Name())
+
+	return nil
+}
@@ -0,0 +1,244 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is matched by errors when a wallet, account or batch cannot be found.
+	ErrNotFound = errors.New("not found")
+	// ErrAlreadyExists is matched by errors when a wallet or account already exists.
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrLocked is matched by errors when a wallet or account is locked.
+	ErrLocked = errors.New("locked")
+	// ErrWrongPassphrase is matched by errors when a passphrase is incorrect.
+	ErrWrongPassphrase = errors.New("incorrect passphrase")
+	// ErrCorruptData is matched by errors when stored data is corrupt.
+	ErrCorruptData = errors.New("corrupt data")
+	// ErrUnsupportedVersion is matched by errors when data has an unsupported version.
+	ErrUnsupportedVersion = errors.New("unsupported version")
+	// ErrWatchOnly is matched by errors when an operation requires a secret key that a watch-only account does not hold.
+	ErrWatchOnly = errors.New("watch-only")
+)
+
+// baseError provides the message and underlying error for typed errors.
+type baseError struct {
+	msg string
+	err error
+}
+
+// Error implements error.
+func (e *baseError) Error() string {
+	if e.err == nil {
+		return e.msg
+	}
+
+	return fmt.Sprintf("%s: %v", e.msg, e.err)
+}
+
+// Unwrap provides the underlying error.
+func (e *baseError) Unwrap() error {
+	return e.err
+}
+
+// NotFoundError is returned when a wallet, account or batch cannot be found.
+type NotFoundError struct {
+	baseError
+	// Kind is the kind of item, "wallet", "account" or "batch".
+	Kind string
+	// Item is the name or ID of the item; for a batch this is the name of its wallet.
+	Item string
+}
+
+// Is returns true if the target is ErrNotFound.
+func (*NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// AlreadyExistsError is returned when a wallet or account already exists.
+type AlreadyExistsError struct {
+	baseError
+	// Kind is the kind of item, "wallet" or "account".
+	Kind string
+	// Item is the name or ID of the item.
+	Item string
+}
+
+// Is returns true if the target is ErrAlreadyExists.
+func (*AlreadyExistsError) Is(target error) bool {
+	return target == ErrAlreadyExists
+}
+
+// LockedError is returned when an operation requires a wallet or account to be unlocked.
+type LockedError struct {
+	baseError
+	// Kind is the kind of item, "wallet" or "account".
+	Kind string
+	// Item is the name of the item.
+	Item string
+}
+
+// Is returns true if the target is ErrLocked.
+func (*LockedError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+// WrongPassphraseError is returned when a passphrase is incorrect.
+type WrongPassphraseError struct {
+	baseError
+}
+
+// Is returns true if the target is ErrWrongPassphrase.
+func (*WrongPassphraseError) Is(target error) bool {
+	return target == ErrWrongPassphrase
+}
+
+// CorruptDataError is returned when stored data is corrupt.
+type CorruptDataError struct {
+	baseError
+}
+
+// Is returns true if the target is ErrCorruptData.
+func (*CorruptDataError) Is(target error) bool {
+	return target == ErrCorruptData
+}
+
+// UnsupportedVersionError is returned when data has an unsupported version.
+type UnsupportedVersionError struct {
+	baseError
+	// Version is the unsupported version.
+	Version uint
+}
+
+// Is returns true if the target is ErrUnsupportedVersion.
+func (*UnsupportedVersionError) Is(target error) bool {
+	return target == ErrUnsupportedVersion
+}
+
+// WatchOnlyError is returned when an operation requires a secret key that a watch-only account does not hold.
+type WatchOnlyError struct {
+	baseError
+	// Item is the name of the account.
+	Item string
+}
+
+// Is returns true if the target is ErrWatchOnly.
+func (*WatchOnlyError) Is(target error) bool {
+	return target == ErrWatchOnly
+}
+
+func newNotFoundError(kind string, item string, err error, format string, args ...any) error {
+	return &NotFoundError{
+		baseError: baseError{msg: fmt.Sprintf(format, args...), err: err},
+		Kind:      kind,
+		Item:      item,
+	}
+}
+
+// incorrectPassphrase returns true if an error from an encryptor is due to an incorrect passphrase.
+// The encryptor reports an incorrect passphrase as a checksum mismatch; any other error means that
+// the encrypted data itself could not be used.
+func incorrectPassphrase(err error) bool {
+	return err.Error() == "invalid checksum"
+}
+
+// missingBatchError returns true if an error from retrieving a batch shows that the store does not
+// hold a batch for the wallet.  Stores do not share an error for this: the filesystem store wraps the
+// fs.ErrNotExist from reading the batch file, and the scratch store returns "no batch".
+func missingBatchError(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || strings.Contains(err.Error(), "no batch")
+}
+
+// unavailableKeyWrapperError is returned when data is protected by a key wrapper that is not available.
+type unavailableKeyWrapperError struct {
+	msg string
+}
+
+// Error implements error.
+func (e *unavailableKeyWrapperError) Error() string {
+	return e.msg
+}
+
+// unmarshalError wraps an error from unmarshalling stored data.  The data is corrupt unless it could
+// not be used only because its key wrapper is unavailable.
+func unmarshalError(err error, msg string) error {
+	var unavailable *unavailableKeyWrapperError
+	if errors.As(err, &unavailable) {
+		return errors.Wrap(err, msg)
+	}
+
+	return newCorruptDataError(err, msg)
+}
+
+// exportDecryptError classifies an error from decrypting an export.  The export codec reports an
+// incorrect passphrase as "invalid key" and an unknown format as "unhandled version"; any other error
+// means that the export is truncated or otherwise malformed.
+func exportDecryptError(encryptedData []byte, err error) error {
+	switch {
+	case err.Error() == "invalid key":
+		return newWrongPassphraseError(err, "failed to decrypt wallet")
+	case strings.HasPrefix(err.Error(), "unhandled version"):
+		return newUnsupportedVersionError(uint(encryptedData[0]), fmt.Sprintf("unsupported export version %d", encryptedData[0]))
+	default:
+		return newCorruptDataError(err, "failed to decrypt wallet")
+	}
+}
+
+func newAlreadyExistsError(kind string, item string, format string, args ...any) error {
+	return &AlreadyExistsError{
+		baseError: baseError{msg: fmt.Sprintf(format, args...)},
+		Kind:      kind,
+		Item:      item,
+	}
+}
+
+func newLockedError(kind string, item string, msg string) error {
+	return &LockedError{
+		baseError: baseError{msg: msg},
+		Kind:      kind,
+		Item:      item,
+	}
+}
+
+func newWrongPassphraseError(err error, msg string) error {
+	return &WrongPassphraseError{
+		baseError: baseError{msg: msg, err: err},
+	}
+}
+
+func newCorruptDataError(err error, msg string) error {
+	return &CorruptDataError{
+		baseError: baseError{msg: msg, err: err},
+	}
+}
+
+func newUnsupportedVersionError(version uint, msg string) error {
+	return &UnsupportedVersionError{
+		baseError: baseError{msg: msg},
+		Version:   version,
+	}
+}
+
+func newWatchOnlyError(item string, msg string) error {
+	return &WatchOnlyError{
+		baseError: baseError{msg: msg},
+		Item:      item,
+	}
+}
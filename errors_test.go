@@ -0,0 +1,155 @@
+// Copyright © 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	scratch "github.com/wealdtech/go-eth2-wallet-store-scratch"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+func TestErrors(t *testing.T) {
+	ctx := context.Background()
+	store := scratch.New()
+	encryptor := keystorev4.New()
+
+	_, err := distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+	require.ErrorIs(t, err, distributed.ErrNotFound)
+	var notFoundErr *distributed.NotFoundError
+	require.ErrorAs(t, err, &notFoundErr)
+	require.Equal(t, "wallet", notFoundErr.Kind)
+	require.Equal(t, "test wallet", notFoundErr.Item)
+
+	wallet, err := distributed.CreateWallet(ctx, "test wallet", store, encryptor)
+	require.NoError(t, err)
+	_, err = distributed.CreateWallet(ctx, "test wallet", store, encryptor)
+	require.ErrorIs(t, err, distributed.ErrAlreadyExists)
+	require.EqualError(t, err, `wallet "test wallet" already exists`)
+
+	privKey := _byteArray("01e748d098d3bcb477d636f19d510399ae18205fadf9814ee67052f88c1f77c0")
+	vvec := [][]byte{
+		_byteArray("a0633864987df6f7a0f40fbecbe0d15fe5317c00adccc0b816266bcf3d3d1ab6a365b3d79461b5da5a8ea09e37644731"),
+		_byteArray("a7430ae4717f511e473ed87a6510c5dfdbf289b7c7c4e083270da487aa146b0291ee701e0c38033aa157a612b8fd488b"),
+		_byteArray("b5e95fbcf45c9f2730f04abab22672dddca4b10e32467ab8522d7d153a382c9656d28268b5e75361e4e39a5d12513d8c"),
+	}
+	participants := map[uint64]string{1: "foo", 2: "bar", 3: "baz"}
+	importer := wallet.(e2wtypes.WalletDistributedAccountImporter)
+
+	_, err = importer.ImportDistributedAccount(ctx, "Account 1", privKey, 3, vvec, participants, []byte("pass"))
+	require.ErrorIs(t, err, distributed.ErrLocked)
+	var lockedErr *distributed.LockedError
+	require.ErrorAs(t, err, &lockedErr)
+	require.Equal(t, "wallet", lockedErr.Kind)
+
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+	account, err := importer.ImportDistributedAccount(ctx, "Account 1", privKey, 3, vvec, participants, []byte("pass"))
+	require.NoError(t, err)
+	_, err = importer.ImportDistributedAccount(ctx, "Account 1", privKey, 3, vvec, participants, []byte("pass"))
+	require.ErrorIs(t, err, distributed.ErrAlreadyExists)
+
+	_, err = wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 2")
+	require.ErrorIs(t, err, distributed.ErrNotFound)
+	require.EqualError(t, err, `no account with name "Account 2"`)
+
+	_, err = account.(e2wtypes.AccountSigner).Sign(ctx, []byte("data"))
+	require.ErrorIs(t, err, distributed.ErrLocked)
+
+	err = account.(e2wtypes.AccountLocker).Unlock(ctx, []byte("wrong"))
+	require.ErrorIs(t, err, distributed.ErrWrongPassphrase)
+	require.False(t, errors.Is(err, distributed.ErrCorruptData))
+
+	dump, err := wallet.(e2wtypes.WalletExporter).Export(ctx, []byte("dump"))
+	require.NoError(t, err)
+	_, err = distributed.Import(ctx, dump, []byte("wrong"), scratch.New(), encryptor)
+	require.ErrorIs(t, err, distributed.ErrWrongPassphrase)
+	_, err = distributed.Import(ctx, dump[:32], []byte("dump"), scratch.New(), encryptor)
+	require.ErrorIs(t, err, distributed.ErrCorruptData)
+	require.False(t, errors.Is(err, distributed.ErrWrongPassphrase))
+	_, _, err = distributed.MergeImport(ctx, dump[:32], []byte("dump"), store, encryptor, distributed.ConflictSkip)
+	require.ErrorIs(t, err, distributed.ErrCorruptData)
+	versioned := append([]byte{0xff}, dump[1:]...)
+	_, err = distributed.Import(ctx, versioned, []byte("dump"), scratch.New(), encryptor)
+	require.ErrorIs(t, err, distributed.ErrUnsupportedVersion)
+	var exportVersionErr *distributed.UnsupportedVersionError
+	require.ErrorAs(t, err, &exportVersionErr)
+	require.Equal(t, uint(0xff), exportVersionErr.Version)
+	_, _, err = distributed.MergeImport(ctx, versioned, []byte("dump"), store, encryptor, distributed.ConflictSkip)
+	require.ErrorIs(t, err, distributed.ErrUnsupportedVersion)
+
+	// Corrupt the encrypted secret key of the stored account.
+	data, err := store.RetrieveAccount(wallet.ID(), account.ID())
+	require.NoError(t, err)
+	stored := make(map[string]any)
+	require.NoError(t, json.Unmarshal(data, &stored))
+	stored["crypto"].(map[string]any)["cipher"].(map[string]any)["message"] = "invalid"
+	data, err = json.Marshal(stored)
+	require.NoError(t, err)
+	require.NoError(t, store.StoreAccount(wallet.ID(), account.ID(), data))
+	wallet, err = distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+	require.NoError(t, err)
+	account, err = wallet.(e2wtypes.WalletAccountByIDProvider).AccountByID(ctx, account.ID())
+	require.NoError(t, err)
+	err = account.(e2wtypes.AccountLocker).Unlock(ctx, []byte("pass"))
+	require.ErrorIs(t, err, distributed.ErrCorruptData)
+	require.False(t, errors.Is(err, distributed.ErrWrongPassphrase))
+
+	// Corrupt the stored account.
+	require.NoError(t, store.StoreAccount(wallet.ID(), account.ID(), []byte(`{"uuid":"`+account.ID().String()+`","name":"Account 1"}`)))
+	wallet, err = distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+	require.NoError(t, err)
+	_, err = wallet.(e2wtypes.WalletAccountByIDProvider).AccountByID(ctx, account.ID())
+	require.ErrorIs(t, err, distributed.ErrCorruptData)
+}
+
+func TestUnsupportedVersionError(t *testing.T) {
+	ctx := context.Background()
+	store := scratch.New()
+	encryptor := keystorev4.New()
+	wallet, err := distributed.CreateWallet(ctx, "test wallet", store, encryptor)
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+	account, err := wallet.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+		"Account 1",
+		_byteArray("01e748d098d3bcb477d636f19d510399ae18205fadf9814ee67052f88c1f77c0"),
+		3,
+		[][]byte{
+			_byteArray("a0633864987df6f7a0f40fbecbe0d15fe5317c00adccc0b816266bcf3d3d1ab6a365b3d79461b5da5a8ea09e37644731"),
+			_byteArray("a7430ae4717f511e473ed87a6510c5dfdbf289b7c7c4e083270da487aa146b0291ee701e0c38033aa157a612b8fd488b"),
+			_byteArray("b5e95fbcf45c9f2730f04abab22672dddca4b10e32467ab8522d7d153a382c9656d28268b5e75361e4e39a5d12513d8c"),
+		},
+		map[uint64]string{1: "foo", 2: "bar", 3: "baz"},
+		[]byte("pass"))
+	require.NoError(t, err)
+
+	data, err := store.RetrieveAccount(wallet.ID(), account.ID())
+	require.NoError(t, err)
+	data = []byte(strings.Replace(string(data), `"version":4`, `"version":3`, 1))
+	require.NoError(t, store.StoreAccount(wallet.ID(), account.ID(), data))
+
+	wallet, err = distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+	require.NoError(t, err)
+	_, err = wallet.(e2wtypes.WalletAccountByIDProvider).AccountByID(ctx, account.ID())
+	require.ErrorIs(t, err, distributed.ErrUnsupportedVersion)
+	var versionErr *distributed.UnsupportedVersionError
+	require.ErrorAs(t, err, &versionErr)
+	require.Equal(t, uint(3), versionErr.Version)
+}
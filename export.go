@@ -0,0 +1,227 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/wealdtech/go-ecodec"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+// walletExport is the format of an exported wallet.
+type walletExport struct {
+	Wallet   *wallet           `json:"wallet"`
+	Accounts []json.RawMessage `json:"accounts"`
+	Batch    json.RawMessage   `json:"batch,omitempty"`
+}
+
+// exportOptions are the options for exporting a wallet.
+type exportOptions struct {
+	names        []string
+	ids          []uuid.UUID
+	nameRegexes  []*regexp.Regexp
+	includeBatch bool
+}
+
+// ExportOption gives options to ExportWithOptions.
+type ExportOption interface {
+	apply(*exportOptions)
+}
+
+type exportOptionFunc func(*exportOptions)
+
+func (f exportOptionFunc) apply(o *exportOptions) {
+	f(o)
+}
+
+// WithAccountNames selects accounts to export by name.
+func WithAccountNames(names ...string) ExportOption {
+	return exportOptionFunc(func(o *exportOptions) {
+		o.names = append(o.names, names...)
+	})
+}
+
+// WithAccountIDs selects accounts to export by ID.
+func WithAccountIDs(ids ...uuid.UUID) ExportOption {
+	return exportOptionFunc(func(o *exportOptions) {
+		o.ids = append(o.ids, ids...)
+	})
+}
+
+// WithAccountNameRegex selects accounts to export whose names match the regular expression.
+func WithAccountNameRegex(re *regexp.Regexp) ExportOption {
+	return exportOptionFunc(func(o *exportOptions) {
+		o.nameRegexes = append(o.nameRegexes, re)
+	})
+}
+
+// WithBatch includes the wallet's encrypted batch in the export.
+// The batch can only be included when exporting all accounts.
+func WithBatch() ExportOption {
+	return exportOptionFunc(func(o *exportOptions) {
+		o.includeBatch = true
+	})
+}
+
+// selective returns true if the options select a subset of accounts.
+func (o *exportOptions) selective() bool {
+	return len(o.names) > 0 || len(o.ids) > 0 || len(o.nameRegexes) > 0
+}
+
+// selects returns true if the options select the given account.
+func (o *exportOptions) selects(a *account) bool {
+	if !o.selective() {
+		return true
+	}
+	for _, name := range o.names {
+		if a.name == name {
+			return true
+		}
+	}
+	for _, id := range o.ids {
+		if a.id == id {
+			return true
+		}
+	}
+	for _, re := range o.nameRegexes {
+		if re.MatchString(a.name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WalletOptionsExporter is the interface for wallets that can export themselves with options.
+type WalletOptionsExporter interface {
+	// ExportWithOptions exports the wallet, protected by an additional passphrase.
+	ExportWithOptions(ctx context.Context, passphrase []byte, opts ...ExportOption) ([]byte, error)
+}
+
+// Export exports the entire wallet, protected by an additional passphrase.
+func (w *wallet) Export(ctx context.Context, passphrase []byte) ([]byte, error) {
+	return w.ExportWithOptions(ctx, passphrase)
+}
+
+// ExportWithOptions exports the wallet, protected by an additional passphrase.
+// By default all accounts are exported; options can select a subset of accounts
+// and include the wallet's batch.
+func (w *wallet) ExportWithOptions(ctx context.Context, passphrase []byte, opts ...ExportOption) ([]byte, error) {
+	options := &exportOptions{}
+	for _, o := range opts {
+		o.apply(options)
+	}
+	if options.includeBatch && options.selective() {
+		return nil, errors.New("batch can only be exported with all accounts")
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	ext := &walletExport{
+		Wallet:   w,
+		Accounts: make([]json.RawMessage, 0),
+	}
+
+	foundNames := make(map[string]bool)
+	foundIDs := make(map[uuid.UUID]bool)
+	for data := range w.store.RetrieveAccounts(w.ID()) {
+		account, err := deserializeAccount(w, data)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to deserialize account")
+		}
+		if !options.selects(account) {
+			continue
+		}
+		foundNames[account.name] = true
+		foundIDs[account.id] = true
+		accountData, err := json.Marshal(account)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal account")
+		}
+		ext.Accounts = append(ext.Accounts, accountData)
+	}
+	for _, name := range options.names {
+		if !foundNames[name] {
+			return nil, newNotFoundError("account", name, nil, "no account with name %q", name)
+		}
+	}
+	for _, id := range options.ids {
+		if !foundIDs[id] {
+			return nil, newNotFoundError("account", id.String(), nil, "no account with ID %s", id)
+		}
+	}
+
+	if options.includeBatch {
+		batchRetriever, isBatchRetriever := w.store.(e2wtypes.BatchRetriever)
+		if !isBatchRetriever {
+			return nil, fmt.Errorf("store %s cannot retrieve batches", w.store.Name())
+		}
+		batch, err := batchRetriever.RetrieveBatch(ctx, w.id)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to retrieve batch")
+		}
+		ext.Batch = batch
+	}
+
+	data, err := json.Marshal(ext)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal wallet for export")
+	}
+
+	res, err := ecodec.Encrypt(data, passphrase)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encrypt export")
+	}
+
+	return res, nil
+}
+
+// validateBatch confirms that exported batch data is well-formed.
+// Entries for accounts that are not present in the wallet, for example accounts
+// removed since the batch was created, are ignored when the batch is fetched.
+func (w *wallet) validateBatch(data []byte) error {
+	if _, isBatchStorer := w.store.(e2wtypes.BatchStorer); !isBatchStorer {
+		return fmt.Errorf("store %s cannot store batches", w.store.Name())
+	}
+
+	b := &batch{
+		keyWrapper: w.keyWrapper,
+	}
+	if err := json.Unmarshal(data, b); err != nil {
+		return errors.Wrap(err, "failed to unmarshal batch")
+	}
+
+	return nil
+}
+
+// storeBatch stores batch data for the wallet.
+func (w *wallet) storeBatch(ctx context.Context, data []byte) error {
+	batchStorer, isBatchStorer := w.store.(e2wtypes.BatchStorer)
+	if !isBatchStorer {
+		return fmt.Errorf("store %s cannot store batches", w.store.Name())
+	}
+
+	if err := batchStorer.StoreBatch(ctx, w.id, w.name, data); err != nil {
+		return errors.Wrap(err, "failed to store batch")
+	}
+
+	return nil
+}
@@ -15,8 +15,12 @@ package distributed_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
@@ -102,3 +106,256 @@ func TestExportWallet(t *testing.T) {
 	_, err = distributed.Import(context.Background(), dump, []byte("dump"), store2, encryptor)
 	assert.NotNil(t, err)
 }
+
+func TestExportWalletSelective(t *testing.T) {
+	ctx := context.Background()
+	store := scratch.New()
+	encryptor := keystorev4.New()
+	wallet, err := distributed.CreateWallet(ctx, "test wallet", store, encryptor)
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+
+	participants := map[uint64]string{1: "foo", 2: "bar", 3: "baz"}
+	account1, err := wallet.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+		"Account 1",
+		_byteArray("01e748d098d3bcb477d636f19d510399ae18205fadf9814ee67052f88c1f77c0"),
+		3,
+		[][]byte{
+			_byteArray("a0633864987df6f7a0f40fbecbe0d15fe5317c00adccc0b816266bcf3d3d1ab6a365b3d79461b5da5a8ea09e37644731"),
+			_byteArray("a7430ae4717f511e473ed87a6510c5dfdbf289b7c7c4e083270da487aa146b0291ee701e0c38033aa157a612b8fd488b"),
+			_byteArray("b5e95fbcf45c9f2730f04abab22672dddca4b10e32467ab8522d7d153a382c9656d28268b5e75361e4e39a5d12513d8c"),
+		},
+		participants,
+		[]byte("pass"))
+	require.NoError(t, err)
+	account2, err := wallet.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+		"Account 2",
+		_byteArray("376880b8079dca3bbd06c93958b5208929cbc169c9ce4caf8731be10e94f710e"),
+		3,
+		[][]byte{
+			_byteArray("a0633864987df6f7a0f40fbecbe0d15fe5317c00adccc0b816266bcf3d3d1ab6a365b3d79461b5da5a8ea09e37644731"),
+			_byteArray("b13d6e14cce66b3827b816c974e8f52a76e86611de58bbcdac116a9e97b00240a29714646202a65ae72df480bdfa5329"),
+			_byteArray("81a00aee312320aa82316ea14b6615eb56f531ecdabc1effca2a55d0282f3c2463124b792da5ec0207d16119360bd896"),
+		},
+		participants,
+		[]byte("pass"))
+	require.NoError(t, err)
+
+	exporter := wallet.(distributed.WalletOptionsExporter)
+
+	tests := []struct {
+		name     string
+		opts     []distributed.ExportOption
+		accounts []string
+		err      string
+	}{
+		{
+			name:     "All",
+			accounts: []string{"Account 1", "Account 2"},
+		},
+		{
+			name:     "ByName",
+			opts:     []distributed.ExportOption{distributed.WithAccountNames("Account 1")},
+			accounts: []string{"Account 1"},
+		},
+		{
+			name:     "ByID",
+			opts:     []distributed.ExportOption{distributed.WithAccountIDs(account2.ID())},
+			accounts: []string{"Account 2"},
+		},
+		{
+			name:     "ByRegex",
+			opts:     []distributed.ExportOption{distributed.WithAccountNameRegex(regexp.MustCompile(`^Account [12]$`))},
+			accounts: []string{"Account 1", "Account 2"},
+		},
+		{
+			name: "NameUnknown",
+			opts: []distributed.ExportOption{distributed.WithAccountNames("Account 3")},
+			err:  `no account with name "Account 3"`,
+		},
+		{
+			name: "BatchWithSelection",
+			opts: []distributed.ExportOption{distributed.WithAccountNames("Account 1"), distributed.WithBatch()},
+			err:  "batch can only be exported with all accounts",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dump, err := exporter.ExportWithOptions(ctx, []byte("dump"), test.opts...)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+
+			wallet2, err := distributed.Import(ctx, dump, []byte("dump"), scratch.New(), encryptor)
+			require.NoError(t, err)
+			names := make([]string, 0)
+			for account := range wallet2.Accounts(ctx) {
+				names = append(names, account.Name())
+			}
+			require.ElementsMatch(t, test.accounts, names)
+		})
+	}
+
+	// Ensure the ID of the first account is unchanged after a selective export.
+	dump, err := exporter.ExportWithOptions(ctx, []byte("dump"), distributed.WithAccountIDs(account1.ID()))
+	require.NoError(t, err)
+	wallet2, err := distributed.Import(ctx, dump, []byte("dump"), scratch.New(), encryptor)
+	require.NoError(t, err)
+	_, err = wallet2.(e2wtypes.WalletAccountByIDProvider).AccountByID(ctx, account1.ID())
+	require.NoError(t, err)
+}
+
+func TestExportWalletWithBatch(t *testing.T) {
+	ctx := context.Background()
+	store := scratch.New()
+	encryptor := keystorev4.New()
+	wallet, err := distributed.CreateWallet(ctx, "test wallet", store, encryptor)
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+
+	_, err = wallet.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+		"Account 1",
+		_byteArray("01e748d098d3bcb477d636f19d510399ae18205fadf9814ee67052f88c1f77c0"),
+		3,
+		[][]byte{
+			_byteArray("a0633864987df6f7a0f40fbecbe0d15fe5317c00adccc0b816266bcf3d3d1ab6a365b3d79461b5da5a8ea09e37644731"),
+			_byteArray("a7430ae4717f511e473ed87a6510c5dfdbf289b7c7c4e083270da487aa146b0291ee701e0c38033aa157a612b8fd488b"),
+			_byteArray("b5e95fbcf45c9f2730f04abab22672dddca4b10e32467ab8522d7d153a382c9656d28268b5e75361e4e39a5d12513d8c"),
+		},
+		map[uint64]string{1: "foo", 2: "bar", 3: "baz"},
+		[]byte("pass"))
+	require.NoError(t, err)
+
+	// Batch not present yet.
+	_, err = wallet.(distributed.WalletOptionsExporter).ExportWithOptions(ctx, []byte("dump"), distributed.WithBatch())
+	require.ErrorContains(t, err, "failed to retrieve batch")
+
+	require.NoError(t, wallet.(e2wtypes.WalletBatchCreator).BatchWallet(ctx, []string{"pass"}, "batch passphrase"))
+	dump, err := wallet.(distributed.WalletOptionsExporter).ExportWithOptions(ctx, []byte("dump"), distributed.WithBatch())
+	require.NoError(t, err)
+
+	store2 := scratch.New()
+	_, err = distributed.Import(ctx, dump, []byte("dump"), store2, encryptor)
+	require.NoError(t, err)
+
+	// Re-open the imported wallet and unlock the account with the batch passphrase.
+	wallet2, err := distributed.OpenWallet(ctx, "test wallet", store2, encryptor)
+	require.NoError(t, err)
+	account, err := wallet2.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 1")
+	require.NoError(t, err)
+	require.NoError(t, account.(e2wtypes.AccountLocker).Unlock(ctx, []byte("batch passphrase")))
+}
+
+// faultyStore is a store that can be told to fail when storing accounts.
+type faultyStore struct {
+	e2wtypes.Store
+	failAccounts bool
+}
+
+func (s *faultyStore) StoreAccount(walletID uuid.UUID, accountID uuid.UUID, data []byte) error {
+	if s.failAccounts {
+		return errors.New("store failure")
+	}
+
+	return s.Store.StoreAccount(walletID, accountID, data)
+}
+
+// removableStore is a faulty store that supports removal of wallets.
+type removableStore struct {
+	faultyStore
+	removedWallets map[uuid.UUID]bool
+}
+
+func (s *removableStore) RemoveWallet(walletID uuid.UUID) error {
+	s.removedWallets[walletID] = true
+
+	return nil
+}
+
+func (s *removableStore) RetrieveWallet(walletName string) ([]byte, error) {
+	data, err := s.Store.RetrieveWallet(walletName)
+	if err != nil {
+		return nil, err
+	}
+	info := &struct {
+		ID uuid.UUID `json:"uuid"`
+	}{}
+	if err := json.Unmarshal(data, info); err != nil {
+		return nil, err
+	}
+	if s.removedWallets[info.ID] {
+		return nil, errors.New("wallet not found")
+	}
+
+	return data, nil
+}
+
+func TestImportAtomic(t *testing.T) {
+	ctx := context.Background()
+	encryptor := keystorev4.New()
+	wallet, err := distributed.CreateWallet(ctx, "test wallet", scratch.New(), encryptor)
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+	_, err = wallet.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+		"Account 1",
+		_byteArray("01e748d098d3bcb477d636f19d510399ae18205fadf9814ee67052f88c1f77c0"),
+		3,
+		[][]byte{
+			_byteArray("a0633864987df6f7a0f40fbecbe0d15fe5317c00adccc0b816266bcf3d3d1ab6a365b3d79461b5da5a8ea09e37644731"),
+			_byteArray("a7430ae4717f511e473ed87a6510c5dfdbf289b7c7c4e083270da487aa146b0291ee701e0c38033aa157a612b8fd488b"),
+			_byteArray("b5e95fbcf45c9f2730f04abab22672dddca4b10e32467ab8522d7d153a382c9656d28268b5e75361e4e39a5d12513d8c"),
+		},
+		map[uint64]string{1: "foo", 2: "bar", 3: "baz"},
+		[]byte("pass"))
+	require.NoError(t, err)
+	dump, err := wallet.(e2wtypes.WalletExporter).Export(ctx, []byte("dump"))
+	require.NoError(t, err)
+
+	t.Run("IncompleteRetry", func(t *testing.T) {
+		// The store cannot remove wallets, so the partial wallet is left behind.
+		store := &faultyStore{Store: scratch.New(), failAccounts: true}
+		_, err := distributed.Import(ctx, dump, []byte("dump"), store, encryptor)
+		require.EqualError(t, err, `incomplete wallet "test wallet" left in store; import it again or delete it from the store: failed to store account "Account 1": store failure`)
+
+		// The partial wallet cannot be opened.
+		_, err = distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+		require.ErrorIs(t, err, distributed.ErrCorruptData)
+		require.EqualError(t, err, `wallet "test wallet" is incomplete; import it again or delete it from the store`)
+
+		// The import can be retried.
+		store.failAccounts = false
+		wallet2, err := distributed.Import(ctx, dump, []byte("dump"), store, encryptor)
+		require.NoError(t, err)
+		_, err = wallet2.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 1")
+		require.NoError(t, err)
+		_, err = distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+		require.NoError(t, err)
+
+		// The completed wallet cannot be imported again.
+		_, err = distributed.Import(ctx, dump, []byte("dump"), store, encryptor)
+		require.EqualError(t, err, `wallet "test wallet" already exists`)
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		store := &removableStore{
+			faultyStore:    faultyStore{Store: scratch.New(), failAccounts: true},
+			removedWallets: make(map[uuid.UUID]bool),
+		}
+		_, err := distributed.Import(ctx, dump, []byte("dump"), store, encryptor)
+		require.EqualError(t, err, `failed to store account "Account 1": store failure`)
+		require.Len(t, store.removedWallets, 1)
+		_, err = distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+		require.ErrorContains(t, err, "wallet not found")
+	})
+
+	t.Run("ExistingWallet", func(t *testing.T) {
+		store := scratch.New()
+		_, err := distributed.CreateWallet(ctx, "test wallet", store, encryptor)
+		require.NoError(t, err)
+		_, err = distributed.Import(ctx, dump, []byte("dump"), store, encryptor)
+		require.EqualError(t, err, `wallet "test wallet" already exists`)
+	})
+}
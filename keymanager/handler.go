@@ -0,0 +1,331 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package keymanager provides the Ethereum keymanager API for distributed wallets.
+package keymanager
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+// KeystoresPath is the path of the keystores endpoint.
+const KeystoresPath = "/eth/v1/keystores"
+
+// maxRequestSize is the maximum size of a request body.
+const maxRequestSize = 16 * 1024 * 1024
+
+// SlashingProtection stores slashing protection data for validators, identified by their
+// composite public keys.
+type SlashingProtection interface {
+	// Import imports slashing protection data in EIP-3076 interchange format.
+	Import(ctx context.Context, interchange []byte) error
+	// Export exports slashing protection data for the given validators in EIP-3076 interchange format.
+	Export(ctx context.Context, pubKeys [][]byte) ([]byte, error)
+}
+
+// Wallet is the interface required of wallets managed by the handler.
+type Wallet interface {
+	e2wtypes.Wallet
+	distributed.WalletKeystoreImporter
+	distributed.WalletAccountRemover
+	distributed.WalletAccountByPublicKeyProvider
+	distributed.WalletAccountByCompositePublicKeyProvider
+}
+
+// Handler implements the keystore endpoints of the Ethereum keymanager API for a distributed wallet.
+// Validators are identified by the composite public keys of the wallet's accounts.
+//
+// Keystores are imported with ImportKeystore, so each keystore requires the distributed metadata
+// sidecar created alongside it by ExportKeystore.  These are supplied in the "sidecars" field of
+// the import request, in the same order as the keystores.  Deleting a keystore removes its
+// account from the wallet.  The wallet must be unlocked to import and delete keystores.
+type Handler struct {
+	wallet             Wallet
+	bearerTokens       [][]byte
+	slashingProtection SlashingProtection
+	accountPassphrase  []byte
+	// mutex serializes requests, as wallets do not support concurrent modification.
+	mutex sync.Mutex
+}
+
+// New creates a new keymanager API handler for the wallet.
+// At least one bearer token and a slashing protection store must be supplied.
+func New(wallet e2wtypes.Wallet, opts ...Option) (*Handler, error) {
+	options := parseOptions(opts)
+
+	managedWallet, isManagedWallet := wallet.(Wallet)
+	if !isManagedWallet {
+		return nil, errors.New("wallet does not support key management")
+	}
+	if len(options.bearerTokens) == 0 {
+		return nil, errors.New("no bearer tokens supplied")
+	}
+	if options.slashingProtection == nil {
+		return nil, errors.New("no slashing protection supplied")
+	}
+
+	h := &Handler{
+		wallet:             managedWallet,
+		slashingProtection: options.slashingProtection,
+		accountPassphrase:  options.accountPassphrase,
+	}
+	for _, token := range options.bearerTokens {
+		if token == "" {
+			return nil, errors.New("bearer token empty")
+		}
+		h.bearerTokens = append(h.bearerTokens, []byte(token))
+	}
+
+	return h, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(r) {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+
+		return
+	}
+	if r.URL.Path != KeystoresPath {
+		writeError(w, http.StatusNotFound, "not found")
+
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	switch r.Method {
+	case http.MethodGet:
+		h.handleList(w, r)
+	case http.MethodPost:
+		h.handleImport(w, r)
+	case http.MethodDelete:
+		h.handleDelete(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// authenticated returns true if the request is authenticated.
+func (h *Handler) authenticated(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	token := []byte(strings.TrimPrefix(auth, "Bearer "))
+	for _, bearerToken := range h.bearerTokens {
+		if subtle.ConstantTimeCompare(token, bearerToken) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// handleList handles a request to list keystores.
+func (h *Handler) handleList(w http.ResponseWriter, r *http.Request) {
+	res := &listResponseJSON{
+		Data: make([]*keystoreJSON, 0),
+	}
+	for account := range h.wallet.Accounts(r.Context()) {
+		provider, isProvider := account.(e2wtypes.AccountCompositePublicKeyProvider)
+		if !isProvider || provider.CompositePublicKey() == nil {
+			continue
+		}
+		// Watch-only accounts cannot sign, so are reported as read-only.
+		watchOnlyProvider, isWatchOnlyProvider := account.(distributed.AccountWatchOnlyProvider)
+		res.Data = append(res.Data, &keystoreJSON{
+			ValidatingPubkey: fmt.Sprintf("%#x", provider.CompositePublicKey().Marshal()),
+			Readonly:         isWatchOnlyProvider && watchOnlyProvider.WatchOnly(),
+		})
+	}
+	sort.Slice(res.Data, func(i, j int) bool { return res.Data[i].ValidatingPubkey < res.Data[j].ValidatingPubkey })
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleImport handles a request to import keystores.
+func (h *Handler) handleImport(w http.ResponseWriter, r *http.Request) {
+	req := &importRequestJSON{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+
+		return
+	}
+	if len(req.Passwords) != len(req.Keystores) {
+		writeError(w, http.StatusBadRequest, "invalid request: keystores and passwords must have the same length")
+
+		return
+	}
+	if len(req.Sidecars) != len(req.Keystores) {
+		writeError(w, http.StatusBadRequest, "invalid request: keystores and sidecars must have the same length")
+
+		return
+	}
+
+	res := &importResponseJSON{
+		Data: make([]*statusJSON, len(req.Keystores)),
+	}
+
+	// Slashing protection data is imported before any keystores, so that keys are never
+	// available for signing without their slashing protection data.
+	if req.SlashingProtection != "" {
+		if err := h.slashingProtection.Import(r.Context(), []byte(req.SlashingProtection)); err != nil {
+			for i := range res.Data {
+				res.Data[i] = &statusJSON{
+					Status:  statusError,
+					Message: fmt.Sprintf("failed to import slashing protection data: %v", err),
+				}
+			}
+			writeJSON(w, http.StatusOK, res)
+
+			return
+		}
+	}
+
+	for i := range req.Keystores {
+		res.Data[i] = h.importKeystore(r.Context(), []byte(req.Keystores[i]), []byte(req.Sidecars[i]), []byte(req.Passwords[i]))
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+// importKeystore imports a single keystore.
+func (h *Handler) importKeystore(ctx context.Context, keystore []byte, sidecar []byte, password []byte) *statusJSON {
+	if h.duplicate(ctx, keystore, sidecar) {
+		return &statusJSON{Status: statusDuplicate}
+	}
+
+	passphrase := h.accountPassphrase
+	if passphrase == nil {
+		passphrase = password
+	}
+	if _, err := h.wallet.ImportKeystore(ctx, "", keystore, sidecar, password, passphrase); err != nil {
+		return &statusJSON{Status: statusError, Message: err.Error()}
+	}
+
+	return &statusJSON{Status: statusImported}
+}
+
+// duplicate returns true if the wallet already holds the share in the keystore,
+// or a share of the same distributed account.
+func (h *Handler) duplicate(ctx context.Context, keystore []byte, sidecar []byte) bool {
+	ks := &keystorePubkeyJSON{}
+	if err := json.Unmarshal(keystore, ks); err == nil {
+		if pubKey, err := hex.DecodeString(strings.TrimPrefix(ks.Pubkey, "0x")); err == nil && len(pubKey) > 0 {
+			if _, err := h.wallet.AccountByPublicKey(ctx, pubKey); err == nil {
+				return true
+			}
+		}
+	}
+
+	sc := &sidecarJSON{}
+	if err := json.Unmarshal(sidecar, sc); err == nil {
+		if compositePubKey, err := hex.DecodeString(strings.TrimPrefix(sc.CompositePubkey, "0x")); err == nil && len(compositePubKey) > 0 {
+			if _, err := h.wallet.AccountByCompositePublicKey(ctx, compositePubKey); err == nil {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// handleDelete handles a request to delete keystores.
+func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	req := &deleteRequestJSON{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+
+		return
+	}
+	pubKeys := make([][]byte, len(req.Pubkeys))
+	for i := range req.Pubkeys {
+		var err error
+		pubKeys[i], err = hex.DecodeString(strings.TrimPrefix(req.Pubkeys[i], "0x"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: invalid public key %s", req.Pubkeys[i]))
+
+			return
+		}
+	}
+
+	res := &deleteResponseJSON{
+		Data: make([]*statusJSON, len(pubKeys)),
+	}
+	accounts := make([]e2wtypes.Account, len(pubKeys))
+	found := make([][]byte, 0, len(pubKeys))
+	for i := range pubKeys {
+		account, err := h.wallet.AccountByCompositePublicKey(r.Context(), pubKeys[i])
+		switch {
+		case errors.Is(err, distributed.ErrNotFound):
+			res.Data[i] = &statusJSON{Status: statusNotFound}
+		case err != nil:
+			res.Data[i] = &statusJSON{Status: statusError, Message: err.Error()}
+		default:
+			accounts[i] = account
+			found = append(found, pubKeys[i])
+		}
+	}
+
+	// Slashing protection data is exported before any accounts are removed, so that
+	// keys are not removed without their slashing protection data being returned.
+	slashingProtection, err := h.slashingProtection.Export(r.Context(), found)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to export slashing protection data: %v", err))
+
+		return
+	}
+	res.SlashingProtection = string(slashingProtection)
+
+	for i, account := range accounts {
+		if account == nil {
+			continue
+		}
+		if err := h.wallet.RemoveAccount(r.Context(), account.Name()); err != nil {
+			res.Data[i] = &statusJSON{Status: statusError, Message: err.Error()}
+
+			continue
+		}
+		res.Data[i] = &statusJSON{Status: statusDeleted}
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+// writeError writes an error response.
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, &errorResponseJSON{
+		Message: message,
+	})
+}
+
+// writeJSON writes a JSON response.
+func writeJSON(w http.ResponseWriter, code int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(data)
+}
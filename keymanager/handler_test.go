@@ -0,0 +1,308 @@
+// Copyright © 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keymanager_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	bls "github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	"github.com/wealdtech/go-eth2-wallet-distributed/keymanager"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	scratch "github.com/wealdtech/go-eth2-wallet-store-scratch"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+func TestMain(m *testing.M) {
+	if err := e2types.InitBLS(); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// removingStore is a store that supports removal of accounts.
+type removingStore struct {
+	e2wtypes.Store
+	removed map[uuid.UUID]bool
+}
+
+func (s *removingStore) RemoveAccount(_ uuid.UUID, accountID uuid.UUID) error {
+	s.removed[accountID] = true
+
+	return nil
+}
+
+func (s *removingStore) RetrieveAccount(walletID uuid.UUID, accountID uuid.UUID) ([]byte, error) {
+	if s.removed[accountID] {
+		return nil, errors.New("account not found")
+	}
+
+	return s.Store.RetrieveAccount(walletID, accountID)
+}
+
+func (s *removingStore) RetrieveAccounts(walletID uuid.UUID) <-chan []byte {
+	ch := make(chan []byte, 1024)
+	go func() {
+		defer close(ch)
+		for data := range s.Store.RetrieveAccounts(walletID) {
+			info := &struct {
+				ID uuid.UUID `json:"uuid"`
+			}{}
+			if err := json.Unmarshal(data, info); err == nil && s.removed[info.ID] {
+				continue
+			}
+			ch <- data
+		}
+	}()
+
+	return ch
+}
+
+// slashingProtection is an in-memory slashing protection store.
+type slashingProtection struct {
+	imported [][]byte
+	fail     bool
+}
+
+func (s *slashingProtection) Import(_ context.Context, interchange []byte) error {
+	if s.fail {
+		return errors.New("store failure")
+	}
+	s.imported = append(s.imported, interchange)
+
+	return nil
+}
+
+func (s *slashingProtection) Export(_ context.Context, pubKeys [][]byte) ([]byte, error) {
+	data := make([]string, len(pubKeys))
+	for i := range pubKeys {
+		data[i] = fmt.Sprintf(`{"pubkey":"%#x"}`, pubKeys[i])
+	}
+
+	return []byte(fmt.Sprintf(`{"data":[%s]}`, strings.Join(data, ","))), nil
+}
+
+// exportedKeystore creates a keystore and sidecar for a share of a new distributed account,
+// returning them along with the composite public key of the account.
+func exportedKeystore(t *testing.T, name string, password string) (string, string, []byte) {
+	t.Helper()
+	ctx := context.Background()
+
+	msk := make([]bls.SecretKey, 2)
+	for i := range msk {
+		msk[i].SetByCSPRNG()
+	}
+	mpk := bls.GetMasterPublicKey(msk)
+	verificationVector := make([][]byte, len(mpk))
+	for i := range mpk {
+		verificationVector[i] = mpk[i].Serialize()
+	}
+	var id bls.ID
+	require.NoError(t, id.SetDecString("1"))
+	var share bls.SecretKey
+	require.NoError(t, share.Set(msk, &id))
+
+	wallet, err := distributed.CreateWallet(ctx, "source wallet", scratch.New(), keystorev4.New())
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+	account, err := wallet.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+		name, share.Serialize(), 2, verificationVector,
+		map[uint64]string{1: "signer1:443", 2: "signer2:443", 3: "signer3:443"}, []byte("pass"))
+	require.NoError(t, err)
+	require.NoError(t, account.(e2wtypes.AccountLocker).Unlock(ctx, []byte("pass")))
+	keystore, sidecar, err := account.(distributed.AccountKeystoreExporter).ExportKeystore(ctx, []byte(password))
+	require.NoError(t, err)
+
+	return string(keystore), string(sidecar), verificationVector[0]
+}
+
+// request makes a request of the handler, returning the status and decoded body of the response.
+func request(t *testing.T, handler http.Handler, method string, token string, body any) (int, map[string]any) {
+	t.Helper()
+
+	var reqBody string
+	if body != nil {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		reqBody = string(data)
+	}
+	req := httptest.NewRequest(method, keymanager.KeystoresPath, strings.NewReader(reqBody))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	res := make(map[string]any)
+	require.NoError(t, json.NewDecoder(rec.Result().Body).Decode(&res))
+
+	return rec.Code, res
+}
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+	wallet, err := distributed.CreateWallet(ctx, "test wallet", scratch.New(), keystorev4.New())
+	require.NoError(t, err)
+
+	_, err = keymanager.New(wallet, keymanager.WithSlashingProtection(&slashingProtection{}))
+	require.EqualError(t, err, "no bearer tokens supplied")
+	_, err = keymanager.New(wallet, keymanager.WithBearerTokens("secret"))
+	require.EqualError(t, err, "no slashing protection supplied")
+	_, err = keymanager.New(wallet, keymanager.WithBearerTokens(""), keymanager.WithSlashingProtection(&slashingProtection{}))
+	require.EqualError(t, err, "bearer token empty")
+	_, err = keymanager.New(wallet, keymanager.WithBearerTokens("secret"), keymanager.WithSlashingProtection(&slashingProtection{}))
+	require.NoError(t, err)
+}
+
+func TestKeystores(t *testing.T) {
+	ctx := context.Background()
+	store := &removingStore{Store: scratch.New(), removed: make(map[uuid.UUID]bool)}
+	wallet, err := distributed.CreateWallet(ctx, "test wallet", store, keystorev4.New())
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+	sp := &slashingProtection{}
+	handler, err := keymanager.New(wallet,
+		keymanager.WithBearerTokens("secret"),
+		keymanager.WithSlashingProtection(sp),
+		keymanager.WithAccountPassphrase([]byte("account passphrase")),
+	)
+	require.NoError(t, err)
+
+	keystore1, sidecar1, compositePubKey1 := exportedKeystore(t, "Account 1", "password 1")
+	keystore2, sidecar2, compositePubKey2 := exportedKeystore(t, "Account 2", "password 2")
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		status, res := request(t, handler, http.MethodGet, "wrong", nil)
+		require.Equal(t, http.StatusUnauthorized, status)
+		require.Equal(t, "unauthorized", res["message"])
+	})
+
+	t.Run("MethodNotAllowed", func(t *testing.T) {
+		status, res := request(t, handler, http.MethodPut, "secret", nil)
+		require.Equal(t, http.StatusMethodNotAllowed, status)
+		require.Equal(t, "method not allowed", res["message"])
+	})
+
+	t.Run("ListEmpty", func(t *testing.T) {
+		status, res := request(t, handler, http.MethodGet, "secret", nil)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, []any{}, res["data"])
+	})
+
+	t.Run("ImportSidecarsMissing", func(t *testing.T) {
+		status, res := request(t, handler, http.MethodPost, "secret", map[string]any{
+			"keystores": []string{keystore1},
+			"passwords": []string{"password 1"},
+		})
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Equal(t, "invalid request: keystores and sidecars must have the same length", res["message"])
+	})
+
+	t.Run("ImportSlashingProtectionFails", func(t *testing.T) {
+		sp.fail = true
+		defer func() { sp.fail = false }()
+		status, res := request(t, handler, http.MethodPost, "secret", map[string]any{
+			"keystores":           []string{keystore1},
+			"passwords":           []string{"password 1"},
+			"sidecars":            []string{sidecar1},
+			"slashing_protection": `{"data":[]}`,
+		})
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, []any{
+			map[string]any{"status": "error", "message": "failed to import slashing protection data: store failure"},
+		}, res["data"])
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		status, res := request(t, handler, http.MethodPost, "secret", map[string]any{
+			"keystores":           []string{keystore1, keystore2, keystore1},
+			"passwords":           []string{"password 1", "wrong", "password 1"},
+			"sidecars":            []string{sidecar1, sidecar2, sidecar1},
+			"slashing_protection": `{"data":[]}`,
+		})
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, []any{
+			map[string]any{"status": "imported"},
+			map[string]any{"status": "error", "message": "incorrect keystore passphrase"},
+			map[string]any{"status": "duplicate"},
+		}, res["data"])
+		require.Equal(t, [][]byte{[]byte(`{"data":[]}`)}, sp.imported)
+
+		// The account is protected by the account passphrase.
+		account, err := wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 1")
+		require.NoError(t, err)
+		require.NoError(t, account.(e2wtypes.AccountLocker).Unlock(ctx, []byte("account passphrase")))
+	})
+
+	t.Run("List", func(t *testing.T) {
+		status, res := request(t, handler, http.MethodGet, "secret", nil)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, []any{
+			map[string]any{"validating_pubkey": fmt.Sprintf("%#x", compositePubKey1), "readonly": false},
+		}, res["data"])
+	})
+
+	t.Run("DeleteInvalid", func(t *testing.T) {
+		status, res := request(t, handler, http.MethodDelete, "secret", map[string]any{
+			"pubkeys": []string{"0xinvalid"},
+		})
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Equal(t, "invalid request: invalid public key 0xinvalid", res["message"])
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		status, res := request(t, handler, http.MethodDelete, "secret", map[string]any{
+			"pubkeys": []string{fmt.Sprintf("%#x", compositePubKey1), fmt.Sprintf("%#x", compositePubKey2)},
+		})
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, []any{
+			map[string]any{"status": "deleted"},
+			map[string]any{"status": "not_found"},
+		}, res["data"])
+		require.Equal(t, fmt.Sprintf(`{"data":[{"pubkey":"%#x"}]}`, compositePubKey1), res["slashing_protection"])
+
+		status, res = request(t, handler, http.MethodGet, "secret", nil)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, []any{}, res["data"])
+	})
+
+	t.Run("ListWatchOnly", func(t *testing.T) {
+		msk := make([]bls.SecretKey, 2)
+		for i := range msk {
+			msk[i].SetByCSPRNG()
+		}
+		mpk := bls.GetMasterPublicKey(msk)
+		verificationVector := [][]byte{mpk[0].Serialize(), mpk[1].Serialize()}
+		_, err := wallet.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+			"Watched", nil, 2, verificationVector,
+			map[uint64]string{1: "signer1:443", 2: "signer2:443", 3: "signer3:443"}, nil)
+		require.NoError(t, err)
+
+		status, res := request(t, handler, http.MethodGet, "secret", nil)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, []any{
+			map[string]any{"validating_pubkey": fmt.Sprintf("%#x", verificationVector[0]), "readonly": true},
+		}, res["data"])
+	})
+}
@@ -0,0 +1,65 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keymanager
+
+// options are the options for a handler.
+type options struct {
+	bearerTokens       []string
+	slashingProtection SlashingProtection
+	accountPassphrase  []byte
+}
+
+// Option gives options to New.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) {
+	f(o)
+}
+
+// WithBearerTokens authenticates requests that supply any of the given tokens in an
+// "Authorization: Bearer" header.
+func WithBearerTokens(tokens ...string) Option {
+	return optionFunc(func(o *options) {
+		o.bearerTokens = append(o.bearerTokens, tokens...)
+	})
+}
+
+// WithSlashingProtection stores slashing protection data for imported and deleted keys.
+func WithSlashingProtection(slashingProtection SlashingProtection) Option {
+	return optionFunc(func(o *options) {
+		o.slashingProtection = slashingProtection
+	})
+}
+
+// WithAccountPassphrase protects imported accounts in the wallet with the given passphrase.
+// If not supplied, imported accounts are protected by the password of their keystore.
+func WithAccountPassphrase(passphrase []byte) Option {
+	return optionFunc(func(o *options) {
+		o.accountPassphrase = passphrase
+	})
+}
+
+// parseOptions parses the supplied options.
+func parseOptions(opts []Option) *options {
+	options := &options{}
+	for _, o := range opts {
+		o.apply(options)
+	}
+
+	return options
+}
@@ -0,0 +1,84 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keymanager
+
+// Statuses of individual keystores in import and delete responses.
+const (
+	statusImported  = "imported"
+	statusDuplicate = "duplicate"
+	statusDeleted   = "deleted"
+	statusNotFound  = "not_found"
+	statusError     = "error"
+)
+
+// keystoreJSON is the JSON representation of a keystore in a list response.
+type keystoreJSON struct {
+	ValidatingPubkey string `json:"validating_pubkey"`
+	DerivationPath   string `json:"derivation_path,omitempty"`
+	Readonly         bool   `json:"readonly"`
+}
+
+// listResponseJSON is the JSON representation of a list response.
+type listResponseJSON struct {
+	Data []*keystoreJSON `json:"data"`
+}
+
+// importRequestJSON is the JSON representation of an import request.
+// Sidecars is an extension to the standard request, providing the distributed metadata
+// sidecar for each keystore.
+type importRequestJSON struct {
+	Keystores          []string `json:"keystores"`
+	Passwords          []string `json:"passwords"`
+	Sidecars           []string `json:"sidecars"`
+	SlashingProtection string   `json:"slashing_protection,omitempty"`
+}
+
+// sidecarJSON is the JSON representation of the parts of a sidecar used by the handler.
+//
+//nolint:tagliatelle
+type sidecarJSON struct {
+	CompositePubkey string `json:"compositePubkey"`
+}
+
+// keystorePubkeyJSON is the JSON representation of the parts of a keystore used by the handler.
+type keystorePubkeyJSON struct {
+	Pubkey string `json:"pubkey"`
+}
+
+// statusJSON is the JSON representation of the status of an individual keystore.
+type statusJSON struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// importResponseJSON is the JSON representation of an import response.
+type importResponseJSON struct {
+	Data []*statusJSON `json:"data"`
+}
+
+// deleteRequestJSON is the JSON representation of a delete request.
+type deleteRequestJSON struct {
+	Pubkeys []string `json:"pubkeys"`
+}
+
+// deleteResponseJSON is the JSON representation of a delete response.
+type deleteResponseJSON struct {
+	Data               []*statusJSON `json:"data"`
+	SlashingProtection string        `json:"slashing_protection"`
+}
+
+// errorResponseJSON is the JSON representation of an error response.
+type errorResponseJSON struct {
+	Message string `json:"message"`
+}
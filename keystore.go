@@ -0,0 +1,213 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+// keystoreJSON is the EIP-2335 keystore format.
+type keystoreJSON struct {
+	Crypto      map[string]any `json:"crypto"`
+	Description string         `json:"description"`
+	Pubkey      string         `json:"pubkey"`
+	Path        string         `json:"path"`
+	UUID        uuid.UUID      `json:"uuid"`
+	Version     uint           `json:"version"`
+}
+
+// keystoreSidecarJSON is the distributed metadata that accompanies a keystore.
+//
+//nolint:tagliatelle
+type keystoreSidecarJSON struct {
+	Name               string                  `json:"name"`
+	Pubkey             string                  `json:"pubkey"`
+	CompositePubkey    string                  `json:"compositePubkey"`
+	VerificationVector []string                `json:"verificationVector"`
+	SigningThreshold   uint32                  `json:"signingThreshold"`
+	Participants       map[string]*Participant `json:"participants"`
+}
+
+// AccountKeystoreExporter is the interface for accounts that can export themselves as EIP-2335 keystores.
+type AccountKeystoreExporter interface {
+	// ExportKeystore exports the account's share as an EIP-2335 keystore protected by the passphrase,
+	// along with a sidecar containing the distributed metadata for the account.
+	ExportKeystore(ctx context.Context, passphrase []byte) ([]byte, []byte, error)
+}
+
+// WalletKeystoreImporter is the interface for wallets that can import EIP-2335 keystores.
+type WalletKeystoreImporter interface {
+	// ImportKeystore imports an account from an EIP-2335 keystore and its distributed metadata sidecar.
+	ImportKeystore(ctx context.Context,
+		name string,
+		keystore []byte,
+		sidecar []byte,
+		keystorePassphrase []byte,
+		passphrase []byte,
+	) (
+		e2wtypes.Account,
+		error,
+	)
+}
+
+// ExportKeystore exports the account's share as an EIP-2335 keystore protected by the passphrase,
+// along with a sidecar containing the distributed metadata for the account.
+// The account must be unlocked.
+func (a *account) ExportKeystore(_ context.Context, passphrase []byte) ([]byte, []byte, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	if a.watchOnly {
+		return nil, nil, newWatchOnlyError(a.name, "watch-only account cannot be exported as a keystore")
+	}
+	if !a.unlocked {
+		return nil, nil, newLockedError("account", a.name, "cannot export keystore when account is locked")
+	}
+	if len(a.verificationVector) == 0 {
+		return nil, nil, errors.New("account has no verification vector")
+	}
+
+	encryptor := keystorev4.New()
+	crypto, err := encryptor.Encrypt(a.secretKey.Marshal(), string(passphrase))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to encrypt private key")
+	}
+	keystore, err := json.Marshal(&keystoreJSON{
+		Crypto:  crypto,
+		Pubkey:  fmt.Sprintf("%x", a.publicKey.Marshal()),
+		UUID:    a.id,
+		Version: encryptor.Version(),
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to marshal keystore")
+	}
+
+	// Accounts described by share public keys are exported in the same form.
+	storedVerificationVector := a.storedVerificationVector()
+	verificationVector := make([]string, len(storedVerificationVector))
+	for i := range storedVerificationVector {
+		verificationVector[i] = fmt.Sprintf("%x", storedVerificationVector[i])
+	}
+	sidecar, err := json.Marshal(&keystoreSidecarJSON{
+		Name:               a.name,
+		Pubkey:             fmt.Sprintf("%x", a.publicKey.Marshal()),
+		CompositePubkey:    fmt.Sprintf("%x", a.verificationVector[0].Marshal()),
+		VerificationVector: verificationVector,
+		SigningThreshold:   a.signingThreshold,
+		Participants:       participantsJSON(a.participants),
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to marshal sidecar")
+	}
+
+	return keystore, sidecar, nil
+}
+
+// ImportKeystore imports an account from an EIP-2335 keystore and its distributed metadata sidecar.
+// If name is empty the name in the sidecar is used.
+// The secret is decrypted with the keystore passphrase and stored in the wallet protected by the passphrase.
+func (w *wallet) ImportKeystore(ctx context.Context,
+	name string,
+	keystore []byte,
+	sidecar []byte,
+	keystorePassphrase []byte,
+	passphrase []byte,
+) (
+	e2wtypes.Account,
+	error,
+) {
+	ks := &keystoreJSON{}
+	if err := json.Unmarshal(keystore, ks); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal keystore")
+	}
+	if ks.Crypto == nil {
+		return nil, errors.New("keystore crypto missing")
+	}
+	if ks.Version != 4 {
+		return nil, newUnsupportedVersionError(ks.Version, fmt.Sprintf("unsupported keystore version %d", ks.Version))
+	}
+	metadata := &keystoreSidecarJSON{}
+	if err := json.Unmarshal(sidecar, metadata); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal sidecar")
+	}
+
+	secretKey, err := keystorev4.New().Decrypt(ks.Crypto, string(keystorePassphrase))
+	if err != nil {
+		if incorrectPassphrase(err) {
+			return nil, newWrongPassphraseError(nil, "incorrect keystore passphrase")
+		}
+
+		return nil, newCorruptDataError(err, "failed to decrypt keystore")
+	}
+	privateKey, err := e2types.BLSPrivateKeyFromBytes(secretKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to obtain BLS private key")
+	}
+	for _, pubkey := range []string{ks.Pubkey, metadata.Pubkey} {
+		if pubkey == "" {
+			continue
+		}
+		pubkeyBytes, err := hex.DecodeString(strings.TrimPrefix(pubkey, "0x"))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decode public key")
+		}
+		if !bytes.Equal(pubkeyBytes, privateKey.PublicKey().Marshal()) {
+			return nil, errors.New("private key does not correspond to public key")
+		}
+	}
+
+	verificationVector := make([][]byte, len(metadata.VerificationVector))
+	for i := range metadata.VerificationVector {
+		verificationVector[i], err = hex.DecodeString(strings.TrimPrefix(metadata.VerificationVector[i], "0x"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode verification vector element %d", i)
+		}
+	}
+	if metadata.CompositePubkey != "" && len(verificationVector) > 0 {
+		compositePubkey, err := hex.DecodeString(strings.TrimPrefix(metadata.CompositePubkey, "0x"))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decode composite public key")
+		}
+		if !bytes.Equal(compositePubkey, verificationVector[0]) {
+			return nil, errors.New("composite public key does not match verification vector")
+		}
+	}
+	participants, err := participantsFromJSON(metadata.Participants)
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name = metadata.Name
+	}
+
+	return w.ImportDistributedAccountWithParticipants(ctx,
+		name,
+		privateKey.Marshal(),
+		metadata.SigningThreshold,
+		verificationVector,
+		participants,
+		passphrase)
+}
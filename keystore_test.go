@@ -0,0 +1,92 @@
+// Copyright © 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	scratch "github.com/wealdtech/go-eth2-wallet-store-scratch"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+func TestKeystore(t *testing.T) {
+	ctx := context.Background()
+	encryptor := keystorev4.New()
+	wallet, err := distributed.CreateWallet(ctx, "test wallet", scratch.New(), encryptor)
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+
+	privKey := _byteArray("01e748d098d3bcb477d636f19d510399ae18205fadf9814ee67052f88c1f77c0")
+	vvec := [][]byte{
+		_byteArray("a0633864987df6f7a0f40fbecbe0d15fe5317c00adccc0b816266bcf3d3d1ab6a365b3d79461b5da5a8ea09e37644731"),
+		_byteArray("a7430ae4717f511e473ed87a6510c5dfdbf289b7c7c4e083270da487aa146b0291ee701e0c38033aa157a612b8fd488b"),
+		_byteArray("b5e95fbcf45c9f2730f04abab22672dddca4b10e32467ab8522d7d153a382c9656d28268b5e75361e4e39a5d12513d8c"),
+	}
+	participants := map[uint64]string{1: "foo", 2: "bar", 3: "baz"}
+	account, err := wallet.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+		"Account 1", privKey, 3, vvec, participants, []byte("pass"))
+	require.NoError(t, err)
+
+	// Locked accounts cannot be exported.
+	_, _, err = account.(distributed.AccountKeystoreExporter).ExportKeystore(ctx, []byte("keystore pass"))
+	require.EqualError(t, err, "cannot export keystore when account is locked")
+
+	require.NoError(t, account.(e2wtypes.AccountLocker).Unlock(ctx, []byte("pass")))
+	keystore, sidecar, err := account.(distributed.AccountKeystoreExporter).ExportKeystore(ctx, []byte("keystore pass"))
+	require.NoError(t, err)
+
+	// Ensure the keystore is a standard EIP-2335 keystore.
+	ks := make(map[string]any)
+	require.NoError(t, json.Unmarshal(keystore, &ks))
+	require.Equal(t, float64(4), ks["version"])
+	require.Equal(t, fmt.Sprintf("%x", account.PublicKey().Marshal()), ks["pubkey"])
+	require.Equal(t, account.ID().String(), ks["uuid"])
+	decrypted, err := keystorev4.New().Decrypt(ks["crypto"].(map[string]any), "keystore pass")
+	require.NoError(t, err)
+	require.Equal(t, privKey, decrypted)
+
+	md := make(map[string]any)
+	require.NoError(t, json.Unmarshal(sidecar, &md))
+	require.Equal(t, float64(3), md["signingThreshold"])
+	require.Len(t, md["verificationVector"], 3)
+	require.Equal(t, "a0633864987df6f7a0f40fbecbe0d15fe5317c00adccc0b816266bcf3d3d1ab6a365b3d79461b5da5a8ea09e37644731", md["compositePubkey"])
+
+	// Import in to a new wallet.
+	wallet2, err := distributed.CreateWallet(ctx, "test wallet", scratch.New(), encryptor)
+	require.NoError(t, err)
+	require.NoError(t, wallet2.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+	importer := wallet2.(distributed.WalletKeystoreImporter)
+
+	_, err = importer.ImportKeystore(ctx, "", keystore, sidecar, []byte("wrong"), []byte("new pass"))
+	require.EqualError(t, err, "incorrect keystore passphrase")
+
+	imported, err := importer.ImportKeystore(ctx, "", keystore, sidecar, []byte("keystore pass"), []byte("new pass"))
+	require.NoError(t, err)
+	require.Equal(t, "Account 1", imported.Name())
+	require.Equal(t, account.PublicKey().Marshal(), imported.PublicKey().Marshal())
+	require.Equal(t, participants, imported.(e2wtypes.AccountParticipantsProvider).Participants())
+	require.Equal(t, uint32(3), imported.(e2wtypes.AccountSigningThresholdProvider).SigningThreshold())
+	require.NoError(t, imported.(e2wtypes.AccountLocker).Unlock(ctx, []byte("new pass")))
+
+	// Import with an explicit name.
+	renamed, err := importer.ImportKeystore(ctx, "Account 2", keystore, sidecar, []byte("keystore pass"), []byte("new pass"))
+	require.NoError(t, err)
+	require.Equal(t, "Account 2", renamed.Name())
+}
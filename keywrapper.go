@@ -0,0 +1,73 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// KeyWrapper is the interface for services that protect secrets without a
+// passphrase, for example an external KMS or a TPM-sealed key.
+type KeyWrapper interface {
+	// Name provides the name of the key wrapper.
+	Name() string
+
+	// Wrap wraps a secret, returning data suitable for storage as account crypto.
+	Wrap(ctx context.Context, secret []byte) (map[string]any, error)
+
+	// Unwrap unwraps a secret previously wrapped with Wrap.
+	Unwrap(ctx context.Context, data map[string]any) ([]byte, error)
+}
+
+// encryptSecret protects a secret, using the wallet's key wrapper if
+// present or else its encryptor with the supplied passphrase.
+func (w *wallet) encryptSecret(ctx context.Context, secret []byte, passphrase string) (map[string]any, error) {
+	if w.keyWrapper != nil {
+		crypto, err := w.keyWrapper.Wrap(ctx, secret)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to wrap secret")
+		}
+
+		return crypto, nil
+	}
+
+	return w.encryptor.Encrypt(secret, passphrase)
+}
+
+// decryptSecret decrypts the account's secret, using its key wrapper if
+// present or else its encryptor with the supplied passphrase.
+// Errors are returned as ErrWrongPassphrase only if the passphrase is incorrect.
+func (a *account) decryptSecret(ctx context.Context, passphrase []byte) ([]byte, error) {
+	if a.keyWrapper != nil {
+		secret, err := a.keyWrapper.Unwrap(ctx, a.crypto)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to unwrap secret")
+		}
+
+		return secret, nil
+	}
+
+	secret, err := a.encryptor.Decrypt(a.crypto, string(passphrase))
+	if err != nil {
+		if incorrectPassphrase(err) {
+			return nil, newWrongPassphraseError(nil, "incorrect passphrase")
+		}
+
+		return nil, newCorruptDataError(err, "failed to decrypt secret")
+	}
+
+	return secret, nil
+}
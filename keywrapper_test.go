@@ -0,0 +1,178 @@
+// Copyright © 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed_test
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	scratch "github.com/wealdtech/go-eth2-wallet-store-scratch"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+// fakeKMS is an in-memory key wrapper.
+type fakeKMS struct {
+	aead cipher.AEAD
+}
+
+// unreachableKMS is a key wrapper whose service cannot be reached.
+type unreachableKMS struct{}
+
+func (k *unreachableKMS) Name() string {
+	return "fakekms"
+}
+
+func (k *unreachableKMS) Wrap(_ context.Context, _ []byte) (map[string]any, error) {
+	return nil, errors.New("service unreachable")
+}
+
+func (k *unreachableKMS) Unwrap(_ context.Context, _ map[string]any) ([]byte, error) {
+	return nil, errors.New("service unreachable")
+}
+
+func newFakeKMS(t *testing.T) *fakeKMS {
+	t.Helper()
+
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+	aead, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+
+	return &fakeKMS{aead: aead}
+}
+
+func (k *fakeKMS) Name() string {
+	return "fakekms"
+}
+
+func (k *fakeKMS) Wrap(_ context.Context, secret []byte) (map[string]any, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"nonce":      hex.EncodeToString(nonce),
+		"ciphertext": hex.EncodeToString(k.aead.Seal(nil, nonce, secret, nil)),
+	}, nil
+}
+
+func (k *fakeKMS) Unwrap(_ context.Context, data map[string]any) ([]byte, error) {
+	nonceStr, ok := data["nonce"].(string)
+	if !ok {
+		return nil, errors.New("nonce missing")
+	}
+	ciphertextStr, ok := data["ciphertext"].(string)
+	if !ok {
+		return nil, errors.New("ciphertext missing")
+	}
+	nonce, err := hex.DecodeString(nonceStr)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := hex.DecodeString(ciphertextStr)
+	if err != nil {
+		return nil, err
+	}
+
+	return k.aead.Open(nil, nonce, ciphertext, nil)
+}
+
+func TestKeyWrapper(t *testing.T) {
+	ctx := context.Background()
+	store := scratch.New()
+	encryptor := keystorev4.New()
+	kms := newFakeKMS(t)
+
+	wallet, err := distributed.CreateWallet(ctx, "test wallet", store, encryptor, distributed.WithKeyWrapper(kms))
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+
+	privKey := _byteArray("0a660b6379a25e095590edeb7688a8506653e58310336efcfc98a9e34e485faa")
+	_, err = wallet.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+		"account 1",
+		privKey,
+		3,
+		[][]byte{
+			_byteArray("b5d7a0bffb025cca463898a7ff56a613402e40d43ee293b45ee9f7811c17047a43273a0cf843d75995d1150140f6b2ef"),
+			_byteArray("b82aa608cd126ff401a458be48944dc84c999cce084fd6c8da816e5548964fc1d71b05d52c528e5ce3657778c573cc31"),
+			_byteArray("a4da59f92bea77d3950cb578c2b8c8ee65e12040e9efd4c82cb4b0ac6138fef5d8f4bb53971bafdf6285f22f91b22b2f"),
+		},
+		map[uint64]string{1: "foo", 2: "bar", 3: "baz"},
+		nil)
+	require.NoError(t, err)
+	for data := range store.RetrieveAccounts(wallet.ID()) {
+		stored := make(map[string]any)
+		require.NoError(t, json.Unmarshal(data, &stored))
+		require.Equal(t, "fakekms", stored["key_wrapper"])
+	}
+
+	// Re-open the wallet with the key wrapper and unlock without a passphrase.
+	wallet, err = distributed.OpenWallet(ctx, "test wallet", store, encryptor, distributed.WithKeyWrapper(kms))
+	require.NoError(t, err)
+	account, err := wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "account 1")
+	require.NoError(t, err)
+	require.NoError(t, account.(e2wtypes.AccountLocker).Unlock(ctx, nil))
+	privateKey, err := account.(e2wtypes.AccountPrivateKeyProvider).PrivateKey(ctx)
+	require.NoError(t, err)
+	require.Equal(t, privKey, privateKey.Marshal())
+
+	// Re-open the wallet without the key wrapper; account should be unavailable.
+	wallet, err = distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+	require.NoError(t, err)
+	_, err = wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "account 1")
+	require.EqualError(t, err, `failed to unmarshal account: account key wrapper "fakekms" unavailable`)
+	require.False(t, errors.Is(err, distributed.ErrCorruptData))
+
+	// A key wrapper that cannot be reached is not an incorrect passphrase.
+	wallet, err = distributed.OpenWallet(ctx, "test wallet", store, encryptor, distributed.WithKeyWrapper(&unreachableKMS{}))
+	require.NoError(t, err)
+	account, err = wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "account 1")
+	require.NoError(t, err)
+	err = account.(e2wtypes.AccountLocker).Unlock(ctx, nil)
+	require.EqualError(t, err, "failed to unwrap secret: service unreachable")
+	require.False(t, errors.Is(err, distributed.ErrWrongPassphrase))
+
+	// Batch the wallet with the key wrapper.
+	wallet, err = distributed.OpenWallet(ctx, "test wallet", store, encryptor, distributed.WithKeyWrapper(kms))
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletBatchCreator).BatchWallet(ctx, nil, ""))
+	batchData, err := store.(e2wtypes.BatchRetriever).RetrieveBatch(ctx, wallet.ID())
+	require.NoError(t, err)
+	storedBatch := make(map[string]any)
+	require.NoError(t, json.Unmarshal(batchData, &storedBatch))
+	require.Equal(t, "fakekms", storedBatch["key_wrapper"])
+
+	// Re-open the wallet and unlock the account from the batch.
+	wallet, err = distributed.OpenWallet(ctx, "test wallet", store, encryptor, distributed.WithKeyWrapper(kms))
+	require.NoError(t, err)
+	account, err = wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "account 1")
+	require.NoError(t, err)
+	require.NoError(t, account.(e2wtypes.AccountLocker).Unlock(ctx, nil))
+	privateKey, err = account.(e2wtypes.AccountPrivateKeyProvider).PrivateKey(ctx)
+	require.NoError(t, err)
+	require.Equal(t, privKey, privateKey.Marshal())
+}
@@ -0,0 +1,118 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+// WalletAccountByPublicKeyProvider is the interface for wallets that can provide an account given its public key.
+type WalletAccountByPublicKeyProvider interface {
+	// AccountByPublicKey provides a single account from the wallet given its public key.
+	AccountByPublicKey(ctx context.Context, pubKey []byte) (e2wtypes.Account, error)
+}
+
+// WalletAccountByCompositePublicKeyProvider is the interface for wallets that can provide an account
+// given its composite public key.
+type WalletAccountByCompositePublicKeyProvider interface {
+	// AccountByCompositePublicKey provides a single account from the wallet given its composite public key.
+	AccountByCompositePublicKey(ctx context.Context, pubKey []byte) (e2wtypes.Account, error)
+}
+
+// AccountByPublicKey provides a single account from the wallet given its public key.
+// This will error if the account is not found.
+func (w *wallet) AccountByPublicKey(ctx context.Context, pubKey []byte) (e2wtypes.Account, error) {
+	w.indexAccountKeys(ctx)
+
+	w.keysMutex.RLock()
+	id, exists := w.publicKeys[string(pubKey)]
+	w.keysMutex.RUnlock()
+	if !exists {
+		return nil, newNotFoundError("account", fmt.Sprintf("%#x", pubKey), nil, "no account with public key %#x", pubKey)
+	}
+
+	return w.AccountByID(ctx, id)
+}
+
+// AccountByCompositePublicKey provides a single account from the wallet given its composite public key.
+// This will error if the account is not found, or if more than one account has the composite public key.
+func (w *wallet) AccountByCompositePublicKey(ctx context.Context, pubKey []byte) (e2wtypes.Account, error) {
+	w.indexAccountKeys(ctx)
+
+	w.keysMutex.RLock()
+	ids := w.compositePublicKeys[string(pubKey)]
+	w.keysMutex.RUnlock()
+	switch len(ids) {
+	case 0:
+		return nil, newNotFoundError("account", fmt.Sprintf("%#x", pubKey), nil, "no account with composite public key %#x", pubKey)
+	case 1:
+		return w.AccountByID(ctx, ids[0])
+	default:
+		return nil, fmt.Errorf("multiple accounts with composite public key %#x", pubKey)
+	}
+}
+
+// indexAccountKeys indexes the public keys of the wallet's accounts, if not already indexed.
+// Accounts that cannot be obtained are not indexed.
+func (w *wallet) indexAccountKeys(ctx context.Context) {
+	w.keysMutex.Lock()
+	defer w.keysMutex.Unlock()
+
+	if w.keysIndexed {
+		return
+	}
+
+	w.publicKeys = make(map[string]uuid.UUID)
+	w.compositePublicKeys = make(map[string][]uuid.UUID)
+	for res := range w.IterateAccounts(ctx) {
+		if res.Err != nil {
+			continue
+		}
+		if a, isAccount := res.Account.(*account); isAccount {
+			w.addAccountKeysLocked(a)
+		}
+	}
+	w.keysIndexed = ctx.Err() == nil
+}
+
+// addAccountKeys adds the public keys of an account to the index, if the index is present.
+func (w *wallet) addAccountKeys(a *account) {
+	w.keysMutex.Lock()
+	defer w.keysMutex.Unlock()
+
+	if w.keysIndexed {
+		w.addAccountKeysLocked(a)
+	}
+}
+
+// addAccountKeysLocked adds the public keys of an account to the index.
+// This assumes that the keys mutex is held.
+func (w *wallet) addAccountKeysLocked(a *account) {
+	w.publicKeys[string(a.publicKey.Marshal())] = a.id
+	if len(a.verificationVector) > 0 {
+		compositePublicKey := string(a.verificationVector[0].Marshal())
+		w.compositePublicKeys[compositePublicKey] = append(w.compositePublicKeys[compositePublicKey], a.id)
+	}
+}
+
+// resetAccountKeys discards the index of public keys, so that it is rebuilt on next use.
+func (w *wallet) resetAccountKeys() {
+	w.keysMutex.Lock()
+	w.keysIndexed = false
+	w.keysMutex.Unlock()
+}
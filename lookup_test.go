@@ -0,0 +1,99 @@
+// Copyright © 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	scratch "github.com/wealdtech/go-eth2-wallet-store-scratch"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+func TestAccountByPublicKey(t *testing.T) {
+	ctx := context.Background()
+	store := scratch.New()
+	encryptor := keystorev4.New()
+	participants := map[uint64]string{1: "foo", 2: "bar", 3: "baz"}
+	vvec1 := [][]byte{
+		_byteArray("a0633864987df6f7a0f40fbecbe0d15fe5317c00adccc0b816266bcf3d3d1ab6a365b3d79461b5da5a8ea09e37644731"),
+		_byteArray("a7430ae4717f511e473ed87a6510c5dfdbf289b7c7c4e083270da487aa146b0291ee701e0c38033aa157a612b8fd488b"),
+		_byteArray("b5e95fbcf45c9f2730f04abab22672dddca4b10e32467ab8522d7d153a382c9656d28268b5e75361e4e39a5d12513d8c"),
+	}
+	vvec2 := [][]byte{
+		_byteArray("b5d7a0bffb025cca463898a7ff56a613402e40d43ee293b45ee9f7811c17047a43273a0cf843d75995d1150140f6b2ef"),
+		_byteArray("b82aa608cd126ff401a458be48944dc84c999cce084fd6c8da816e5548964fc1d71b05d52c528e5ce3657778c573cc31"),
+		_byteArray("a4da59f92bea77d3950cb578c2b8c8ee65e12040e9efd4c82cb4b0ac6138fef5d8f4bb53971bafdf6285f22f91b22b2f"),
+	}
+
+	wallet, err := distributed.CreateWallet(ctx, "test wallet", store, encryptor)
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+	importer := wallet.(e2wtypes.WalletDistributedAccountImporter)
+	account1, err := importer.ImportDistributedAccount(ctx,
+		"Account 1", _byteArray("01e748d098d3bcb477d636f19d510399ae18205fadf9814ee67052f88c1f77c0"), 3, vvec1, participants, []byte("pass"))
+	require.NoError(t, err)
+
+	// lookup confirms that an account can be found by both its public key and its composite public key.
+	lookup := func(t *testing.T, wallet e2wtypes.Wallet, expected e2wtypes.Account) {
+		t.Helper()
+		account, err := wallet.(distributed.WalletAccountByPublicKeyProvider).AccountByPublicKey(ctx, expected.PublicKey().Marshal())
+		require.NoError(t, err)
+		require.Equal(t, expected.ID(), account.ID())
+		compositePubKey := expected.(e2wtypes.AccountCompositePublicKeyProvider).CompositePublicKey().Marshal()
+		account, err = wallet.(distributed.WalletAccountByCompositePublicKeyProvider).AccountByCompositePublicKey(ctx, compositePubKey)
+		require.NoError(t, err)
+		require.Equal(t, expected.ID(), account.ID())
+	}
+
+	lookup(t, wallet, account1)
+
+	// Accounts imported after the keys have been indexed can be found.
+	account2, err := importer.ImportDistributedAccount(ctx,
+		"Account 2", _byteArray("0a660b6379a25e095590edeb7688a8506653e58310336efcfc98a9e34e485faa"), 3, vvec2, participants, []byte("pass"))
+	require.NoError(t, err)
+	lookup(t, wallet, account2)
+
+	_, err = wallet.(distributed.WalletAccountByPublicKeyProvider).AccountByPublicKey(ctx, vvec1[1])
+	require.ErrorIs(t, err, distributed.ErrNotFound)
+	_, err = wallet.(distributed.WalletAccountByCompositePublicKeyProvider).AccountByCompositePublicKey(ctx, vvec1[1])
+	require.ErrorIs(t, err, distributed.ErrNotFound)
+
+	// Lookups work on a reopened wallet.
+	wallet, err = distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+	require.NoError(t, err)
+	lookup(t, wallet, account1)
+	lookup(t, wallet, account2)
+
+	// Lookups work from a batch.
+	require.NoError(t, wallet.(e2wtypes.WalletBatchCreator).BatchWallet(ctx, []string{"pass"}, "batch"))
+	wallet, err = distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+	require.NoError(t, err)
+	lookup(t, wallet, account1)
+	lookup(t, wallet, account2)
+	account, err := wallet.(distributed.WalletAccountByPublicKeyProvider).AccountByPublicKey(ctx, account1.PublicKey().Marshal())
+	require.NoError(t, err)
+	require.NoError(t, account.(e2wtypes.AccountLocker).Unlock(ctx, []byte("batch")))
+
+	// A composite public key shared by more than one account is ambiguous.
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+	_, err = wallet.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+		"Account 3", _byteArray("376880b8079dca3bbd06c93958b5208929cbc169c9ce4caf8731be10e94f710e"), 3, vvec1, participants, []byte("pass"))
+	require.NoError(t, err)
+	_, err = wallet.(distributed.WalletAccountByCompositePublicKeyProvider).AccountByCompositePublicKey(ctx, vvec1[0])
+	require.EqualError(t, err, "multiple accounts with composite public key 0xa0633864987df6f7a0f40fbecbe0d15fe5317c00adccc0b816266bcf3d3d1ab6a365b3d79461b5da5a8ea09e37644731")
+}
@@ -0,0 +1,337 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/wealdtech/go-ecodec"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+// ConflictPolicy defines how a merge import handles accounts that conflict with
+// existing accounts in the wallet.  Accounts conflict if they have the same ID,
+// name or public key.  Accounts in the export that conflict with an earlier account
+// in the export are handled in the same way, except that ConflictOverwrite keeps the
+// earlier account and skips the later one.
+type ConflictPolicy int
+
+const (
+	// ConflictFail fails the import if any account conflicts; nothing is imported.
+	ConflictFail ConflictPolicy = iota
+	// ConflictSkip skips conflicting accounts.
+	ConflictSkip
+	// ConflictOverwrite replaces conflicting accounts with those from the export.
+	ConflictOverwrite
+	// ConflictRename imports conflicting accounts under a new name, and a new ID if required.
+	// Accounts whose public key is already present are skipped, as renaming would duplicate the key.
+	ConflictRename
+)
+
+// MergeReport reports the results of a merge import.
+type MergeReport struct {
+	// Imported contains the names of accounts imported without conflict.
+	Imported []string
+	// Skipped contains the names of accounts that were not imported due to conflicts.
+	Skipped []string
+	// Overwritten contains the names of accounts that replaced existing accounts.
+	Overwritten []string
+	// Renamed maps the names of accounts in the export that were renamed or given a new ID to their names in the wallet.
+	Renamed map[string]string
+}
+
+// mergeAction is the action to take for an account in a merge import.
+type mergeAction struct {
+	account   *account
+	name      string
+	conflicts []*account
+	skip      bool
+	renamed   bool
+}
+
+// MergeImport imports the accounts from an export in to the wallet of the same name in the store,
+// resolving conflicts with existing accounts according to the supplied policy.  If the wallet does
+// not exist it is created.  Any batch in the export is ignored.  If any accounts are overwritten the
+// wallet's batch is dropped, and BatchWallet must be called again to batch the wallet.
+func MergeImport(ctx context.Context,
+	encryptedData []byte,
+	passphrase []byte,
+	store e2wtypes.Store,
+	encryptor e2wtypes.Encryptor,
+	policy ConflictPolicy,
+	opts ...Option,
+) (
+	e2wtypes.Wallet,
+	*MergeReport,
+	error,
+) {
+	data, err := ecodec.Decrypt(encryptedData, passphrase)
+	if err != nil {
+		return nil, nil, exportDecryptError(encryptedData, err)
+	}
+	exported, err := newWallet(opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	exported.store = store
+	exported.encryptor = encryptor
+	ext := &walletExport{
+		Wallet: exported,
+	}
+	if err := json.Unmarshal(data, ext); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to unmarshal wallet")
+	}
+
+	// Plan the merge before creating any wallet, so that a failed plan leaves the store untouched.
+	var w *wallet
+	planner := exported
+	existingAccounts := make([]*account, 0)
+	if existing, err := OpenWallet(ctx, exported.Name(), store, encryptor, opts...); err == nil {
+		w = existing.(*wallet)
+		planner = w
+		existingAccounts = w.storedAccounts()
+	}
+	actions, err := planner.planMerge(existingAccounts, ext.Accounts, policy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if w == nil {
+		created, err := CreateWallet(ctx, exported.Name(), store, encryptor, opts...)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to create wallet %q", exported.Name())
+		}
+		w = created.(*wallet)
+	}
+
+	report, err := w.applyMerge(ctx, actions, policy)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(report.Overwritten) > 0 {
+		// The batch holds the secret keys of the replaced accounts, so can no longer be used.
+		if err := w.dropBatch(ctx); err != nil {
+			return nil, nil, errors.Wrap(err, "failed to drop batch")
+		}
+	}
+
+	return w, report, nil
+}
+
+// storedAccounts returns the readable accounts held in the store for the wallet.
+func (w *wallet) storedAccounts() []*account {
+	res := make([]*account, 0)
+	for data := range w.store.RetrieveAccounts(w.ID()) {
+		if existing, err := deserializeAccount(w, data); err == nil {
+			res = append(res, existing)
+		}
+	}
+
+	return res
+}
+
+// planMerge decides the action to take for each account in a merge import, given the accounts
+// already in the wallet.  Names in the wallet's index are never reused, even if their accounts
+// cannot be read.
+func (w *wallet) planMerge(existingAccounts []*account,
+	accountsData []json.RawMessage,
+	policy ConflictPolicy,
+) (
+	[]*mergeAction,
+	error,
+) {
+	names := make(map[string]bool)
+	for _, existing := range existingAccounts {
+		names[existing.name] = true
+	}
+
+	actions := make([]*mergeAction, 0, len(accountsData))
+	// planned contains the accounts from the export that will be stored, as they will be stored.
+	planned := make([]*account, 0, len(accountsData))
+	for _, accountData := range accountsData {
+		acc, err := deserializeAccount(w, accountData)
+		if err != nil {
+			return nil, err
+		}
+		action := &mergeAction{
+			account: acc,
+			name:    acc.name,
+		}
+
+		idConflict := false
+		nameConflict := false
+		pubkeyConflict := false
+		// conflictsWith notes any conflict between the account and another account, returning true if there is one.
+		conflictsWith := func(other *account) bool {
+			conflicts := false
+			if other.id == acc.id {
+				idConflict = true
+				conflicts = true
+			}
+			if other.name == acc.name {
+				nameConflict = true
+				conflicts = true
+			}
+			if bytes.Equal(other.publicKey.Marshal(), acc.publicKey.Marshal()) {
+				pubkeyConflict = true
+				conflicts = true
+			}
+
+			return conflicts
+		}
+		for _, existing := range existingAccounts {
+			if conflictsWith(existing) {
+				action.conflicts = append(action.conflicts, existing)
+			}
+		}
+		// Accounts in the export can also conflict with each other.
+		var plannedConflict *account
+		for _, other := range planned {
+			if conflictsWith(other) && plannedConflict == nil {
+				plannedConflict = other
+			}
+		}
+
+		if len(action.conflicts) > 0 || plannedConflict != nil {
+			switch policy {
+			case ConflictFail:
+				if len(action.conflicts) > 0 {
+					return nil, newAlreadyExistsError("account", acc.name,
+						"account %q conflicts with existing account %q", acc.name, action.conflicts[0].name)
+				}
+
+				return nil, newAlreadyExistsError("account", acc.name,
+					"account %q conflicts with account %q in the export", acc.name, plannedConflict.name)
+			case ConflictSkip:
+				action.skip = true
+			case ConflictOverwrite:
+				if plannedConflict != nil {
+					// The earlier account in the export is kept.
+					action.skip = true
+					break
+				}
+				if len(action.conflicts) > 1 {
+					if _, isAccountRemover := w.store.(AccountRemover); !isAccountRemover {
+						return nil, fmt.Errorf("account %q conflicts with multiple accounts and store %s cannot remove accounts",
+							acc.name, w.store.Name())
+					}
+				}
+				// The account replaces the first conflicting account in place.
+				acc.id = action.conflicts[0].id
+			case ConflictRename:
+				if pubkeyConflict {
+					action.skip = true
+					break
+				}
+				if nameConflict {
+					for i := 2; ; i++ {
+						candidate := fmt.Sprintf("%s (%d)", acc.name, i)
+						if !names[candidate] && !w.index.NameKnown(candidate) {
+							action.name = candidate
+							break
+						}
+					}
+				}
+				if idConflict {
+					id, err := uuid.NewRandom()
+					if err != nil {
+						return nil, errors.Wrap(err, "failed to generate ID")
+					}
+					acc.id = id
+				}
+				action.renamed = true
+			default:
+				return nil, fmt.Errorf("unknown conflict policy %d", policy)
+			}
+		}
+		if !action.skip {
+			if action.name == "" || strings.HasPrefix(action.name, "_") {
+				return nil, fmt.Errorf("invalid account name %q", action.name)
+			}
+			names[action.name] = true
+			planned = append(planned, &account{
+				id:        acc.id,
+				name:      action.name,
+				publicKey: acc.publicKey,
+			})
+		}
+		actions = append(actions, action)
+	}
+
+	return actions, nil
+}
+
+// applyMerge carries out the actions of a merge import.
+func (w *wallet) applyMerge(ctx context.Context, actions []*mergeAction, policy ConflictPolicy) (*MergeReport, error) {
+	report := &MergeReport{
+		Imported:    make([]string, 0),
+		Skipped:     make([]string, 0),
+		Overwritten: make([]string, 0),
+		Renamed:     make(map[string]string),
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	// Accounts may be replaced, so rebuild the key index on next use.
+	defer w.resetAccountKeys()
+
+	for _, action := range actions {
+		acc := action.account
+		originalName := acc.name
+		if action.skip {
+			report.Skipped = append(report.Skipped, originalName)
+			continue
+		}
+
+		if policy == ConflictOverwrite && len(action.conflicts) > 0 {
+			// The account has the ID of the first conflicting account, so replaces it in place; remove any others.
+			for _, conflict := range action.conflicts {
+				w.index.Remove(conflict.id, conflict.name)
+				delete(w.accounts, conflict.id)
+				if conflict.id == acc.id {
+					continue
+				}
+				if err := w.store.(AccountRemover).RemoveAccount(w.id, conflict.id); err != nil {
+					return nil, errors.Wrapf(err, "failed to remove account %q", conflict.name)
+				}
+			}
+		}
+
+		acc.name = action.name
+		acc.wallet = w
+		w.index.Add(acc.id, acc.name)
+		if err := acc.storeAccount(ctx); err != nil {
+			w.index.Remove(acc.id, acc.name)
+			return nil, errors.Wrapf(err, "failed to store account %q", originalName)
+		}
+		w.accounts[acc.id] = acc
+
+		switch {
+		case policy == ConflictOverwrite && len(action.conflicts) > 0:
+			report.Overwritten = append(report.Overwritten, originalName)
+		case action.renamed:
+			report.Renamed[originalName] = acc.name
+		default:
+			report.Imported = append(report.Imported, originalName)
+		}
+	}
+
+	return report, nil
+}
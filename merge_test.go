@@ -0,0 +1,344 @@
+// Copyright © 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wealdtech/go-ecodec"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	scratch "github.com/wealdtech/go-eth2-wallet-store-scratch"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+func TestMergeImport(t *testing.T) {
+	ctx := context.Background()
+	encryptor := keystorev4.New()
+	participants := map[uint64]string{1: "foo", 2: "bar", 3: "baz"}
+	account1Key := _byteArray("01e748d098d3bcb477d636f19d510399ae18205fadf9814ee67052f88c1f77c0")
+	account1VVec := [][]byte{
+		_byteArray("a0633864987df6f7a0f40fbecbe0d15fe5317c00adccc0b816266bcf3d3d1ab6a365b3d79461b5da5a8ea09e37644731"),
+		_byteArray("a7430ae4717f511e473ed87a6510c5dfdbf289b7c7c4e083270da487aa146b0291ee701e0c38033aa157a612b8fd488b"),
+		_byteArray("b5e95fbcf45c9f2730f04abab22672dddca4b10e32467ab8522d7d153a382c9656d28268b5e75361e4e39a5d12513d8c"),
+	}
+	account2Key := _byteArray("376880b8079dca3bbd06c93958b5208929cbc169c9ce4caf8731be10e94f710e")
+	account2VVec := [][]byte{
+		_byteArray("a0633864987df6f7a0f40fbecbe0d15fe5317c00adccc0b816266bcf3d3d1ab6a365b3d79461b5da5a8ea09e37644731"),
+		_byteArray("b13d6e14cce66b3827b816c974e8f52a76e86611de58bbcdac116a9e97b00240a29714646202a65ae72df480bdfa5329"),
+		_byteArray("81a00aee312320aa82316ea14b6615eb56f531ecdabc1effca2a55d0282f3c2463124b792da5ec0207d16119360bd896"),
+	}
+
+	// Source wallet contains accounts 1 and 2.
+	source, err := distributed.CreateWallet(ctx, "test wallet", scratch.New(), encryptor)
+	require.NoError(t, err)
+	require.NoError(t, source.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+	_, err = source.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+		"Account 1", account1Key, 3, account1VVec, participants, []byte("pass"))
+	require.NoError(t, err)
+	_, err = source.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+		"Account 2", account2Key, 3, account2VVec, participants, []byte("pass"))
+	require.NoError(t, err)
+	dump, err := source.(e2wtypes.WalletExporter).Export(ctx, []byte("dump"))
+	require.NoError(t, err)
+
+	// setup creates a target wallet containing account 1 under the given name.
+	setup := func(t *testing.T, name string) e2wtypes.Store {
+		t.Helper()
+		store := scratch.New()
+		target, err := distributed.CreateWallet(ctx, "test wallet", store, encryptor)
+		require.NoError(t, err)
+		require.NoError(t, target.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+		_, err = target.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+			name, account1Key, 3, account1VVec, participants, []byte("pass"))
+		require.NoError(t, err)
+
+		return store
+	}
+
+	accountNames := func(t *testing.T, wallet e2wtypes.Wallet) []string {
+		t.Helper()
+		names := make([]string, 0)
+		for account := range wallet.Accounts(ctx) {
+			names = append(names, account.Name())
+		}
+
+		return names
+	}
+
+	t.Run("NoWallet", func(t *testing.T) {
+		wallet, report, err := distributed.MergeImport(ctx, dump, []byte("dump"), scratch.New(), encryptor, distributed.ConflictFail)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"Account 1", "Account 2"}, report.Imported)
+		require.ElementsMatch(t, []string{"Account 1", "Account 2"}, accountNames(t, wallet))
+	})
+
+	t.Run("Fail", func(t *testing.T) {
+		store := setup(t, "Account 1")
+		_, _, err := distributed.MergeImport(ctx, dump, []byte("dump"), store, encryptor, distributed.ConflictFail)
+		require.EqualError(t, err, `account "Account 1" conflicts with existing account "Account 1"`)
+		wallet, err := distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"Account 1"}, accountNames(t, wallet))
+	})
+
+	t.Run("Skip", func(t *testing.T) {
+		store := setup(t, "Existing")
+		wallet, report, err := distributed.MergeImport(ctx, dump, []byte("dump"), store, encryptor, distributed.ConflictSkip)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Account 1"}, report.Skipped)
+		require.Equal(t, []string{"Account 2"}, report.Imported)
+		require.ElementsMatch(t, []string{"Existing", "Account 2"}, accountNames(t, wallet))
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		store := setup(t, "Existing")
+		wallet, report, err := distributed.MergeImport(ctx, dump, []byte("dump"), store, encryptor, distributed.ConflictOverwrite)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Account 1"}, report.Overwritten)
+		require.Equal(t, []string{"Account 2"}, report.Imported)
+		require.ElementsMatch(t, []string{"Account 1", "Account 2"}, accountNames(t, wallet))
+		_, err = wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Existing")
+		require.Error(t, err)
+	})
+
+	t.Run("OverwriteBatched", func(t *testing.T) {
+		store := scratch.New()
+		target, err := distributed.CreateWallet(ctx, "test wallet", store, encryptor)
+		require.NoError(t, err)
+		require.NoError(t, target.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+		_, err = target.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+			"Account 1", account2Key, 3, account2VVec, participants, []byte("pass"))
+		require.NoError(t, err)
+		require.NoError(t, target.(e2wtypes.WalletBatchCreator).BatchWallet(ctx, []string{"pass"}, "batch"))
+
+		partial, err := source.(distributed.WalletOptionsExporter).ExportWithOptions(ctx, []byte("dump"), distributed.WithAccountNames("Account 1"))
+		require.NoError(t, err)
+		_, report, err := distributed.MergeImport(ctx, partial, []byte("dump"), store, encryptor, distributed.ConflictOverwrite)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Account 1"}, report.Overwritten)
+
+		// The batch held the replaced key, so is no longer used.
+		wallet, err := distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+		require.NoError(t, err)
+		account, err := wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 1")
+		require.NoError(t, err)
+		require.Equal(t, account1VVec[0], account.(e2wtypes.AccountCompositePublicKeyProvider).CompositePublicKey().Marshal())
+		require.NoError(t, account.(e2wtypes.AccountLocker).Unlock(ctx, []byte("pass")))
+		verifyReport, err := wallet.(distributed.WalletVerifier).Verify(ctx)
+		require.NoError(t, err)
+		require.Empty(t, verifyReport.Problems)
+	})
+
+	t.Run("OverwriteBatchedKeyWrapped", func(t *testing.T) {
+		// Wallets that wrap their keys do not require an encryptor.
+		kms := newFakeKMS(t)
+		store := scratch.New()
+		target, err := distributed.CreateWallet(ctx, "test wallet", store, nil, distributed.WithKeyWrapper(kms))
+		require.NoError(t, err)
+		require.NoError(t, target.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+		_, err = target.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+			"Account 1", account2Key, 3, account2VVec, participants, nil)
+		require.NoError(t, err)
+		require.NoError(t, target.(e2wtypes.WalletBatchCreator).BatchWallet(ctx, nil, ""))
+
+		partial, err := source.(distributed.WalletOptionsExporter).ExportWithOptions(ctx, []byte("dump"), distributed.WithAccountNames("Account 1"))
+		require.NoError(t, err)
+		_, report, err := distributed.MergeImport(ctx, partial, []byte("dump"), store, nil, distributed.ConflictOverwrite, distributed.WithKeyWrapper(kms))
+		require.NoError(t, err)
+		require.Equal(t, []string{"Account 1"}, report.Overwritten)
+
+		wallet, err := distributed.OpenWallet(ctx, "test wallet", store, nil, distributed.WithKeyWrapper(kms))
+		require.NoError(t, err)
+		account, err := wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 1")
+		require.NoError(t, err)
+		require.Equal(t, account1VVec[0], account.(e2wtypes.AccountCompositePublicKeyProvider).CompositePublicKey().Marshal())
+		verifyReport, err := wallet.(distributed.WalletVerifier).Verify(ctx)
+		require.NoError(t, err)
+		require.Empty(t, verifyReport.Problems)
+	})
+
+	t.Run("OverwriteBatchedNoEncryptor", func(t *testing.T) {
+		store := scratch.New()
+		target, err := distributed.CreateWallet(ctx, "test wallet", store, encryptor)
+		require.NoError(t, err)
+		require.NoError(t, target.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+		_, err = target.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+			"Account 1", account2Key, 3, account2VVec, participants, []byte("pass"))
+		require.NoError(t, err)
+		require.NoError(t, target.(e2wtypes.WalletBatchCreator).BatchWallet(ctx, []string{"pass"}, "batch"))
+
+		// Exported accounts are already encrypted, so merging them does not require an encryptor.
+		partial, err := source.(distributed.WalletOptionsExporter).ExportWithOptions(ctx, []byte("dump"), distributed.WithAccountNames("Account 1"))
+		require.NoError(t, err)
+		_, report, err := distributed.MergeImport(ctx, partial, []byte("dump"), store, nil, distributed.ConflictOverwrite)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Account 1"}, report.Overwritten)
+
+		wallet, err := distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+		require.NoError(t, err)
+		account, err := wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 1")
+		require.NoError(t, err)
+		require.NoError(t, account.(e2wtypes.AccountLocker).Unlock(ctx, []byte("pass")))
+	})
+
+	t.Run("RenameSameKey", func(t *testing.T) {
+		store := setup(t, "Account 1")
+		wallet, report, err := distributed.MergeImport(ctx, dump, []byte("dump"), store, encryptor, distributed.ConflictRename)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Account 1"}, report.Skipped)
+		require.Equal(t, []string{"Account 2"}, report.Imported)
+		require.ElementsMatch(t, []string{"Account 1", "Account 2"}, accountNames(t, wallet))
+	})
+
+	t.Run("Rename", func(t *testing.T) {
+		store := scratch.New()
+		target, err := distributed.CreateWallet(ctx, "test wallet", store, encryptor)
+		require.NoError(t, err)
+		require.NoError(t, target.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+		_, err = target.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+			"Account 2", account1Key, 3, account1VVec, participants, []byte("pass"))
+		require.NoError(t, err)
+
+		_, report, err := distributed.MergeImport(ctx, dump, []byte("dump"), store, encryptor, distributed.ConflictRename)
+		require.NoError(t, err)
+		// Account 1 has the same key as the existing account so is skipped.
+		require.Equal(t, []string{"Account 1"}, report.Skipped)
+		require.Equal(t, map[string]string{"Account 2": "Account 2 (2)"}, report.Renamed)
+		wallet, err := distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"Account 2", "Account 2 (2)"}, accountNames(t, wallet))
+		renamed, err := wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 2 (2)")
+		require.NoError(t, err)
+		require.NoError(t, renamed.(e2wtypes.AccountLocker).Unlock(ctx, []byte("pass")))
+	})
+
+	t.Run("RenameID", func(t *testing.T) {
+		// The target wallet holds account 1 with the same ID as in the source wallet.
+		store := scratch.New()
+		partial, err := source.(distributed.WalletOptionsExporter).ExportWithOptions(ctx, []byte("dump"), distributed.WithAccountNames("Account 1"))
+		require.NoError(t, err)
+		_, _, err = distributed.MergeImport(ctx, partial, []byte("dump"), store, encryptor, distributed.ConflictFail)
+		require.NoError(t, err)
+
+		// Export account 2 with the ID of account 1.
+		account1, err := source.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 1")
+		require.NoError(t, err)
+		partial, err = source.(distributed.WalletOptionsExporter).ExportWithOptions(ctx, []byte("dump"), distributed.WithAccountNames("Account 2"))
+		require.NoError(t, err)
+		data, err := ecodec.Decrypt(partial, []byte("dump"))
+		require.NoError(t, err)
+		ext := make(map[string]json.RawMessage)
+		require.NoError(t, json.Unmarshal(data, &ext))
+		accounts := make([]map[string]any, 0)
+		require.NoError(t, json.Unmarshal(ext["accounts"], &accounts))
+		accounts[0]["uuid"] = account1.ID().String()
+		ext["accounts"], err = json.Marshal(accounts)
+		require.NoError(t, err)
+		data, err = json.Marshal(ext)
+		require.NoError(t, err)
+		partial, err = ecodec.Encrypt(data, []byte("dump"))
+		require.NoError(t, err)
+
+		wallet, report, err := distributed.MergeImport(ctx, partial, []byte("dump"), store, encryptor, distributed.ConflictRename)
+		require.NoError(t, err)
+		require.Empty(t, report.Imported)
+		require.Equal(t, map[string]string{"Account 2": "Account 2"}, report.Renamed)
+		account2, err := wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 2")
+		require.NoError(t, err)
+		require.NotEqual(t, account1.ID(), account2.ID())
+	})
+
+	t.Run("RenameUnreadable", func(t *testing.T) {
+		// The target wallet holds account 2 with the key of account 1, and an unreadable account 2 (2).
+		store := scratch.New()
+		target, err := distributed.CreateWallet(ctx, "test wallet", store, encryptor)
+		require.NoError(t, err)
+		require.NoError(t, target.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+		_, err = target.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+			"Account 2", account1Key, 3, account1VVec, participants, []byte("pass"))
+		require.NoError(t, err)
+		unreadable, err := target.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+			"Account 2 (2)", account2Key, 3, account2VVec, participants, []byte("pass"))
+		require.NoError(t, err)
+		require.NoError(t, store.StoreAccount(target.ID(), unreadable.ID(), []byte("{}")))
+
+		_, report, err := distributed.MergeImport(ctx, dump, []byte("dump"), store, encryptor, distributed.ConflictRename)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"Account 2": "Account 2 (3)"}, report.Renamed)
+	})
+
+	t.Run("InvalidName", func(t *testing.T) {
+		data, err := ecodec.Decrypt(dump, []byte("dump"))
+		require.NoError(t, err)
+		ext := make(map[string]json.RawMessage)
+		require.NoError(t, json.Unmarshal(data, &ext))
+		accounts := make([]map[string]any, 0)
+		require.NoError(t, json.Unmarshal(ext["accounts"], &accounts))
+		accounts[0]["name"] = "_hidden"
+		ext["accounts"], err = json.Marshal(accounts)
+		require.NoError(t, err)
+		data, err = json.Marshal(ext)
+		require.NoError(t, err)
+		invalid, err := ecodec.Encrypt(data, []byte("dump"))
+		require.NoError(t, err)
+
+		store := scratch.New()
+		_, _, err = distributed.MergeImport(ctx, invalid, []byte("dump"), store, encryptor, distributed.ConflictFail)
+		require.EqualError(t, err, `invalid account name "_hidden"`)
+		_, err = distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+		require.ErrorIs(t, err, distributed.ErrNotFound)
+	})
+
+	t.Run("DuplicateInExport", func(t *testing.T) {
+		// Repeat an account in the export.
+		data, err := ecodec.Decrypt(dump, []byte("dump"))
+		require.NoError(t, err)
+		ext := make(map[string]json.RawMessage)
+		require.NoError(t, json.Unmarshal(data, &ext))
+		accounts := make([]json.RawMessage, 0)
+		require.NoError(t, json.Unmarshal(ext["accounts"], &accounts))
+		ext["accounts"], err = json.Marshal(append(accounts, accounts[0]))
+		require.NoError(t, err)
+		data, err = json.Marshal(ext)
+		require.NoError(t, err)
+		duplicated, err := ecodec.Encrypt(data, []byte("dump"))
+		require.NoError(t, err)
+		duplicate := make(map[string]any)
+		require.NoError(t, json.Unmarshal(accounts[0], &duplicate))
+		name := duplicate["name"].(string)
+
+		store := scratch.New()
+		_, _, err = distributed.MergeImport(ctx, duplicated, []byte("dump"), store, encryptor, distributed.ConflictFail)
+		require.EqualError(t, err, fmt.Sprintf("account %q conflicts with account %q in the export", name, name))
+		// The failed import does not leave a wallet behind.
+		_, err = distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+		require.ErrorIs(t, err, distributed.ErrNotFound)
+
+		for _, policy := range []distributed.ConflictPolicy{distributed.ConflictSkip, distributed.ConflictOverwrite, distributed.ConflictRename} {
+			wallet, report, err := distributed.MergeImport(ctx, duplicated, []byte("dump"), scratch.New(), encryptor, policy)
+			require.NoError(t, err)
+			require.ElementsMatch(t, []string{"Account 1", "Account 2"}, report.Imported)
+			require.Equal(t, []string{name}, report.Skipped)
+			require.ElementsMatch(t, []string{"Account 1", "Account 2"}, accountNames(t, wallet))
+			verification, err := wallet.(distributed.WalletVerifier).Verify(ctx)
+			require.NoError(t, err)
+			require.Empty(t, verification.Problems)
+		}
+	})
+}
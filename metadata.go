@@ -0,0 +1,110 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+// AccountMetadataValuesProvider is the interface for accounts that can provide key/value metadata.
+type AccountMetadataValuesProvider interface {
+	// Metadata provides the key/value metadata for the account.
+	Metadata() map[string]string
+}
+
+// AccountMetadataSetter is the interface for accounts that can update their key/value metadata.
+type AccountMetadataSetter interface {
+	// SetMetadata replaces the key/value metadata for the account.
+	SetMetadata(ctx context.Context, metadata map[string]string) error
+}
+
+// Metadata provides the key/value metadata for the account.
+func (a *account) Metadata() map[string]string {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	return copyMetadata(a.metadata)
+}
+
+// SetMetadata replaces the key/value metadata for the account.
+// The wallet must be unlocked.  Any batch containing the account is not updated, so
+// BatchWallet should be called again for the batch to contain the new metadata.
+func (a *account) SetMetadata(ctx context.Context, metadata map[string]string) error {
+	for k := range metadata {
+		if k == "" {
+			return errors.New("metadata key missing")
+		}
+	}
+
+	err := a.updateStoredAccount(ctx, func(stored *account) error {
+		stored.metadata = copyMetadata(metadata)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	a.metadata = copyMetadata(metadata)
+	a.mutex.Unlock()
+
+	return nil
+}
+
+// WithMetadata provides only accounts with the given metadata value.
+// If supplied multiple times accounts must match all values.
+func WithMetadata(key string, value string) IterateOption {
+	return iterateOptionFunc(func(o *iterateOptions) {
+		if o.metadata == nil {
+			o.metadata = make(map[string]string)
+		}
+		o.metadata[key] = value
+	})
+}
+
+// selects returns true if the options select the given account.
+func (o *iterateOptions) selects(account e2wtypes.Account) bool {
+	if len(o.metadata) == 0 {
+		return true
+	}
+	provider, isProvider := account.(AccountMetadataValuesProvider)
+	if !isProvider {
+		return false
+	}
+	metadata := provider.Metadata()
+	for k, v := range o.metadata {
+		if value, exists := metadata[k]; !exists || value != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// copyMetadata returns a copy of metadata, or nil if there is none.
+func copyMetadata(metadata map[string]string) map[string]string {
+	if len(metadata) == 0 {
+		return nil
+	}
+	res := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		res[k] = v
+	}
+
+	return res
+}
@@ -0,0 +1,157 @@
+// Copyright © 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	scratch "github.com/wealdtech/go-eth2-wallet-store-scratch"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+func TestMetadata(t *testing.T) {
+	ctx := context.Background()
+	store := scratch.New()
+	encryptor := keystorev4.New()
+	participants := map[uint64]string{1: "foo", 2: "bar", 3: "baz"}
+
+	wallet, err := distributed.CreateWallet(ctx, "test wallet", store, encryptor)
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+	importer := wallet.(e2wtypes.WalletDistributedAccountImporter)
+	account1, err := importer.ImportDistributedAccount(ctx,
+		"Account 1",
+		_byteArray("01e748d098d3bcb477d636f19d510399ae18205fadf9814ee67052f88c1f77c0"),
+		3,
+		[][]byte{
+			_byteArray("a0633864987df6f7a0f40fbecbe0d15fe5317c00adccc0b816266bcf3d3d1ab6a365b3d79461b5da5a8ea09e37644731"),
+			_byteArray("a7430ae4717f511e473ed87a6510c5dfdbf289b7c7c4e083270da487aa146b0291ee701e0c38033aa157a612b8fd488b"),
+			_byteArray("b5e95fbcf45c9f2730f04abab22672dddca4b10e32467ab8522d7d153a382c9656d28268b5e75361e4e39a5d12513d8c"),
+		},
+		participants,
+		[]byte("pass"))
+	require.NoError(t, err)
+	account2, err := importer.ImportDistributedAccount(ctx,
+		"Account 2",
+		_byteArray("376880b8079dca3bbd06c93958b5208929cbc169c9ce4caf8731be10e94f710e"),
+		3,
+		[][]byte{
+			_byteArray("a0633864987df6f7a0f40fbecbe0d15fe5317c00adccc0b816266bcf3d3d1ab6a365b3d79461b5da5a8ea09e37644731"),
+			_byteArray("b13d6e14cce66b3827b816c974e8f52a76e86611de58bbcdac116a9e97b00240a29714646202a65ae72df480bdfa5329"),
+			_byteArray("81a00aee312320aa82316ea14b6615eb56f531ecdabc1effca2a55d0282f3c2463124b792da5ec0207d16119360bd896"),
+		},
+		participants,
+		[]byte("pass"))
+	require.NoError(t, err)
+
+	require.Equal(t, wallet.ID(), account1.(e2wtypes.AccountMetadataProvider).WalletID())
+	require.Nil(t, account1.(distributed.AccountMetadataValuesProvider).Metadata())
+
+	metadata1 := map[string]string{"validator_index": "1", "cluster": "a"}
+	require.NoError(t, account1.(distributed.AccountMetadataSetter).SetMetadata(ctx, metadata1))
+	require.NoError(t, account2.(distributed.AccountMetadataSetter).SetMetadata(ctx, map[string]string{"cluster": "b"}))
+	require.Equal(t, metadata1, account1.(distributed.AccountMetadataValuesProvider).Metadata())
+	require.EqualError(t, account2.(distributed.AccountMetadataSetter).SetMetadata(ctx, map[string]string{"": "b"}), "metadata key missing")
+
+	// Returned metadata is a copy.
+	account1.(distributed.AccountMetadataValuesProvider).Metadata()["cluster"] = "c"
+	require.Equal(t, metadata1, account1.(distributed.AccountMetadataValuesProvider).Metadata())
+
+	// metadataOf obtains the metadata for an account in a wallet.
+	metadataOf := func(t *testing.T, wallet e2wtypes.Wallet, name string) map[string]string {
+		t.Helper()
+		account, err := wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, name)
+		require.NoError(t, err)
+
+		return account.(distributed.AccountMetadataValuesProvider).Metadata()
+	}
+
+	// selected lists the names of accounts selected by the options.
+	selected := func(t *testing.T, wallet e2wtypes.Wallet, opts ...distributed.IterateOption) []string {
+		t.Helper()
+		names := make([]string, 0)
+		for res := range wallet.(distributed.WalletAccountsIterator).IterateAccounts(ctx, opts...) {
+			require.NoError(t, res.Err)
+			names = append(names, res.Name)
+		}
+
+		return names
+	}
+
+	t.Run("Persisted", func(t *testing.T) {
+		wallet, err := distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+		require.NoError(t, err)
+		require.Equal(t, metadata1, metadataOf(t, wallet, "Account 1"))
+		require.Equal(t, map[string]string{"cluster": "b"}, metadataOf(t, wallet, "Account 2"))
+	})
+
+	t.Run("Locked", func(t *testing.T) {
+		wallet, err := distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+		require.NoError(t, err)
+		account, err := wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 1")
+		require.NoError(t, err)
+		require.ErrorIs(t, account.(distributed.AccountMetadataSetter).SetMetadata(ctx, nil), distributed.ErrLocked)
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		require.Equal(t, []string{"Account 1"}, selected(t, wallet, distributed.WithMetadata("cluster", "a")))
+		require.Equal(t, []string{"Account 1"}, selected(t, wallet, distributed.WithMetadata("validator_index", "1")))
+		require.Empty(t, selected(t, wallet, distributed.WithMetadata("cluster", "a"), distributed.WithMetadata("validator_index", "2")))
+		require.Equal(t, []string{"Account 2"}, selected(t, wallet,
+			distributed.WithOrder(distributed.OrderByName),
+			distributed.WithMetadata("cluster", "b"),
+		))
+		require.Empty(t, selected(t, wallet,
+			distributed.WithOrder(distributed.OrderByName),
+			distributed.WithMetadata("cluster", "b"),
+			distributed.WithOffset(1),
+		))
+	})
+
+	t.Run("ExportImport", func(t *testing.T) {
+		dump, err := wallet.(e2wtypes.WalletExporter).Export(ctx, []byte("dump"))
+		require.NoError(t, err)
+		imported, err := distributed.Import(ctx, dump, []byte("dump"), scratch.New(), encryptor)
+		require.NoError(t, err)
+		require.Equal(t, metadata1, metadataOf(t, imported, "Account 1"))
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		require.NoError(t, wallet.(e2wtypes.WalletBatchCreator).BatchWallet(ctx, []string{"pass"}, "batch"))
+		batchWallet, err := distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+		require.NoError(t, err)
+		require.Equal(t, metadata1, metadataOf(t, batchWallet, "Account 1"))
+		require.Equal(t, []string{"Account 1"}, selected(t, batchWallet, distributed.WithMetadata("cluster", "a")))
+
+		// Updating metadata for a batch account updates the stored account, leaving the batch out of date.
+		require.NoError(t, batchWallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+		account, err := batchWallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 2")
+		require.NoError(t, err)
+		require.NoError(t, account.(distributed.AccountMetadataSetter).SetMetadata(ctx, map[string]string{"cluster": "c"}))
+		require.Equal(t, map[string]string{"cluster": "c"}, account.(distributed.AccountMetadataValuesProvider).Metadata())
+		report, err := batchWallet.(distributed.WalletVerifier).Verify(ctx)
+		require.NoError(t, err)
+		require.Len(t, report.Problems, 1)
+		require.Equal(t, "batch entry has different metadata", report.Problems[0].Description)
+
+		// The stored account retains its encrypted secret key.
+		data, err := store.RetrieveAccount(wallet.ID(), account.ID())
+		require.NoError(t, err)
+		require.Contains(t, string(data), `"crypto"`)
+	})
+}
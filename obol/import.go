@@ -0,0 +1,197 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package obol
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+// LockFile is the name of the cluster lock file in a charon directory.
+const LockFile = "cluster-lock.json"
+
+// KeystoresDir is the name of the directory containing share keystores in a charon directory.
+const KeystoresDir = "validator_keys"
+
+// keystoreJSON is the JSON representation of the parts of an EIP-2335 keystore used here.
+type keystoreJSON struct {
+	Crypto map[string]any `json:"crypto"`
+}
+
+// Entries creates import entries for the distributed validators whose shares are held in the keystores.
+// Each keystore is decrypted and matched to a validator in the lock by the public key of its share, and the
+// verification vector of the validator is recovered from its public shares.  Accounts are named after the
+// distributed public keys of their validators, and each operator becomes a participant whose name is its
+// address and whose share public key is its public share.
+func Entries(lock *Lock, keystores []*Keystore, opts ...Option) ([]*distributed.ImportEntry, error) {
+	options := parseOptions(opts)
+	if options.concurrency < 1 {
+		return nil, errors.New("concurrency must be at least 1")
+	}
+
+	// Decrypting keystores is expensive, so decrypt them concurrently.
+	secrets := make([][]byte, len(keystores))
+	errs := make([]error, len(keystores))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for n := 0; n < options.concurrency; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				secrets[i], errs[i] = decryptKeystore(keystores[i])
+			}
+		}()
+	}
+	for i := range keystores {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	entries := make([]*distributed.ImportEntry, len(keystores))
+	for i, keystore := range keystores {
+		if errs[i] != nil {
+			return nil, errors.Wrapf(errs[i], "%s", keystore.Source)
+		}
+		entry, err := importEntry(lock, secrets[i], options.endpoints)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s", keystore.Source)
+		}
+		entry.Source = keystore.Source
+		entries[i] = entry
+	}
+
+	return entries, nil
+}
+
+// decryptKeystore decrypts a keystore, returning its secret key.
+func decryptKeystore(keystore *Keystore) ([]byte, error) {
+	data := &keystoreJSON{}
+	if err := json.Unmarshal(keystore.Data, data); err != nil {
+		return nil, errors.Wrap(err, "invalid keystore")
+	}
+	if data.Crypto == nil {
+		return nil, errors.New("keystore crypto missing")
+	}
+	secret, err := keystorev4.New().Decrypt(data.Crypto, string(keystore.Password))
+	if err != nil {
+		return nil, errors.New("incorrect keystore password")
+	}
+
+	return secret, nil
+}
+
+// importEntry creates the import entry for a share of a validator in the lock.
+func importEntry(lock *Lock, secret []byte, endpoints map[uint64]string) (*distributed.ImportEntry, error) {
+	secretKey, err := e2types.BLSPrivateKeyFromBytes(secret)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid secret key")
+	}
+	sharePublicKey := secretKey.PublicKey().Marshal()
+
+	var validator *Validator
+	for _, v := range lock.Validators {
+		for _, publicShare := range v.PublicShares {
+			if bytes.Equal(publicShare, sharePublicKey) {
+				validator = v
+			}
+		}
+	}
+	if validator == nil {
+		return nil, fmt.Errorf("share public key %#x not found in cluster lock", sharePublicKey)
+	}
+
+	sharePublicKeys := make(map[uint64][]byte, len(validator.PublicShares))
+	participants := make(map[uint64]*distributed.Participant, len(validator.PublicShares))
+	for i, publicShare := range validator.PublicShares {
+		id := uint64(i + 1)
+		sharePublicKeys[id] = publicShare
+		participantSharePublicKey, err := e2types.BLSPublicKeyFromBytes(publicShare)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid public share %d", i)
+		}
+		participants[id] = &distributed.Participant{
+			Endpoint:       endpoints[id],
+			Name:           lock.Operators[i].Address,
+			SharePublicKey: participantSharePublicKey,
+		}
+	}
+	verificationVector, err := distributed.VerificationVectorFromSharePublicKeys(lock.Threshold, sharePublicKeys)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid public shares for validator %#x", validator.PublicKey)
+	}
+	if !bytes.Equal(verificationVector[0], validator.PublicKey) {
+		return nil, fmt.Errorf("public shares do not correspond to distributed public key %#x", validator.PublicKey)
+	}
+
+	return &distributed.ImportEntry{
+		Name:               fmt.Sprintf("%#x", validator.PublicKey),
+		SecretShare:        secret,
+		SigningThreshold:   lock.Threshold,
+		VerificationVector: verificationVector,
+		Participants:       participants,
+	}, nil
+}
+
+// ImportCluster imports the distributed validators of a cluster from a charon directory, containing
+// the cluster lock in cluster-lock.json and the operator's share keystores in validator_keys, in to the
+// wallet.  The wallet must be unlocked.  The accounts are imported with ImportDistributedAccounts,
+// protected by the passphrase, and a result is returned for each keystore.  No network access is
+// required.
+func ImportCluster(ctx context.Context,
+	wallet e2wtypes.Wallet,
+	dir string,
+	passphrase []byte,
+	opts ...Option,
+) (
+	[]*distributed.ImportResult,
+	error,
+) {
+	importer, isImporter := wallet.(distributed.WalletDistributedAccountsImporter)
+	if !isImporter {
+		return nil, errors.New("wallet cannot import distributed accounts in bulk")
+	}
+	options := parseOptions(opts)
+
+	data, err := os.ReadFile(filepath.Join(dir, LockFile))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read cluster lock")
+	}
+	lock, err := ParseLock(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid cluster lock")
+	}
+	keystores, err := ReadKeystores(filepath.Join(dir, KeystoresDir))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read keystores")
+	}
+	entries, err := Entries(lock, keystores, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return importer.ImportDistributedAccounts(ctx, entries, passphrase, distributed.WithConcurrency(options.concurrency))
+}
@@ -0,0 +1,304 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package obol_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	bls "github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	"github.com/wealdtech/go-eth2-wallet-distributed/obol"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	scratch "github.com/wealdtech/go-eth2-wallet-store-scratch"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+func TestMain(m *testing.M) {
+	if err := e2types.InitBLS(); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// clusterValidator is a distributed validator of a test cluster.
+type clusterValidator struct {
+	publicKey    []byte
+	publicShares [][]byte
+	shares       [][]byte
+}
+
+// newClusterValidator creates a distributed validator with the given threshold and number of operators.
+func newClusterValidator(t *testing.T, threshold int, operators int) *clusterValidator {
+	t.Helper()
+
+	msk := make([]bls.SecretKey, threshold)
+	for i := range msk {
+		msk[i].SetByCSPRNG()
+	}
+	validator := &clusterValidator{
+		publicKey: msk[0].GetPublicKey().Serialize(),
+	}
+	for i := 1; i <= operators; i++ {
+		var id bls.ID
+		require.NoError(t, id.SetDecString(fmt.Sprintf("%d", i)))
+		var share bls.SecretKey
+		require.NoError(t, share.Set(msk, &id))
+		validator.shares = append(validator.shares, share.Serialize())
+		validator.publicShares = append(validator.publicShares, share.GetPublicKey().Serialize())
+	}
+
+	return validator
+}
+
+// lockData creates a cluster lock for the validators.
+func lockData(t *testing.T, threshold int, validators []*clusterValidator) []byte {
+	t.Helper()
+
+	operators := make([]map[string]string, len(validators[0].publicShares))
+	for i := range operators {
+		operators[i] = map[string]string{
+			"address": fmt.Sprintf("0x%040x", i+1),
+			"enr":     fmt.Sprintf("enr:-operator%d", i+1),
+		}
+	}
+	distributedValidators := make([]map[string]any, len(validators))
+	for i, validator := range validators {
+		publicShares := make([]string, len(validator.publicShares))
+		for j := range validator.publicShares {
+			publicShares[j] = fmt.Sprintf("%#x", validator.publicShares[j])
+		}
+		distributedValidators[i] = map[string]any{
+			"distributed_public_key": fmt.Sprintf("%#x", validator.publicKey),
+			"public_shares":          publicShares,
+		}
+	}
+	data, err := json.Marshal(map[string]any{
+		"cluster_definition": map[string]any{
+			"name":      "test cluster",
+			"threshold": threshold,
+			"operators": operators,
+		},
+		"distributed_validators": distributedValidators,
+		"lock_hash":              "0x00",
+	})
+	require.NoError(t, err)
+
+	return data
+}
+
+// writeKeystore writes a keystore and its password in to the directory.
+func writeKeystore(t *testing.T, dir string, index int, secret []byte, password string) {
+	t.Helper()
+
+	crypto, err := keystorev4.New(keystorev4.WithCost(t, 4)).Encrypt(secret, password)
+	require.NoError(t, err)
+	data, err := json.Marshal(map[string]any{
+		"crypto":  crypto,
+		"version": 4,
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(dir, 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("keystore-%d.json", index)), data, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("keystore-%d.txt", index)), []byte(password+"\n"), 0o600))
+}
+
+func TestParseLock(t *testing.T) {
+	validator := newClusterValidator(t, 3, 4)
+	validData := lockData(t, 3, []*clusterValidator{validator})
+
+	lock, err := obol.ParseLock(validData)
+	require.NoError(t, err)
+	require.Equal(t, "test cluster", lock.Name)
+	require.Equal(t, uint32(3), lock.Threshold)
+	require.Len(t, lock.Operators, 4)
+	require.Equal(t, "0x0000000000000000000000000000000000000002", lock.Operators[1].Address)
+	require.Equal(t, "enr:-operator2", lock.Operators[1].ENR)
+	require.Len(t, lock.Validators, 1)
+	require.Equal(t, validator.publicKey, lock.Validators[0].PublicKey)
+	require.Equal(t, validator.publicShares, lock.Validators[0].PublicShares)
+
+	// Early lock versions encode keys in base64.
+	publicShares := make([]string, len(validator.publicShares))
+	for i := range validator.publicShares {
+		publicShares[i] = base64.StdEncoding.EncodeToString(validator.publicShares[i])
+	}
+	data, err := json.Marshal(map[string]any{
+		"cluster_definition": map[string]any{
+			"threshold": 3,
+			"operators": []map[string]string{{}, {}, {}, {}},
+		},
+		"distributed_validators": []map[string]any{{
+			"distributed_public_key": base64.StdEncoding.EncodeToString(validator.publicKey),
+			"public_shares":          publicShares,
+		}},
+	})
+	require.NoError(t, err)
+	lock, err = obol.ParseLock(data)
+	require.NoError(t, err)
+	require.Equal(t, validator.publicKey, lock.Validators[0].PublicKey)
+	require.Equal(t, validator.publicShares, lock.Validators[0].PublicShares)
+
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{
+			name: "Invalid",
+			data: `{`,
+			err:  "invalid JSON: unexpected end of JSON input",
+		},
+		{
+			name: "DefinitionMissing",
+			data: `{}`,
+			err:  "cluster definition missing",
+		},
+		{
+			name: "OperatorsMissing",
+			data: `{"cluster_definition":{"threshold":3}}`,
+			err:  "operators missing",
+		},
+		{
+			name: "ThresholdMissing",
+			data: `{"cluster_definition":{"operators":[{},{}]}}`,
+			err:  "threshold missing",
+		},
+		{
+			name: "ThresholdTooHigh",
+			data: `{"cluster_definition":{"threshold":3,"operators":[{},{}]}}`,
+			err:  "threshold 3 exceeds number of operators 2",
+		},
+		{
+			name: "PublicKeyMissing",
+			data: `{"cluster_definition":{"threshold":2,"operators":[{},{}]},"distributed_validators":[{"public_shares":["0x01","0x02"]}]}`,
+			err:  "invalid public key for validator 0: missing",
+		},
+		{
+			name: "PublicSharesWrongLength",
+			data: `{"cluster_definition":{"threshold":2,"operators":[{},{}]},"distributed_validators":[{"distributed_public_key":"0x01","public_shares":["0x01"]}]}`,
+			err:  "validator 0 has 1 public shares but cluster has 2 operators",
+		},
+		{
+			name: "PublicShareInvalid",
+			data: `{"cluster_definition":{"threshold":2,"operators":[{},{}]},"distributed_validators":[{"distributed_public_key":"0x01","public_shares":["0x01","0xinvalid"]}]}`,
+			err:  "invalid public share 1 for validator 0: encoding/hex: invalid byte: U+0069 'i'",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := obol.ParseLock([]byte(test.data))
+			require.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestImportCluster(t *testing.T) {
+	ctx := context.Background()
+	validators := []*clusterValidator{
+		newClusterValidator(t, 3, 4),
+		newClusterValidator(t, 3, 4),
+	}
+
+	// The charon directory of the second operator.
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, obol.LockFile), lockData(t, 3, validators), 0o600))
+	keystoresDir := filepath.Join(dir, obol.KeystoresDir)
+	writeKeystore(t, keystoresDir, 0, validators[0].shares[1], "password 0")
+	writeKeystore(t, keystoresDir, 1, validators[1].shares[1], "password 1")
+
+	wallet, err := distributed.CreateWallet(ctx, "test wallet", scratch.New(), keystorev4.New())
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+
+	results, err := obol.ImportCluster(ctx, wallet, dir, []byte("pass"),
+		obol.WithEndpoints(map[uint64]string{2: "localhost:9000"}))
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	for i, result := range results {
+		require.NoError(t, result.Err)
+		require.Equal(t, fmt.Sprintf("keystore-%d.json", i), result.Source)
+		require.Equal(t, fmt.Sprintf("%#x", validators[i].publicKey), result.Name)
+
+		account := result.Account
+		require.Equal(t, validators[i].publicKey, account.(e2wtypes.AccountCompositePublicKeyProvider).CompositePublicKey().Marshal())
+		require.Equal(t, validators[i].publicShares[1], account.(e2wtypes.AccountPublicKeyProvider).PublicKey().Marshal())
+		require.Equal(t, uint32(3), account.(e2wtypes.AccountSigningThresholdProvider).SigningThreshold())
+		participantID, err := account.(distributed.AccountParticipantIDProvider).ParticipantID()
+		require.NoError(t, err)
+		require.Equal(t, uint64(2), participantID)
+		participants := account.(distributed.AccountParticipantDescriptorsProvider).ParticipantDescriptors()
+		require.Len(t, participants, 4)
+		require.Equal(t, "localhost:9000", participants[2].Endpoint)
+		require.Equal(t, "", participants[3].Endpoint)
+		require.Equal(t, "0x0000000000000000000000000000000000000004", participants[4].Name)
+		require.Equal(t, validators[i].publicShares[3], participants[4].SharePublicKey.Marshal())
+		require.NoError(t, account.(e2wtypes.AccountLocker).Unlock(ctx, []byte("pass")))
+	}
+
+	// Importing again reports the accounts as already present.
+	results, err = obol.ImportCluster(ctx, wallet, dir, []byte("pass"))
+	require.NoError(t, err)
+	require.EqualError(t, results[0].Err, fmt.Sprintf("account with name \"%#x\" already exists", validators[0].publicKey))
+}
+
+func TestEntriesErrors(t *testing.T) {
+	validators := []*clusterValidator{
+		newClusterValidator(t, 3, 4),
+	}
+	lock, err := obol.ParseLock(lockData(t, 3, validators))
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	_, err = obol.ReadKeystores(dir)
+	require.EqualError(t, err, "no keystores found")
+
+	writeKeystore(t, dir, 0, validators[0].shares[0], "password")
+	keystores, err := obol.ReadKeystores(dir)
+	require.NoError(t, err)
+	require.Equal(t, []byte("password"), keystores[0].Password)
+
+	keystores[0].Password = []byte("wrong")
+	_, err = obol.Entries(lock, keystores)
+	require.EqualError(t, err, "keystore-0.json: incorrect keystore password")
+
+	// A share not in the cluster lock.
+	other := newClusterValidator(t, 3, 4)
+	writeKeystore(t, dir, 0, other.shares[0], "password")
+	keystores, err = obol.ReadKeystores(dir)
+	require.NoError(t, err)
+	_, err = obol.Entries(lock, keystores)
+	require.EqualError(t, err, fmt.Sprintf("keystore-0.json: share public key %#x not found in cluster lock", other.publicShares[0]))
+
+	// Public shares that do not correspond to the distributed public key.
+	lock.Validators[0].PublicKey = other.publicKey
+	writeKeystore(t, dir, 0, validators[0].shares[0], "password")
+	keystores, err = obol.ReadKeystores(dir)
+	require.NoError(t, err)
+	_, err = obol.Entries(lock, keystores)
+	require.EqualError(t, err, fmt.Sprintf("keystore-0.json: public shares do not correspond to distributed public key %#x", other.publicKey))
+
+	// Inconsistent public shares.
+	lock.Validators[0].PublicShares[3] = other.publicShares[3]
+	_, err = obol.Entries(lock, keystores)
+	require.EqualError(t, err, fmt.Sprintf("keystore-0.json: invalid public shares for validator %#x: share public key for participant 4 is inconsistent with the others", other.publicKey))
+}
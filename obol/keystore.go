@@ -0,0 +1,85 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package obol
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Keystore is an operator's keystore for its share of a distributed validator, along with its password.
+type Keystore struct {
+	// Source is the name of the keystore's file.
+	Source string
+	// Data is the EIP-2335 keystore.
+	Data []byte
+	// Password is the password for the keystore.
+	Password []byte
+}
+
+// ReadKeystores reads the keystores and their passwords from a directory, as found in charon's
+// validator_keys directory.  Each keystore-N.json file has its password in keystore-N.txt.
+// Keystores are returned in order of N.
+func ReadKeystores(dir string) ([]*Keystore, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "keystore-*.json"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list keystores")
+	}
+	if len(paths) == 0 {
+		return nil, errors.New("no keystores found")
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if keystoreIndex(paths[i]) != keystoreIndex(paths[j]) {
+			return keystoreIndex(paths[i]) < keystoreIndex(paths[j])
+		}
+
+		return paths[i] < paths[j]
+	})
+
+	keystores := make([]*Keystore, len(paths))
+	for i, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", filepath.Base(path))
+		}
+		passwordPath := strings.TrimSuffix(path, ".json") + ".txt"
+		password, err := os.ReadFile(passwordPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", filepath.Base(passwordPath))
+		}
+		keystores[i] = &Keystore{
+			Source:   filepath.Base(path),
+			Data:     data,
+			Password: bytes.TrimRight(password, "\r\n"),
+		}
+	}
+
+	return keystores, nil
+}
+
+// keystoreIndex returns the index N of a keystore-N.json file, or -1 if it does not have one.
+func keystoreIndex(path string) int {
+	index, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "keystore-"), ".json"))
+	if err != nil {
+		return -1
+	}
+
+	return index
+}
@@ -0,0 +1,148 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package obol imports distributed validators created by Obol's charon in to distributed wallets.
+package obol
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Lock contains the information from a cluster lock required to import its distributed validators.
+type Lock struct {
+	// Name is the name of the cluster.
+	Name string
+	// Threshold is the number of operators required to sign.
+	Threshold uint32
+	// Operators are the operators in the cluster, in order.
+	Operators []*Operator
+	// Validators are the distributed validators of the cluster.
+	Validators []*Validator
+}
+
+// Operator is an operator in a cluster.
+type Operator struct {
+	// Address is the Ethereum address of the operator.
+	Address string
+	// ENR is the Ethereum node record of the operator's charon node.
+	ENR string
+}
+
+// Validator is a distributed validator in a cluster.
+type Validator struct {
+	// PublicKey is the distributed public key of the validator.
+	PublicKey []byte
+	// PublicShares are the public keys of the operators' shares of the validator, in operator order.
+	PublicShares [][]byte
+}
+
+// lockJSON is the JSON representation of the parts of a cluster lock used here.
+type lockJSON struct {
+	Definition *definitionJSON  `json:"cluster_definition"`
+	Validators []*validatorJSON `json:"distributed_validators"`
+}
+
+type definitionJSON struct {
+	Name      string          `json:"name"`
+	Threshold uint32          `json:"threshold"`
+	Operators []*operatorJSON `json:"operators"`
+}
+
+type operatorJSON struct {
+	Address string `json:"address"`
+	ENR     string `json:"enr"`
+}
+
+type validatorJSON struct {
+	PublicKey    string   `json:"distributed_public_key"`
+	PublicShares []string `json:"public_shares"`
+}
+
+// ParseLock parses a cluster lock, as found in charon's cluster-lock.json.
+// The lock hash and signatures are not checked.
+func ParseLock(data []byte) (*Lock, error) {
+	lockData := &lockJSON{}
+	if err := json.Unmarshal(data, lockData); err != nil {
+		return nil, errors.Wrap(err, "invalid JSON")
+	}
+	if lockData.Definition == nil {
+		return nil, errors.New("cluster definition missing")
+	}
+	if len(lockData.Definition.Operators) == 0 {
+		return nil, errors.New("operators missing")
+	}
+	if lockData.Definition.Threshold == 0 {
+		return nil, errors.New("threshold missing")
+	}
+	if lockData.Definition.Threshold > uint32(len(lockData.Definition.Operators)) {
+		return nil, fmt.Errorf("threshold %d exceeds number of operators %d", lockData.Definition.Threshold, len(lockData.Definition.Operators))
+	}
+
+	lock := &Lock{
+		Name:       lockData.Definition.Name,
+		Threshold:  lockData.Definition.Threshold,
+		Operators:  make([]*Operator, len(lockData.Definition.Operators)),
+		Validators: make([]*Validator, len(lockData.Validators)),
+	}
+	for i, operator := range lockData.Definition.Operators {
+		if operator == nil {
+			return nil, fmt.Errorf("operator %d missing", i)
+		}
+		lock.Operators[i] = &Operator{
+			Address: operator.Address,
+			ENR:     operator.ENR,
+		}
+	}
+	for i, validator := range lockData.Validators {
+		if validator == nil {
+			return nil, fmt.Errorf("validator %d missing", i)
+		}
+		publicKey, err := decodeKey(validator.PublicKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid public key for validator %d", i)
+		}
+		if len(validator.PublicShares) != len(lock.Operators) {
+			return nil, fmt.Errorf("validator %d has %d public shares but cluster has %d operators", i, len(validator.PublicShares), len(lock.Operators))
+		}
+		lock.Validators[i] = &Validator{
+			PublicKey:    publicKey,
+			PublicShares: make([][]byte, len(validator.PublicShares)),
+		}
+		for j := range validator.PublicShares {
+			lock.Validators[i].PublicShares[j], err = decodeKey(validator.PublicShares[j])
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid public share %d for validator %d", j, i)
+			}
+		}
+	}
+
+	return lock, nil
+}
+
+// decodeKey decodes a key in a cluster lock.  Keys are 0x-prefixed hex, or base64 in early lock versions.
+func decodeKey(input string) ([]byte, error) {
+	if input == "" {
+		return nil, errors.New("missing")
+	}
+	if strings.HasPrefix(input, "0x") {
+		return hex.DecodeString(strings.TrimPrefix(input, "0x"))
+	}
+
+	return base64.StdEncoding.DecodeString(input)
+}
@@ -0,0 +1,65 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package obol
+
+import (
+	"runtime"
+)
+
+// options are the options for importing a cluster.
+type options struct {
+	endpoints   map[uint64]string
+	concurrency int
+}
+
+// Option gives options to Entries and ImportCluster.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) {
+	f(o)
+}
+
+// WithEndpoints supplies the endpoints of the operators, keyed by participant ID.  The participant ID of
+// an operator is its position in the cluster lock's list of operators plus one.  Cluster locks do not
+// contain network endpoints, so participants without a supplied endpoint are imported without one.
+func WithEndpoints(endpoints map[uint64]string) Option {
+	return optionFunc(func(o *options) {
+		o.endpoints = endpoints
+	})
+}
+
+// WithConcurrency sets the number of keystores decrypted, and accounts imported, concurrently.
+// Defaults to the number of CPUs.
+func WithConcurrency(concurrency int) Option {
+	return optionFunc(func(o *options) {
+		o.concurrency = concurrency
+	})
+}
+
+// parseOptions parses the supplied options.
+func parseOptions(opts []Option) *options {
+	options := &options{
+		endpoints:   make(map[uint64]string),
+		concurrency: runtime.NumCPU(),
+	}
+	for _, o := range opts {
+		o.apply(options)
+	}
+
+	return options
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed
+
+import (
+	"time"
+)
+
+// options are the options for a wallet.
+type options struct {
+	keyWrapper         KeyWrapper
+	batchRetryAttempts int
+	batchRetryInterval time.Duration
+	thresholdPolicy    ThresholdPolicy
+}
+
+// Option gives options to functions that create or open wallets.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) {
+	f(o)
+}
+
+// WithKeyWrapper sets a key wrapper for the wallet.  If supplied, secrets for new
+// accounts and batches are protected by the key wrapper rather than the encryptor,
+// and passphrases are ignored.
+func WithKeyWrapper(keyWrapper KeyWrapper) Option {
+	return optionFunc(func(o *options) {
+		o.keyWrapper = keyWrapper
+	})
+}
+
+// WithBatchRetry sets the policy for retrieving the wallet's batch from the store.
+// Retrieval is attempted up to the given number of times, waiting for the given
+// interval between attempts.  Corrupt batch data, and a batch that is not present in the
+// store, are not retried.
+func WithBatchRetry(attempts int, interval time.Duration) Option {
+	return optionFunc(func(o *options) {
+		o.batchRetryAttempts = attempts
+		o.batchRetryInterval = interval
+	})
+}
+
+// WithThresholdPolicy sets the policy for the signing thresholds of accounts imported in to
+// the wallet.  If not supplied the policy is ThresholdHonestMajority.  The policy is not
+// applied to existing accounts when they are read, but Verify() reports accounts that do
+// not satisfy it.
+func WithThresholdPolicy(policy ThresholdPolicy) Option {
+	return optionFunc(func(o *options) {
+		o.thresholdPolicy = policy
+	})
+}
+
+// parseOptions parses the supplied options.
+func parseOptions(opts []Option) *options {
+	options := &options{
+		batchRetryAttempts: 1,
+	}
+	for _, o := range opts {
+		o.apply(options)
+	}
+
+	return options
+}
@@ -0,0 +1,351 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+// Participant describes a participant in a distributed account.
+type Participant struct {
+	// Endpoint is the network endpoint of the participant, for example "signer1.example.com:443".
+	Endpoint string
+	// Name is the display name of the participant.
+	Name string
+	// CertificateFingerprint is the SHA-256 fingerprint of the participant's TLS certificate.
+	CertificateFingerprint []byte
+	// IdentityKey is the participant's public identity key.
+	IdentityKey []byte
+	// SharePublicKey is the public key of the participant's share of the account's secret key.
+	SharePublicKey e2types.PublicKey
+}
+
+// participantJSON is the JSON representation of a participant with more than an endpoint.
+type participantJSON struct {
+	Endpoint               string `json:"endpoint"`
+	Name                   string `json:"name,omitempty"`
+	CertificateFingerprint string `json:"certificate_fingerprint,omitempty"`
+	IdentityKey            string `json:"identity_key,omitempty"`
+	SharePubkey            string `json:"share_pubkey,omitempty"`
+}
+
+// AccountParticipantDescriptorsProvider is the interface for accounts that can provide descriptors
+// of their participants.
+type AccountParticipantDescriptorsProvider interface {
+	// ParticipantDescriptors provides the descriptors of the participants in the account.
+	ParticipantDescriptors() map[uint64]*Participant
+}
+
+// AccountParticipantsSetter is the interface for accounts that can update the descriptors of their participants.
+type AccountParticipantsSetter interface {
+	// SetParticipants replaces the descriptors of the participants in the account.
+	SetParticipants(ctx context.Context, participants map[uint64]*Participant) error
+}
+
+// AccountParticipantIDProvider is the interface for accounts that can provide the ID of the
+// participant that holds their share.
+type AccountParticipantIDProvider interface {
+	// ParticipantID provides the ID of the participant that holds the account's share.
+	ParticipantID() (uint64, error)
+}
+
+// WalletDistributedAccountWithParticipantsImporter is the interface for wallets that can import
+// distributed accounts with participant descriptors.
+type WalletDistributedAccountWithParticipantsImporter interface {
+	// ImportDistributedAccountWithParticipants creates a new distributed account in the wallet from
+	// provided data, with full descriptors of its participants.
+	ImportDistributedAccountWithParticipants(ctx context.Context,
+		name string,
+		privateKey []byte,
+		signingThreshold uint32,
+		verificationVector [][]byte,
+		participants map[uint64]*Participant,
+		passphrase []byte,
+	) (
+		e2wtypes.Account,
+		error,
+	)
+}
+
+// MarshalJSON implements json.Marshaler.
+// A participant with only an endpoint is written as a string, as used by earlier versions of this module.
+func (p *Participant) MarshalJSON() ([]byte, error) {
+	if p.Name == "" && len(p.CertificateFingerprint) == 0 && len(p.IdentityKey) == 0 && p.SharePublicKey == nil {
+		return json.Marshal(p.Endpoint)
+	}
+
+	data := &participantJSON{
+		Endpoint: p.Endpoint,
+		Name:     p.Name,
+	}
+	if len(p.CertificateFingerprint) > 0 {
+		data.CertificateFingerprint = fmt.Sprintf("%x", p.CertificateFingerprint)
+	}
+	if len(p.IdentityKey) > 0 {
+		data.IdentityKey = fmt.Sprintf("%x", p.IdentityKey)
+	}
+	if p.SharePublicKey != nil {
+		data.SharePubkey = fmt.Sprintf("%x", p.SharePublicKey.Marshal())
+	}
+
+	return json.Marshal(data)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+// A participant can be supplied either as a string containing its endpoint or as an object.
+func (p *Participant) UnmarshalJSON(input []byte) error {
+	var endpoint string
+	if err := json.Unmarshal(input, &endpoint); err == nil {
+		*p = Participant{Endpoint: endpoint}
+
+		return nil
+	}
+
+	var data participantJSON
+	if err := json.Unmarshal(input, &data); err != nil {
+		return errors.Wrap(err, "invalid JSON")
+	}
+	res := Participant{
+		Endpoint: data.Endpoint,
+		Name:     data.Name,
+	}
+	var err error
+	if data.CertificateFingerprint != "" {
+		res.CertificateFingerprint, err = hex.DecodeString(strings.TrimPrefix(data.CertificateFingerprint, "0x"))
+		if err != nil {
+			return errors.Wrap(err, "invalid certificate fingerprint")
+		}
+	}
+	if data.IdentityKey != "" {
+		res.IdentityKey, err = hex.DecodeString(strings.TrimPrefix(data.IdentityKey, "0x"))
+		if err != nil {
+			return errors.Wrap(err, "invalid identity key")
+		}
+	}
+	if data.SharePubkey != "" {
+		sharePubkey, err := hex.DecodeString(strings.TrimPrefix(data.SharePubkey, "0x"))
+		if err != nil {
+			return errors.Wrap(err, "invalid share public key")
+		}
+		res.SharePublicKey, err = e2types.BLSPublicKeyFromBytes(sharePubkey)
+		if err != nil {
+			return errors.Wrap(err, "invalid share public key")
+		}
+	}
+	*p = res
+
+	return nil
+}
+
+// copy returns a copy of the participant.
+func (p *Participant) copy() *Participant {
+	res := *p
+	res.CertificateFingerprint = bytes.Clone(p.CertificateFingerprint)
+	res.IdentityKey = bytes.Clone(p.IdentityKey)
+
+	return &res
+}
+
+// equal returns true if the two participants have the same details.
+func (p *Participant) equal(other *Participant) bool {
+	if p == nil || other == nil {
+		return p == other
+	}
+	if p.Endpoint != other.Endpoint ||
+		p.Name != other.Name ||
+		!bytes.Equal(p.CertificateFingerprint, other.CertificateFingerprint) ||
+		!bytes.Equal(p.IdentityKey, other.IdentityKey) {
+		return false
+	}
+	if p.SharePublicKey == nil || other.SharePublicKey == nil {
+		return p.SharePublicKey == nil && other.SharePublicKey == nil
+	}
+
+	return bytes.Equal(p.SharePublicKey.Marshal(), other.SharePublicKey.Marshal())
+}
+
+// copyParticipants returns a deep copy of participants.
+func copyParticipants(participants map[uint64]*Participant) map[uint64]*Participant {
+	res := make(map[uint64]*Participant, len(participants))
+	for k, v := range participants {
+		res[k] = v.copy()
+	}
+
+	return res
+}
+
+// endpointParticipants returns participants that have only an endpoint.
+func endpointParticipants(endpoints map[uint64]string) map[uint64]*Participant {
+	res := make(map[uint64]*Participant, len(endpoints))
+	for k, v := range endpoints {
+		res[k] = &Participant{Endpoint: v}
+	}
+
+	return res
+}
+
+// participantsJSON returns participants keyed by their ID as a string, as used in JSON.
+func participantsJSON(participants map[uint64]*Participant) map[string]*Participant {
+	res := make(map[string]*Participant, len(participants))
+	for k, v := range participants {
+		res[fmt.Sprintf("%d", k)] = v
+	}
+
+	return res
+}
+
+// participantsFromJSON returns participants keyed by their ID from their JSON representation.
+func participantsFromJSON(data map[string]*Participant) (map[uint64]*Participant, error) {
+	res := make(map[uint64]*Participant, len(data))
+	for k, v := range data {
+		id, err := strconv.ParseUint(k, 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid participant ID")
+		}
+		if v == nil {
+			return nil, fmt.Errorf("participant %d missing", id)
+		}
+		res[id] = v
+	}
+
+	return res, nil
+}
+
+// participantFromData obtains a participant from its generic JSON representation.
+func participantFromData(data any) (*Participant, error) {
+	switch v := data.(type) {
+	case string:
+		return &Participant{Endpoint: v}, nil
+	case map[string]any:
+		input, err := json.Marshal(v)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal participant")
+		}
+		participant := &Participant{}
+		if err := participant.UnmarshalJSON(input); err != nil {
+			return nil, errors.Wrap(err, "account participant value invalid")
+		}
+
+		return participant, nil
+	default:
+		return nil, errors.New("account participant value invalid")
+	}
+}
+
+// ParticipantDescriptors provides the descriptors of the participants in the account.
+func (a *account) ParticipantDescriptors() map[uint64]*Participant {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	return copyParticipants(a.participants)
+}
+
+// SetParticipants replaces the descriptors of the participants in the account.
+// The participant IDs must match those already in the account, as must the share public keys if
+// the account is described by them rather than by a verification vector.  Otherwise any share public
+// keys must match those obtained from the verification vector.  The wallet must be unlocked.
+// Any batch containing the account is not updated, so BatchWallet should be called again
+// for the batch to contain the new descriptors.
+func (a *account) SetParticipants(ctx context.Context, participants map[uint64]*Participant) error {
+	a.mutex.RLock()
+	matches := len(participants) == len(a.participants)
+	for k := range a.participants {
+		if participants[k] == nil {
+			matches = false
+		}
+	}
+	publicShares := a.publicShares
+	signingThreshold := a.signingThreshold
+	verificationVector := a.verificationVector
+	a.mutex.RUnlock()
+	if !matches {
+		return errors.New("participant IDs do not match account")
+	}
+	if publicShares {
+		// The account is described by its participants' share public keys, so they cannot change.
+		newVerificationVector, err := verificationVectorFromParticipants(signingThreshold, participants)
+		if err != nil || !equalVerificationVectors(newVerificationVector, verificationVector) {
+			return errors.New("participant share public keys do not match account")
+		}
+	} else {
+		for id, participant := range participants {
+			if participant.SharePublicKey == nil {
+				continue
+			}
+			sharePubKey, err := evaluateVerificationVector(verificationVector, id)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(participant.SharePublicKey.Marshal(), sharePubKey) {
+				return fmt.Errorf("share public key for participant %d does not match account", id)
+			}
+		}
+	}
+
+	err := a.updateStoredAccount(ctx, func(stored *account) error {
+		stored.participants = copyParticipants(participants)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	a.participants = copyParticipants(participants)
+	a.mutex.Unlock()
+
+	return nil
+}
+
+// ParticipantID provides the ID of the participant that holds the account's share.
+// This is the participant whose share public key matches the account's public key, either as
+// supplied in the participant descriptors or obtained from the verification vector.
+func (a *account) ParticipantID() (uint64, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	if a.watchOnly {
+		return 0, newWatchOnlyError(a.name, "watch-only account does not hold a share")
+	}
+	pubKey := a.publicKey.Marshal()
+	for id, participant := range a.participants {
+		if participant.SharePublicKey != nil && bytes.Equal(participant.SharePublicKey.Marshal(), pubKey) {
+			return id, nil
+		}
+	}
+	if len(a.verificationVector) > 0 {
+		for id := range a.participants {
+			sharePubKey, err := evaluateVerificationVector(a.verificationVector, id)
+			if err != nil {
+				return 0, err
+			}
+			if bytes.Equal(sharePubKey, pubKey) {
+				return id, nil
+			}
+		}
+	}
+
+	return 0, errors.New("account public key does not match any participant")
+}
@@ -0,0 +1,228 @@
+// Copyright © 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	scratch "github.com/wealdtech/go-eth2-wallet-store-scratch"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+func TestParticipantJSON(t *testing.T) {
+	sharePubKey, err := e2types.BLSPublicKeyFromBytes(_byteArray("a7430ae4717f511e473ed87a6510c5dfdbf289b7c7c4e083270da487aa146b0291ee701e0c38033aa157a612b8fd488b"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		participant *distributed.Participant
+		input       []byte
+		err         string
+	}{
+		{
+			name:        "EndpointOnly",
+			participant: &distributed.Participant{Endpoint: "signer1:443"},
+			input:       []byte(`"signer1:443"`),
+		},
+		{
+			name: "Full",
+			participant: &distributed.Participant{
+				Endpoint:               "signer1:443",
+				Name:                   "Signer 1",
+				CertificateFingerprint: []byte{0x01, 0x02},
+				IdentityKey:            []byte{0x03, 0x04},
+				SharePublicKey:         sharePubKey,
+			},
+			input: []byte(`{"endpoint":"signer1:443","name":"Signer 1","certificate_fingerprint":"0102","identity_key":"0304","share_pubkey":"a7430ae4717f511e473ed87a6510c5dfdbf289b7c7c4e083270da487aa146b0291ee701e0c38033aa157a612b8fd488b"}`),
+		},
+		{
+			name:  "Invalid",
+			input: []byte(`true`),
+			err:   "invalid JSON: json: cannot unmarshal bool into Go value of type distributed.participantJSON",
+		},
+		{
+			name:  "FingerprintInvalid",
+			input: []byte(`{"endpoint":"signer1:443","certificate_fingerprint":"zz"}`),
+			err:   "invalid certificate fingerprint: encoding/hex: invalid byte: U+007A 'z'",
+		},
+		{
+			name:  "SharePubkeyInvalid",
+			input: []byte(`{"endpoint":"signer1:443","share_pubkey":"0102"}`),
+			err:   "invalid share public key: public key must be 48 bytes",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var participant distributed.Participant
+			err := json.Unmarshal(test.input, &participant)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.participant.Endpoint, participant.Endpoint)
+			require.Equal(t, test.participant.Name, participant.Name)
+			require.Equal(t, test.participant.CertificateFingerprint, participant.CertificateFingerprint)
+			require.Equal(t, test.participant.IdentityKey, participant.IdentityKey)
+			data, err := json.Marshal(test.participant)
+			require.NoError(t, err)
+			require.Equal(t, string(test.input), string(data))
+		})
+	}
+}
+
+func TestParticipants(t *testing.T) {
+	ctx := context.Background()
+	store := scratch.New()
+	encryptor := keystorev4.New()
+	vvec := [][]byte{
+		_byteArray("a0633864987df6f7a0f40fbecbe0d15fe5317c00adccc0b816266bcf3d3d1ab6a365b3d79461b5da5a8ea09e37644731"),
+		_byteArray("a7430ae4717f511e473ed87a6510c5dfdbf289b7c7c4e083270da487aa146b0291ee701e0c38033aa157a612b8fd488b"),
+		_byteArray("b5e95fbcf45c9f2730f04abab22672dddca4b10e32467ab8522d7d153a382c9656d28268b5e75361e4e39a5d12513d8c"),
+	}
+	// The share public key of participant 1, obtained from the verification vector.
+	sharePubKey, err := e2types.BLSPublicKeyFromBytes(_byteArray("8eb535b29110a80e835df2944b382001957639fa153220fd33e70b19628b53d0b19be1aa0e782c6cffb1c7295b1dab35"))
+	require.NoError(t, err)
+	participants := map[uint64]*distributed.Participant{
+		1: {
+			Endpoint:               "signer1:443",
+			Name:                   "Signer 1",
+			CertificateFingerprint: []byte{0x01, 0x02},
+			SharePublicKey:         sharePubKey,
+		},
+		2: {Endpoint: "signer2:443", IdentityKey: []byte{0x03, 0x04}},
+		3: {Endpoint: "signer3:443"},
+	}
+	endpoints := map[uint64]string{1: "signer1:443", 2: "signer2:443", 3: "signer3:443"}
+
+	wallet, err := distributed.CreateWallet(ctx, "test wallet", store, encryptor)
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+	importer := wallet.(distributed.WalletDistributedAccountWithParticipantsImporter)
+	_, err = importer.ImportDistributedAccountWithParticipants(ctx,
+		"Account 1", _byteArray("01e748d098d3bcb477d636f19d510399ae18205fadf9814ee67052f88c1f77c0"), 3, vvec,
+		map[uint64]*distributed.Participant{1: participants[1], 2: nil, 3: participants[3]}, []byte("pass"))
+	require.EqualError(t, err, "participant 2 missing")
+	account, err := importer.ImportDistributedAccountWithParticipants(ctx,
+		"Account 1", _byteArray("01e748d098d3bcb477d636f19d510399ae18205fadf9814ee67052f88c1f77c0"), 3, vvec, participants, []byte("pass"))
+	require.NoError(t, err)
+
+	// descriptorsOf obtains the participant descriptors for an account in a wallet.
+	descriptorsOf := func(t *testing.T, wallet e2wtypes.Wallet, name string) map[uint64]*distributed.Participant {
+		t.Helper()
+		account, err := wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, name)
+		require.NoError(t, err)
+		require.Equal(t, endpoints, account.(e2wtypes.AccountParticipantsProvider).Participants())
+
+		return account.(distributed.AccountParticipantDescriptorsProvider).ParticipantDescriptors()
+	}
+
+	require.Equal(t, endpoints, account.(e2wtypes.AccountParticipantsProvider).Participants())
+	require.Equal(t, participants, account.(distributed.AccountParticipantDescriptorsProvider).ParticipantDescriptors())
+
+	// Returned descriptors are a copy.
+	account.(distributed.AccountParticipantDescriptorsProvider).ParticipantDescriptors()[1].Name = "Changed"
+	require.Equal(t, "Signer 1", account.(distributed.AccountParticipantDescriptorsProvider).ParticipantDescriptors()[1].Name)
+
+	t.Run("Persisted", func(t *testing.T) {
+		wallet, err := distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+		require.NoError(t, err)
+		descriptors := descriptorsOf(t, wallet, "Account 1")
+		require.Equal(t, "Signer 1", descriptors[1].Name)
+		require.Equal(t, []byte{0x01, 0x02}, descriptors[1].CertificateFingerprint)
+		require.Equal(t, sharePubKey.Marshal(), descriptors[1].SharePublicKey.Marshal())
+		require.Equal(t, []byte{0x03, 0x04}, descriptors[2].IdentityKey)
+		require.Equal(t, &distributed.Participant{Endpoint: "signer3:443"}, descriptors[3])
+	})
+
+	t.Run("EndpointsOnly", func(t *testing.T) {
+		account, err := wallet.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+			"Account 2", _byteArray("376880b8079dca3bbd06c93958b5208929cbc169c9ce4caf8731be10e94f710e"), 3, vvec, endpoints, []byte("pass"))
+		require.NoError(t, err)
+		require.Equal(t, &distributed.Participant{Endpoint: "signer1:443"},
+			account.(distributed.AccountParticipantDescriptorsProvider).ParticipantDescriptors()[1])
+
+		// Accounts with only endpoints store participants as strings.
+		data, err := store.RetrieveAccount(wallet.ID(), account.ID())
+		require.NoError(t, err)
+		require.Contains(t, string(data), `"participants":{"1":"signer1:443","2":"signer2:443","3":"signer3:443"}`)
+	})
+
+	t.Run("Set", func(t *testing.T) {
+		setter := account.(distributed.AccountParticipantsSetter)
+		require.EqualError(t, setter.SetParticipants(ctx, map[uint64]*distributed.Participant{1: participants[1]}),
+			"participant IDs do not match account")
+		require.EqualError(t, setter.SetParticipants(ctx, map[uint64]*distributed.Participant{
+			1: participants[1], 2: participants[2], 4: participants[3],
+		}), "participant IDs do not match account")
+		mismatched, err := e2types.BLSPublicKeyFromBytes(vvec[1])
+		require.NoError(t, err)
+		require.EqualError(t, setter.SetParticipants(ctx, map[uint64]*distributed.Participant{
+			1: {Endpoint: "signer1:443", SharePublicKey: mismatched}, 2: participants[2], 3: participants[3],
+		}), "share public key for participant 1 does not match account")
+
+		updated := map[uint64]*distributed.Participant{
+			1: {Endpoint: "signer1.example.com:443", Name: "Signer 1"},
+			2: participants[2],
+			3: participants[3],
+		}
+		require.NoError(t, setter.SetParticipants(ctx, updated))
+		require.Equal(t, updated, account.(distributed.AccountParticipantDescriptorsProvider).ParticipantDescriptors())
+
+		wallet, err := distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+		require.NoError(t, err)
+		account, err := wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 1")
+		require.NoError(t, err)
+		require.Equal(t, "signer1.example.com:443", account.(e2wtypes.AccountParticipantsProvider).Participants()[1])
+		require.ErrorIs(t, account.(distributed.AccountParticipantsSetter).SetParticipants(ctx, updated), distributed.ErrLocked)
+
+		require.NoError(t, setter.SetParticipants(ctx, participants))
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		require.NoError(t, wallet.(e2wtypes.WalletBatchCreator).BatchWallet(ctx, []string{"pass"}, "batch"))
+		batchWallet, err := distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+		require.NoError(t, err)
+		descriptors := descriptorsOf(t, batchWallet, "Account 1")
+		require.Equal(t, "Signer 1", descriptors[1].Name)
+		require.Equal(t, sharePubKey.Marshal(), descriptors[1].SharePublicKey.Marshal())
+
+		report, err := batchWallet.(distributed.WalletVerifier).Verify(ctx)
+		require.NoError(t, err)
+		require.True(t, report.OK())
+	})
+
+	t.Run("Keystore", func(t *testing.T) {
+		require.NoError(t, account.(e2wtypes.AccountLocker).Unlock(ctx, []byte("pass")))
+		keystore, sidecar, err := account.(distributed.AccountKeystoreExporter).ExportKeystore(ctx, []byte("keystore pass"))
+		require.NoError(t, err)
+
+		wallet, err := distributed.CreateWallet(ctx, "test wallet", scratch.New(), encryptor)
+		require.NoError(t, err)
+		require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+		_, err = wallet.(distributed.WalletKeystoreImporter).ImportKeystore(ctx, "", keystore, sidecar, []byte("keystore pass"), []byte("pass"))
+		require.NoError(t, err)
+		descriptors := descriptorsOf(t, wallet, "Account 1")
+		require.Equal(t, "Signer 1", descriptors[1].Name)
+		require.Equal(t, []byte{0x03, 0x04}, descriptors[2].IdentityKey)
+	})
+}
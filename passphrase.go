@@ -0,0 +1,138 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+// AccountPassphraseChanger is the interface for accounts that can change the passphrase protecting their secret key.
+type AccountPassphraseChanger interface {
+	// ChangePassphrase re-encrypts the account's secret key with a new passphrase.
+	ChangePassphrase(ctx context.Context, oldPassphrase []byte, newPassphrase []byte) error
+}
+
+// WalletBatchPassphraseChanger is the interface for wallets that can change the passphrase protecting their batch.
+type WalletBatchPassphraseChanger interface {
+	// ChangeBatchPassphrase re-encrypts the secret keys in the wallet's batch with a new passphrase.
+	ChangeBatchPassphrase(ctx context.Context, oldPassphrase []byte, newPassphrase []byte) error
+}
+
+// ChangePassphrase re-encrypts the account's secret key with a new passphrase.
+// The wallet must be unlocked.  Accounts protected by a key wrapper do not use passphrases, so
+// cannot have them changed.  Any batch containing the account is not updated, and continues to
+// be protected by the batch passphrase.
+func (a *account) ChangePassphrase(ctx context.Context, oldPassphrase []byte, newPassphrase []byte) error {
+	var crypto map[string]any
+	err := a.updateStoredAccount(ctx, func(stored *account) error {
+		if stored.watchOnly {
+			return newWatchOnlyError(stored.name, "watch-only account has no passphrase")
+		}
+		if stored.keyWrapper != nil {
+			return errors.New("account secret is protected by a key wrapper")
+		}
+		secret, err := stored.decryptSecret(ctx, oldPassphrase)
+		if err != nil {
+			return err
+		}
+		secretKey, err := e2types.BLSPrivateKeyFromBytes(secret)
+		if err != nil {
+			return errors.Wrap(err, "failed to obtain private key")
+		}
+		if !bytes.Equal(secretKey.PublicKey().Marshal(), stored.publicKey.Marshal()) {
+			return newCorruptDataError(nil, "private key does not correspond to public key")
+		}
+		crypto, err = stored.encryptor.Encrypt(secret, string(newPassphrase))
+		if err != nil {
+			return errors.Wrap(err, "failed to encrypt private key")
+		}
+		stored.crypto = crypto
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	if a.crypto != nil {
+		a.crypto = crypto
+	}
+	a.mutex.Unlock()
+
+	return nil
+}
+
+// ChangeBatchPassphrase re-encrypts the secret keys in the wallet's batch with a new passphrase.
+// Unlike BatchWallet this does not require the passphrases of the individual accounts, and the
+// accounts in the batch are unchanged.  Batches protected by a key wrapper do not use passphrases,
+// so cannot have them changed.
+func (w *wallet) ChangeBatchPassphrase(ctx context.Context, oldPassphrase []byte, newPassphrase []byte) error {
+	batchRetriever, isBatchRetriever := w.store.(e2wtypes.BatchRetriever)
+	if !isBatchRetriever {
+		return fmt.Errorf("store %s cannot retrieve batches", w.store.Name())
+	}
+	batchStorer, isBatchStorer := w.store.(e2wtypes.BatchStorer)
+	if !isBatchStorer {
+		return fmt.Errorf("store %s cannot store batches", w.store.Name())
+	}
+
+	w.batchMutex.Lock()
+	defer w.batchMutex.Unlock()
+
+	serializedBatch, err := batchRetriever.RetrieveBatch(ctx, w.id)
+	if err != nil {
+		return errors.Wrap(err, "failed to retrieve batch")
+	}
+	b := &batch{
+		keyWrapper: w.keyWrapper,
+	}
+	if err := json.Unmarshal(serializedBatch, b); err != nil {
+		return unmarshalError(err, "failed to unmarshal batch")
+	}
+	if len(b.entries) == 0 {
+		return errors.New("batch is empty")
+	}
+	if b.keyWrapper != nil {
+		return errors.New("batch is protected by a key wrapper")
+	}
+
+	secretBytes, err := b.decrypt(ctx, oldPassphrase)
+	if err != nil {
+		return err
+	}
+	b.crypto, err = b.encryptor.Encrypt(secretBytes, string(newPassphrase))
+	if err != nil {
+		return errors.Wrap(err, "failed to encrypt batch")
+	}
+	serializedBatch, err = json.Marshal(b)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal batch")
+	}
+	if err := batchStorer.StoreBatch(ctx, w.id, w.name, serializedBatch); err != nil {
+		return errors.Wrap(err, "failed to store batch")
+	}
+
+	// Reload the batch so that its accounts are unlocked with the new passphrase.
+	w.batchRetrieved = false
+
+	return nil
+}
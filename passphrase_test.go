@@ -0,0 +1,89 @@
+// Copyright © 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	scratch "github.com/wealdtech/go-eth2-wallet-store-scratch"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+func TestChangePassphrase(t *testing.T) {
+	ctx := context.Background()
+	store := scratch.New()
+	wallet := removalWallet(t, store)
+	account, err := wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 1")
+	require.NoError(t, err)
+	changer := account.(distributed.AccountPassphraseChanger)
+
+	err = changer.ChangePassphrase(ctx, []byte("wrong"), []byte("new pass"))
+	require.ErrorIs(t, err, distributed.ErrWrongPassphrase)
+
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Lock(ctx))
+	err = changer.ChangePassphrase(ctx, []byte("pass"), []byte("new pass"))
+	require.ErrorIs(t, err, distributed.ErrLocked)
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+
+	require.NoError(t, changer.ChangePassphrase(ctx, []byte("pass"), []byte("new pass")))
+	require.Error(t, account.(e2wtypes.AccountLocker).Unlock(ctx, []byte("pass")))
+	require.NoError(t, account.(e2wtypes.AccountLocker).Unlock(ctx, []byte("new pass")))
+
+	// The change is persisted.
+	reopened, err := distributed.OpenWallet(ctx, "test wallet", store, keystorev4.New())
+	require.NoError(t, err)
+	account, err = reopened.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 1")
+	require.NoError(t, err)
+	require.Error(t, account.(e2wtypes.AccountLocker).Unlock(ctx, []byte("pass")))
+	require.NoError(t, account.(e2wtypes.AccountLocker).Unlock(ctx, []byte("new pass")))
+}
+
+func TestChangeBatchPassphrase(t *testing.T) {
+	ctx := context.Background()
+	store := scratch.New()
+	wallet := removalWallet(t, store)
+	changer := wallet.(distributed.WalletBatchPassphraseChanger)
+
+	err := changer.ChangeBatchPassphrase(ctx, []byte("batch passphrase"), []byte("new batch passphrase"))
+	require.ErrorContains(t, err, "failed to retrieve batch")
+
+	require.NoError(t, wallet.(e2wtypes.WalletBatchCreator).BatchWallet(ctx, []string{"pass"}, "batch passphrase"))
+	wallet, err = distributed.OpenWallet(ctx, "test wallet", store, keystorev4.New())
+	require.NoError(t, err)
+	changer = wallet.(distributed.WalletBatchPassphraseChanger)
+
+	err = changer.ChangeBatchPassphrase(ctx, []byte("wrong"), []byte("new batch passphrase"))
+	require.ErrorIs(t, err, distributed.ErrWrongPassphrase)
+	require.NoError(t, changer.ChangeBatchPassphrase(ctx, []byte("batch passphrase"), []byte("new batch passphrase")))
+
+	// The wallet in use reloads the batch.
+	account, err := wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 2")
+	require.NoError(t, err)
+	require.Error(t, account.(e2wtypes.AccountLocker).Unlock(ctx, []byte("batch passphrase")))
+	require.NoError(t, account.(e2wtypes.AccountLocker).Unlock(ctx, []byte("new batch passphrase")))
+
+	// The change is persisted.
+	reopened, err := distributed.OpenWallet(ctx, "test wallet", store, keystorev4.New())
+	require.NoError(t, err)
+	count := 0
+	for account := range reopened.Accounts(ctx) {
+		require.NoError(t, account.(e2wtypes.AccountLocker).Unlock(ctx, []byte("new batch passphrase")))
+		count++
+	}
+	require.Equal(t, 3, count)
+}
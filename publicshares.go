@@ -0,0 +1,95 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+)
+
+// participantSharePublicKeys returns the share public keys of the participants, or nil if any
+// participant does not have a share public key.
+func participantSharePublicKeys(participants map[uint64]*Participant) map[uint64][]byte {
+	if len(participants) == 0 {
+		return nil
+	}
+	res := make(map[uint64][]byte, len(participants))
+	for id, participant := range participants {
+		if participant == nil || participant.SharePublicKey == nil {
+			return nil
+		}
+		res[id] = participant.SharePublicKey.Marshal()
+	}
+
+	return res
+}
+
+// verificationVectorFromParticipants derives a verification vector from the share public keys of
+// the participants.  Some distributed key generation systems publish only share public keys, so
+// accounts described by them do not store a verification vector; it is derived with this when the
+// account is created or loaded, allowing both forms of account to be treated the same way.
+func verificationVectorFromParticipants(signingThreshold uint32,
+	participants map[uint64]*Participant,
+) (
+	[]e2types.PublicKey,
+	error,
+) {
+	sharePublicKeys := participantSharePublicKeys(participants)
+	if sharePublicKeys == nil {
+		return nil, errors.New("not all participants have share public keys")
+	}
+	verificationVector, err := VerificationVectorFromSharePublicKeys(signingThreshold, sharePublicKeys)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to derive verification vector from share public keys")
+	}
+	res := make([]e2types.PublicKey, len(verificationVector))
+	for i := range verificationVector {
+		res[i], err = e2types.BLSPublicKeyFromBytes(verificationVector[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to obtain BLS public key for verification vector %d", i)
+		}
+	}
+
+	return res, nil
+}
+
+// storedVerificationVector returns the verification vector as stored for the account, which is
+// empty for accounts described by the share public keys of their participants.
+func (a *account) storedVerificationVector() [][]byte {
+	if a.publicShares {
+		return [][]byte{}
+	}
+	res := make([][]byte, len(a.verificationVector))
+	for i := range a.verificationVector {
+		res[i] = a.verificationVector[i].Marshal()
+	}
+
+	return res
+}
+
+// equalVerificationVectors returns true if the two verification vectors are the same.
+func equalVerificationVectors(a []e2types.PublicKey, b []e2types.PublicKey) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i].Marshal(), b[i].Marshal()) {
+			return false
+		}
+	}
+
+	return true
+}
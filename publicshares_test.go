@@ -0,0 +1,114 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	scratch "github.com/wealdtech/go-eth2-wallet-store-scratch"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+// publicShareParticipants returns participants described by the public keys of their shares.
+func publicShareParticipants(t *testing.T, shares map[uint64][]byte) map[uint64]*distributed.Participant {
+	t.Helper()
+
+	res := make(map[uint64]*distributed.Participant, len(shares))
+	for id, pubKey := range sharePublicKeys(t, shares) {
+		sharePublicKey, err := e2types.BLSPublicKeyFromBytes(pubKey)
+		require.NoError(t, err)
+		res[id] = &distributed.Participant{
+			Endpoint:       fmt.Sprintf("signer%d:443", id),
+			SharePublicKey: sharePublicKey,
+		}
+	}
+
+	return res
+}
+
+func TestPublicShares(t *testing.T) {
+	ctx := context.Background()
+	store := scratch.New()
+	encryptor := keystorev4.New()
+	wallet, err := distributed.CreateWallet(ctx, "test wallet", store, encryptor)
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+	importer := wallet.(distributed.WalletDistributedAccountWithParticipantsImporter)
+
+	vvec, shares := generateShares(t, 2, 1, 2, 3)
+	participants := publicShareParticipants(t, shares)
+
+	// Shares alone are not sufficient without a verification vector.
+	_, err = importer.ImportDistributedAccountWithParticipants(ctx, "Account 1", shares[2], 2, nil,
+		map[uint64]*distributed.Participant{1: participants[1], 2: participants[2], 3: {Endpoint: "signer3:443"}}, []byte("pass"))
+	require.EqualError(t, err, "verification vector missing")
+
+	// Shares from different accounts are rejected.
+	_, otherShares := generateShares(t, 2, 3)
+	_, err = importer.ImportDistributedAccountWithParticipants(ctx, "Account 1", shares[2], 2, nil,
+		map[uint64]*distributed.Participant{1: participants[1], 2: participants[2], 3: publicShareParticipants(t, otherShares)[3]}, []byte("pass"))
+	require.EqualError(t, err, "failed to derive verification vector from share public keys: share public key for participant 3 is inconsistent with the others")
+
+	account, err := importer.ImportDistributedAccountWithParticipants(ctx, "Account 1", shares[2], 2, nil, participants, []byte("pass"))
+	require.NoError(t, err)
+	require.Equal(t, vvec[0], account.(e2wtypes.AccountCompositePublicKeyProvider).CompositePublicKey().Marshal())
+	require.Len(t, account.(e2wtypes.AccountVerificationVectorProvider).VerificationVector(), 2)
+	participantID, err := account.(distributed.AccountParticipantIDProvider).ParticipantID()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), participantID)
+
+	// The verification vector is not stored.
+	for data := range store.RetrieveAccounts(wallet.ID()) {
+		stored := make(map[string]any)
+		require.NoError(t, json.Unmarshal(data, &stored))
+		require.NotContains(t, stored, "verificationvector")
+	}
+
+	// The share public keys cannot change.
+	replacement := publicShareParticipants(t, shares)
+	replacement[3] = publicShareParticipants(t, otherShares)[3]
+	err = account.(distributed.AccountParticipantsSetter).SetParticipants(ctx, replacement)
+	require.EqualError(t, err, "participant share public keys do not match account")
+	replacement = publicShareParticipants(t, shares)
+	replacement[1].Name = "first"
+	require.NoError(t, account.(distributed.AccountParticipantsSetter).SetParticipants(ctx, replacement))
+
+	// The account is reconstructed from storage and from a batch.
+	require.NoError(t, wallet.(e2wtypes.WalletBatchCreator).BatchWallet(ctx, []string{"pass"}, "batch"))
+	for _, unlock := range []bool{false, true} {
+		wallet, err = distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+		require.NoError(t, err)
+		if unlock {
+			require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+			obtained, err := wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 1")
+			require.NoError(t, err)
+			require.NoError(t, obtained.(e2wtypes.AccountLocker).Unlock(ctx, []byte("batch")))
+		}
+		obtained, err := wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 1")
+		require.NoError(t, err)
+		require.Equal(t, vvec[0], obtained.(e2wtypes.AccountCompositePublicKeyProvider).CompositePublicKey().Marshal())
+		require.Equal(t, "first", obtained.(distributed.AccountParticipantDescriptorsProvider).ParticipantDescriptors()[1].Name)
+	}
+
+	report, err := wallet.(distributed.WalletVerifier).Verify(ctx)
+	require.NoError(t, err)
+	require.Empty(t, report.Problems)
+}
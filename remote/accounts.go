@@ -0,0 +1,107 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+// accountCache holds the accounts of a wallet by their composite public key.
+type accountCache struct {
+	wallet      e2wtypes.Wallet
+	provider    distributed.WalletAccountByCompositePublicKeyProvider
+	passphrases [][]byte
+	accounts    map[string]e2wtypes.Account
+	mutex       sync.Mutex
+}
+
+// newAccountCache creates a new account cache for the wallet, unlocking accounts with the passphrases.
+func newAccountCache(wallet e2wtypes.Wallet, passphrases [][]byte) (*accountCache, error) {
+	provider, isProvider := wallet.(distributed.WalletAccountByCompositePublicKeyProvider)
+	if !isProvider {
+		return nil, errors.New("wallet cannot provide accounts by composite public key")
+	}
+
+	return &accountCache{
+		wallet:      wallet,
+		provider:    provider,
+		passphrases: passphrases,
+		accounts:    make(map[string]e2wtypes.Account),
+	}, nil
+}
+
+// account obtains the account with the given composite public key.
+// If unlock is true the account is unlocked, returning an error if this is not possible.
+func (c *accountCache) account(ctx context.Context, compositePublicKey []byte, unlock bool) (e2wtypes.Account, error) {
+	// Accounts are obtained under the lock, as wallets do not support concurrent retrieval of accounts.
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	account, exists := c.accounts[string(compositePublicKey)]
+	if !exists {
+		var err error
+		account, err = c.provider.AccountByCompositePublicKey(ctx, compositePublicKey)
+		if err != nil {
+			return nil, err
+		}
+		c.accounts[string(compositePublicKey)] = account
+	}
+	if !unlock {
+		return account, nil
+	}
+
+	if locker, isLocker := account.(e2wtypes.AccountLocker); isLocker {
+		unlocked, err := locker.IsUnlocked(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to obtain account lock status")
+		}
+		for _, passphrase := range c.passphrases {
+			if unlocked {
+				break
+			}
+			unlocked = locker.Unlock(ctx, passphrase) == nil
+		}
+		if !unlocked {
+			return nil, errors.Wrap(distributed.ErrLocked, "account is locked")
+		}
+	}
+
+	return account, nil
+}
+
+// compositePublicKeys returns the composite public keys of the accounts in the wallet.
+// Watch-only accounts cannot provide partial signatures, so are only included if includeWatchOnly is set.
+func (c *accountCache) compositePublicKeys(ctx context.Context, includeWatchOnly bool) [][]byte {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	res := make([][]byte, 0)
+	for account := range c.wallet.Accounts(ctx) {
+		if provider, isProvider := account.(distributed.AccountWatchOnlyProvider); isProvider && provider.WatchOnly() && !includeWatchOnly {
+			continue
+		}
+		if provider, isProvider := account.(e2wtypes.AccountCompositePublicKeyProvider); isProvider {
+			if compositePubKey := provider.CompositePublicKey(); compositePubKey != nil {
+				res = append(res, compositePubKey.Marshal())
+			}
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,206 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+// Transport obtains partial signatures from participants.
+type Transport interface {
+	// Sign requests a partial signature from the participant.
+	Sign(ctx context.Context, participant *distributed.Participant, req *SignRequest) (*SignResponse, error)
+}
+
+// Client obtains composite signatures for distributed accounts by requesting partial
+// signatures from the accounts' participants.
+type Client struct {
+	transport Transport
+}
+
+// ThresholdAccount is the interface required of accounts for which the client obtains signatures.
+type ThresholdAccount interface {
+	e2wtypes.AccountCompositePublicKeyProvider
+	e2wtypes.AccountSigningThresholdProvider
+	e2wtypes.AccountParticipantsProvider
+	distributed.AccountPartialSignatureVerifier
+	distributed.AccountPartialSignatureCombiner
+}
+
+// NewClient creates a new client that obtains partial signatures with the given transport.
+func NewClient(transport Transport) (*Client, error) {
+	if transport == nil {
+		return nil, errors.New("no transport supplied")
+	}
+
+	return &Client{
+		transport: transport,
+	}, nil
+}
+
+// partialResult is the result of a request for a partial signature.
+type partialResult struct {
+	participantID uint64
+	signature     e2types.Signature
+	err           error
+}
+
+// Sign obtains the composite signature of the account over the given object root and domain.
+// Partial signatures are requested from all participants in parallel, and the composite signature
+// is returned as soon as the signing threshold of valid partial signatures has been obtained.
+func (c *Client) Sign(ctx context.Context, account e2wtypes.Account, root []byte, domain []byte) (e2types.Signature, error) {
+	signingRoot, err := SigningRoot(root, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.sign(ctx, account, &SignRequest{Root: root, Domain: domain}, signingRoot)
+}
+
+// SignSigningRoot obtains the composite signature of the account over the given signing root.
+// This is used where the object root and domain are not available, for example when acting for
+// a consensus client that supplies only the signing root.
+func (c *Client) SignSigningRoot(ctx context.Context, account e2wtypes.Account, signingRoot []byte) (e2types.Signature, error) {
+	if len(signingRoot) != 32 {
+		return nil, errors.New("signing root must be 32 bytes")
+	}
+
+	return c.sign(ctx, account, &SignRequest{SigningRoot: signingRoot}, signingRoot)
+}
+
+// sign obtains the composite signature of the account for the request.
+func (c *Client) sign(ctx context.Context, account e2wtypes.Account, req *SignRequest, signingRoot []byte) (e2types.Signature, error) {
+	thresholdAccount, isThresholdAccount := account.(ThresholdAccount)
+	if !isThresholdAccount {
+		return nil, errors.New("account is not a distributed account")
+	}
+	compositePubKey := thresholdAccount.CompositePublicKey()
+	if compositePubKey == nil {
+		return nil, errors.New("account has no composite public key")
+	}
+
+	participants := participantDescriptors(account)
+	threshold := int(thresholdAccount.SigningThreshold())
+	if len(participants) < threshold {
+		return nil, fmt.Errorf("account has %d participants but requires %d signatures", len(participants), threshold)
+	}
+
+	// Outstanding requests are canceled once sufficient partial signatures have been obtained.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	req.CompositePublicKey = compositePubKey.Marshal()
+	resultCh := make(chan *partialResult, len(participants))
+	for participantID, participant := range participants {
+		go func(participantID uint64, participant *distributed.Participant) {
+			signature, err := c.partialSignature(ctx, thresholdAccount, participantID, participant, req, signingRoot)
+			resultCh <- &partialResult{
+				participantID: participantID,
+				signature:     signature,
+				err:           err,
+			}
+		}(participantID, participant)
+	}
+
+	signatures := make(map[uint64]e2types.Signature, threshold)
+	failures := make(map[uint64]error)
+	for range participants {
+		var result *partialResult
+		select {
+		case result = <-resultCh:
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx.Err(), "failed to obtain partial signatures")
+		}
+		if result.err != nil {
+			failures[result.participantID] = result.err
+
+			continue
+		}
+		signatures[result.participantID] = result.signature
+		if len(signatures) == threshold {
+			return thresholdAccount.CombinePartialSignatures(signingRoot, signatures)
+		}
+	}
+
+	return nil, fmt.Errorf("obtained %d of %d required partial signatures: %s", len(signatures), threshold, describeFailures(failures))
+}
+
+// partialSignature obtains and verifies a partial signature from a participant.
+func (c *Client) partialSignature(ctx context.Context,
+	account ThresholdAccount,
+	participantID uint64,
+	participant *distributed.Participant,
+	req *SignRequest,
+	signingRoot []byte,
+) (
+	e2types.Signature,
+	error,
+) {
+	resp, err := c.transport.Sign(ctx, participant, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ParticipantID != participantID {
+		return nil, fmt.Errorf("response from participant %d", resp.ParticipantID)
+	}
+	signature, err := e2types.BLSSignatureFromBytes(resp.Signature)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid partial signature")
+	}
+	if err := account.VerifyPartialSignature(participantID, signingRoot, signature); err != nil {
+		return nil, err
+	}
+
+	return signature, nil
+}
+
+// participantDescriptors returns the descriptors of the account's participants.
+func participantDescriptors(account e2wtypes.Account) map[uint64]*distributed.Participant {
+	if provider, isProvider := account.(distributed.AccountParticipantDescriptorsProvider); isProvider {
+		return provider.ParticipantDescriptors()
+	}
+
+	res := make(map[uint64]*distributed.Participant)
+	if provider, isProvider := account.(e2wtypes.AccountParticipantsProvider); isProvider {
+		for participantID, endpoint := range provider.Participants() {
+			res[participantID] = &distributed.Participant{Endpoint: endpoint}
+		}
+	}
+
+	return res
+}
+
+// describeFailures returns a description of failed requests, ordered by participant ID.
+func describeFailures(failures map[uint64]error) string {
+	participantIDs := make([]uint64, 0, len(failures))
+	for participantID := range failures {
+		participantIDs = append(participantIDs, participantID)
+	}
+	sort.Slice(participantIDs, func(i, j int) bool { return participantIDs[i] < participantIDs[j] })
+
+	descriptions := make([]string, len(participantIDs))
+	for i, participantID := range participantIDs {
+		descriptions[i] = fmt.Sprintf("participant %d: %v", participantID, failures[participantID])
+	}
+
+	return strings.Join(descriptions, "; ")
+}
@@ -0,0 +1,265 @@
+// Copyright © 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	"github.com/wealdtech/go-eth2-wallet-distributed/remote"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+// fakeTransport provides partial signatures from in-process participants.
+type fakeTransport struct {
+	shares map[string][]byte
+	// responses overrides the response of participants at the given endpoint.
+	responses map[string]func() (*remote.SignResponse, error)
+}
+
+// Sign implements remote.Transport.
+func (f *fakeTransport) Sign(_ context.Context, participant *distributed.Participant, req *remote.SignRequest) (*remote.SignResponse, error) {
+	if response, exists := f.responses[participant.Endpoint]; exists {
+		return response()
+	}
+	share, exists := f.shares[participant.Endpoint]
+	if !exists {
+		return nil, errors.New("unknown endpoint")
+	}
+	signingRoot := req.SigningRoot
+	if signingRoot == nil {
+		var err error
+		signingRoot, err = remote.SigningRoot(req.Root, req.Domain)
+		if err != nil {
+			return nil, err
+		}
+	}
+	key, err := e2types.BLSPrivateKeyFromBytes(share)
+	if err != nil {
+		return nil, err
+	}
+	var participantID uint64
+	if _, err := fmt.Sscanf(participant.Endpoint, "participant%d", &participantID); err != nil {
+		return nil, err
+	}
+
+	return &remote.SignResponse{
+		ParticipantID: participantID,
+		Signature:     key.Sign(signingRoot).Marshal(),
+	}, nil
+}
+
+func TestNewClient(t *testing.T) {
+	_, err := remote.NewClient(nil)
+	require.EqualError(t, err, "no transport supplied")
+	_, err = remote.NewClient(remote.NewHTTPTransport())
+	require.NoError(t, err)
+}
+
+func TestClientSign(t *testing.T) {
+	ctx := context.Background()
+	vvec, shares := generateShares(t, 2, 1, 2, 3)
+	participants := map[uint64]string{1: "participant1", 2: "participant2", 3: "participant3"}
+	_, account := participantWallet(t, vvec, shares[1], participants)
+
+	root := bytes.Repeat([]byte{0x01}, 32)
+	domain := bytes.Repeat([]byte{0x02}, 32)
+	signingRoot, err := remote.SigningRoot(root, domain)
+	require.NoError(t, err)
+	compositePubKey, err := e2types.BLSPublicKeyFromBytes(vvec[0])
+	require.NoError(t, err)
+
+	badSignature := func() (*remote.SignResponse, error) {
+		key, err := e2types.GenerateBLSPrivateKey()
+		if err != nil {
+			return nil, err
+		}
+
+		return &remote.SignResponse{ParticipantID: 3, Signature: key.Sign(signingRoot).Marshal()}, nil
+	}
+	unavailable := func() (*remote.SignResponse, error) {
+		return nil, errors.New("unavailable")
+	}
+
+	tests := []struct {
+		name      string
+		account   e2wtypes.Account
+		root      []byte
+		responses map[string]func() (*remote.SignResponse, error)
+		err       string
+	}{
+		{
+			name:    "RootInvalid",
+			account: account,
+			root:    root[1:],
+			err:     "root must be 32 bytes",
+		},
+		{
+			name:    "Good",
+			account: account,
+			root:    root,
+		},
+		{
+			name:    "OneUnavailable",
+			account: account,
+			root:    root,
+			responses: map[string]func() (*remote.SignResponse, error){
+				"participant2": unavailable,
+			},
+		},
+		{
+			name:    "OneInvalid",
+			account: account,
+			root:    root,
+			responses: map[string]func() (*remote.SignResponse, error){
+				"participant3": badSignature,
+			},
+		},
+		{
+			name:    "InsufficientPartials",
+			account: account,
+			root:    root,
+			responses: map[string]func() (*remote.SignResponse, error){
+				"participant2": unavailable,
+				"participant3": badSignature,
+			},
+			err: "obtained 1 of 2 required partial signatures: participant 2: unavailable; participant 3: partial signature from participant 3 is invalid",
+		},
+		{
+			name:    "WrongParticipant",
+			account: account,
+			root:    root,
+			responses: map[string]func() (*remote.SignResponse, error){
+				"participant1": unavailable,
+				"participant2": func() (*remote.SignResponse, error) {
+					return &remote.SignResponse{ParticipantID: 3}, nil
+				},
+			},
+			err: "obtained 1 of 2 required partial signatures: participant 1: unavailable; participant 2: response from participant 3",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := remote.NewClient(&fakeTransport{
+				shares: map[string][]byte{
+					"participant1": shares[1],
+					"participant2": shares[2],
+					"participant3": shares[3],
+				},
+				responses: test.responses,
+			})
+			require.NoError(t, err)
+			signature, err := client.Sign(ctx, test.account, test.root, domain)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+			} else {
+				require.NoError(t, err)
+				require.True(t, signature.Verify(signingRoot, compositePubKey))
+			}
+		})
+	}
+}
+
+func TestClientHTTP(t *testing.T) {
+	ctx := context.Background()
+	vvec, shares := generateShares(t, 2, 1, 2, 3)
+
+	// Start servers for the first two participants; the third is not running.
+	participants := map[uint64]string{3: "127.0.0.1:1"}
+	var account e2wtypes.Account
+	for _, participantID := range []uint64{1, 2} {
+		wallet, participantAccount := participantWallet(t, vvec, shares[participantID], map[uint64]string{1: "a", 2: "b", 3: "c"})
+		server, err := remote.NewServer(wallet, remote.WithBearerTokens("secret"), remote.WithPassphrases([]byte("pass")))
+		require.NoError(t, err)
+		participants[participantID] = fmt.Sprintf("http://%s", serve(t, server))
+		if account == nil {
+			account = participantAccount
+		}
+	}
+	require.NoError(t, account.(distributed.AccountParticipantsSetter).SetParticipants(ctx, map[uint64]*distributed.Participant{
+		1: {Endpoint: participants[1]},
+		2: {Endpoint: participants[2]},
+		3: {Endpoint: participants[3]},
+	}))
+
+	root := bytes.Repeat([]byte{0x01}, 32)
+	domain := bytes.Repeat([]byte{0x02}, 32)
+	signingRoot, err := remote.SigningRoot(root, domain)
+	require.NoError(t, err)
+	compositePubKey, err := e2types.BLSPublicKeyFromBytes(vvec[0])
+	require.NoError(t, err)
+
+	client, err := remote.NewClient(remote.NewHTTPTransport(remote.WithBearerToken("secret")))
+	require.NoError(t, err)
+	signature, err := client.Sign(ctx, account, root, domain)
+	require.NoError(t, err)
+	require.True(t, signature.Verify(signingRoot, compositePubKey))
+
+	client, err = remote.NewClient(remote.NewHTTPTransport(remote.WithBearerToken("wrong")))
+	require.NoError(t, err)
+	_, err = client.Sign(ctx, account, root, domain)
+	require.ErrorContains(t, err, "request failed with status 401: unauthorized")
+}
+
+func TestClientCertificateFingerprint(t *testing.T) {
+	ctx := context.Background()
+	vvec, shares := generateShares(t, 1, 1)
+	wallet, account := participantWallet(t, vvec, shares[1], map[uint64]string{1: "a"})
+
+	serverCert := selfSignedCertificate(t)
+	server, err := remote.NewServer(wallet,
+		remote.WithTLSConfig(&tls.Config{
+			Certificates: []tls.Certificate{serverCert},
+			MinVersion:   tls.VersionTLS13,
+		}),
+		remote.WithBearerTokens("secret"),
+		remote.WithPassphrases([]byte("pass")),
+	)
+	require.NoError(t, err)
+	address := serve(t, server)
+	client, err := remote.NewClient(remote.NewHTTPTransport(remote.WithBearerToken("secret")))
+	require.NoError(t, err)
+
+	root := bytes.Repeat([]byte{0x01}, 32)
+	domain := bytes.Repeat([]byte{0x02}, 32)
+	setter := account.(distributed.AccountParticipantsSetter)
+
+	// The self-signed certificate cannot be verified without its fingerprint.
+	require.NoError(t, setter.SetParticipants(ctx, map[uint64]*distributed.Participant{1: {Endpoint: address}}))
+	_, err = client.Sign(ctx, account, root, domain)
+	require.ErrorContains(t, err, "certificate")
+
+	otherFingerprint := sha256.Sum256(selfSignedCertificate(t).Certificate[0])
+	require.NoError(t, setter.SetParticipants(ctx, map[uint64]*distributed.Participant{
+		1: {Endpoint: address, CertificateFingerprint: otherFingerprint[:]},
+	}))
+	_, err = client.Sign(ctx, account, root, domain)
+	require.ErrorContains(t, err, "server certificate fingerprint mismatch")
+
+	fingerprint := sha256.Sum256(serverCert.Certificate[0])
+	require.NoError(t, setter.SetParticipants(ctx, map[uint64]*distributed.Participant{
+		1: {Endpoint: address, CertificateFingerprint: fingerprint[:]},
+	}))
+	_, err = client.Sign(ctx, account, root, domain)
+	require.NoError(t, err)
+}
@@ -0,0 +1,176 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"crypto/tls"
+	"time"
+)
+
+// serverOptions are the options for a server.
+type serverOptions struct {
+	bearerTokens                  []string
+	clientCertificateFingerprints [][]byte
+	tlsConfig                     *tls.Config
+	passphrases                   [][]byte
+}
+
+// ServerOption gives options to NewServer.
+type ServerOption interface {
+	apply(*serverOptions)
+}
+
+type serverOptionFunc func(*serverOptions)
+
+func (f serverOptionFunc) apply(o *serverOptions) {
+	f(o)
+}
+
+// WithBearerTokens authenticates requests that supply any of the given tokens in an
+// "Authorization: Bearer" header.
+func WithBearerTokens(tokens ...string) ServerOption {
+	return serverOptionFunc(func(o *serverOptions) {
+		o.bearerTokens = append(o.bearerTokens, tokens...)
+	})
+}
+
+// WithClientCertificateFingerprints authenticates requests made over TLS with a client
+// certificate whose SHA-256 fingerprint is any of those given.  This requires a TLS configuration.
+func WithClientCertificateFingerprints(fingerprints ...[]byte) ServerOption {
+	return serverOptionFunc(func(o *serverOptions) {
+		o.clientCertificateFingerprints = append(o.clientCertificateFingerprints, fingerprints...)
+	})
+}
+
+// WithTLSConfig serves requests over TLS with the given configuration.
+func WithTLSConfig(config *tls.Config) ServerOption {
+	return serverOptionFunc(func(o *serverOptions) {
+		o.tlsConfig = config
+	})
+}
+
+// WithPassphrases supplies passphrases with which the server unlocks accounts.
+// If not supplied, accounts must be unlocked when the server obtains them from the wallet.
+func WithPassphrases(passphrases ...[]byte) ServerOption {
+	return serverOptionFunc(func(o *serverOptions) {
+		o.passphrases = append(o.passphrases, passphrases...)
+	})
+}
+
+// parseServerOptions parses the supplied options.
+func parseServerOptions(opts []ServerOption) *serverOptions {
+	options := &serverOptions{}
+	for _, o := range opts {
+		o.apply(options)
+	}
+
+	return options
+}
+
+// transportOptions are the options for an HTTP transport.
+type transportOptions struct {
+	bearerToken string
+	tlsConfig   *tls.Config
+	timeout     time.Duration
+}
+
+// TransportOption gives options to NewHTTPTransport.
+type TransportOption interface {
+	apply(*transportOptions)
+}
+
+type transportOptionFunc func(*transportOptions)
+
+func (f transportOptionFunc) apply(o *transportOptions) {
+	f(o)
+}
+
+// WithBearerToken supplies the token sent in an "Authorization: Bearer" header with each request.
+func WithBearerToken(token string) TransportOption {
+	return transportOptionFunc(func(o *transportOptions) {
+		o.bearerToken = token
+	})
+}
+
+// WithClientTLSConfig makes requests with the given TLS configuration, for example to supply
+// a client certificate or the certificate authorities of participants.
+func WithClientTLSConfig(config *tls.Config) TransportOption {
+	return transportOptionFunc(func(o *transportOptions) {
+		o.tlsConfig = config
+	})
+}
+
+// WithTimeout sets the timeout for each request.
+// The default is 10 seconds.
+func WithTimeout(timeout time.Duration) TransportOption {
+	return transportOptionFunc(func(o *transportOptions) {
+		o.timeout = timeout
+	})
+}
+
+// parseTransportOptions parses the supplied options.
+func parseTransportOptions(opts []TransportOption) *transportOptions {
+	options := &transportOptions{
+		timeout: 10 * time.Second,
+	}
+	for _, o := range opts {
+		o.apply(options)
+	}
+
+	return options
+}
+
+// web3SignerOptions are the options for a Web3Signer handler.
+type web3SignerOptions struct {
+	client      *Client
+	passphrases [][]byte
+}
+
+// Web3SignerOption gives options to NewWeb3SignerHandler.
+type Web3SignerOption interface {
+	apply(*web3SignerOptions)
+}
+
+type web3SignerOptionFunc func(*web3SignerOptions)
+
+func (f web3SignerOptionFunc) apply(o *web3SignerOptions) {
+	f(o)
+}
+
+// WithThresholdClient provides composite signatures obtained by the client, in place of
+// the partial signatures of the wallet's accounts.
+func WithThresholdClient(client *Client) Web3SignerOption {
+	return web3SignerOptionFunc(func(o *web3SignerOptions) {
+		o.client = client
+	})
+}
+
+// WithWeb3SignerPassphrases supplies passphrases with which the handler unlocks accounts to
+// provide partial signatures.
+// If not supplied, accounts must be unlocked when the handler obtains them from the wallet.
+func WithWeb3SignerPassphrases(passphrases ...[]byte) Web3SignerOption {
+	return web3SignerOptionFunc(func(o *web3SignerOptions) {
+		o.passphrases = append(o.passphrases, passphrases...)
+	})
+}
+
+// parseWeb3SignerOptions parses the supplied options.
+func parseWeb3SignerOptions(opts []Web3SignerOption) *web3SignerOptions {
+	options := &web3SignerOptions{}
+	for _, o := range opts {
+		o.apply(options)
+	}
+
+	return options
+}
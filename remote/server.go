@@ -0,0 +1,238 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package remote provides remote partial signing for distributed accounts.
+package remote
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+// SignPath is the path of the partial signing endpoint.
+const SignPath = "/v1/sign"
+
+// maxRequestSize is the maximum size of a request body.
+const maxRequestSize = 64 * 1024
+
+// shutdownTimeout is the time allowed for in-flight requests to complete when the server stops.
+const shutdownTimeout = 5 * time.Second
+
+// Server provides partial signatures from the accounts of a distributed wallet.
+type Server struct {
+	accounts                      *accountCache
+	bearerTokens                  [][]byte
+	clientCertificateFingerprints [][]byte
+	tlsConfig                     *tls.Config
+	mux                           *http.ServeMux
+}
+
+// NewServer creates a new server for the accounts in the wallet.
+// At least one method of authentication must be supplied.
+// Accounts must be unlocked to provide signatures, either when obtained from the
+// wallet or with passphrases supplied by WithPassphrases().
+func NewServer(wallet e2wtypes.Wallet, opts ...ServerOption) (*Server, error) {
+	options := parseServerOptions(opts)
+
+	accounts, err := newAccountCache(wallet, options.passphrases)
+	if err != nil {
+		return nil, err
+	}
+	if len(options.bearerTokens) == 0 && len(options.clientCertificateFingerprints) == 0 {
+		return nil, errors.New("no authentication supplied")
+	}
+	if len(options.clientCertificateFingerprints) > 0 && options.tlsConfig == nil {
+		return nil, errors.New("client certificate authentication requires a TLS configuration")
+	}
+
+	s := &Server{
+		accounts:                      accounts,
+		clientCertificateFingerprints: options.clientCertificateFingerprints,
+		mux:                           http.NewServeMux(),
+	}
+	for _, token := range options.bearerTokens {
+		if token == "" {
+			return nil, errors.New("bearer token empty")
+		}
+		s.bearerTokens = append(s.bearerTokens, []byte(token))
+	}
+	if options.tlsConfig != nil {
+		s.tlsConfig = options.tlsConfig.Clone()
+		if len(s.clientCertificateFingerprints) > 0 && s.tlsConfig.ClientAuth == tls.NoClientCert {
+			// Client certificates are authenticated by their fingerprint rather than a certificate authority.
+			s.tlsConfig.ClientAuth = tls.RequestClientCert
+		}
+	}
+	s.mux.HandleFunc(SignPath, s.handleSign)
+
+	return s, nil
+}
+
+// Serve serves requests on the listener until the context is canceled.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	if s.tlsConfig != nil {
+		listener = tls.NewListener(listener, s.tlsConfig)
+	}
+	srv := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(listener)
+	}()
+
+	select {
+	case err := <-errCh:
+		return errors.Wrap(err, "server failed")
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			return errors.Wrap(err, "failed to shut down server")
+		}
+
+		return nil
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authenticated(r) {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// authenticated returns true if the request is authenticated.
+func (s *Server) authenticated(r *http.Request) bool {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token := []byte(strings.TrimPrefix(auth, "Bearer "))
+		for _, bearerToken := range s.bearerTokens {
+			if subtle.ConstantTimeCompare(token, bearerToken) == 1 {
+				return true
+			}
+		}
+	}
+
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		fingerprint := sha256.Sum256(r.TLS.PeerCertificates[0].Raw)
+		for _, clientCertificateFingerprint := range s.clientCertificateFingerprints {
+			if subtle.ConstantTimeCompare(fingerprint[:], clientCertificateFingerprint) == 1 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// handleSign handles a request for a partial signature.
+func (s *Server) handleSign(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+
+		return
+	}
+
+	req := &SignRequest{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+
+		return
+	}
+	signingRoot, err := req.signingRoot()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+
+		return
+	}
+
+	account, err := s.accounts.account(r.Context(), req.CompositePublicKey, true)
+	if err != nil {
+		switch {
+		case errors.Is(err, distributed.ErrNotFound):
+			writeError(w, http.StatusNotFound, "account not found")
+		case errors.Is(err, distributed.ErrLocked):
+			writeError(w, http.StatusServiceUnavailable, "account is locked")
+		default:
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to obtain account: %v", err))
+		}
+
+		return
+	}
+	signer, isSigner := account.(e2wtypes.AccountSigner)
+	if !isSigner {
+		writeError(w, http.StatusInternalServerError, "account cannot sign")
+
+		return
+	}
+	idProvider, isIDProvider := account.(distributed.AccountParticipantIDProvider)
+	if !isIDProvider {
+		writeError(w, http.StatusInternalServerError, "account cannot provide participant ID")
+
+		return
+	}
+	participantID, err := idProvider.ParticipantID()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to obtain participant ID: %v", err))
+
+		return
+	}
+
+	signature, err := signer.Sign(r.Context(), signingRoot)
+	if err != nil {
+		if errors.Is(err, distributed.ErrLocked) {
+			writeError(w, http.StatusServiceUnavailable, "account is locked")
+		} else {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to sign: %v", err))
+		}
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, &SignResponse{
+		ParticipantID: participantID,
+		Signature:     signature.Marshal(),
+	})
+}
+
+// writeError writes an error response.
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, &errorResponseJSON{
+		Code:    code,
+		Message: message,
+	})
+}
+
+// writeJSON writes a JSON response.
+func writeJSON(w http.ResponseWriter, code int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(data)
+}
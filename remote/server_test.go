@@ -0,0 +1,367 @@
+// Copyright © 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	bls "github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	"github.com/wealdtech/go-eth2-wallet-distributed/remote"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	scratch "github.com/wealdtech/go-eth2-wallet-store-scratch"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+func TestMain(m *testing.M) {
+	if err := e2types.InitBLS(); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// generateShares generates a distributed key with the given threshold, returning its
+// verification vector and the secret key share for each participant.
+func generateShares(t *testing.T, signingThreshold int, participantIDs ...uint64) ([][]byte, map[uint64][]byte) {
+	t.Helper()
+
+	msk := make([]bls.SecretKey, signingThreshold)
+	for i := range msk {
+		msk[i].SetByCSPRNG()
+	}
+	mpk := bls.GetMasterPublicKey(msk)
+	verificationVector := make([][]byte, len(mpk))
+	for i := range mpk {
+		verificationVector[i] = mpk[i].Serialize()
+	}
+
+	shares := make(map[uint64][]byte, len(participantIDs))
+	for _, participantID := range participantIDs {
+		var id bls.ID
+		require.NoError(t, id.SetDecString(fmt.Sprintf("%d", participantID)))
+		var share bls.SecretKey
+		require.NoError(t, share.Set(msk, &id))
+		shares[participantID] = share.Serialize()
+	}
+
+	return verificationVector, shares
+}
+
+// participantWallet creates a wallet holding the share of the given participant in a distributed account.
+func participantWallet(t *testing.T,
+	verificationVector [][]byte,
+	share []byte,
+	participants map[uint64]string,
+) (
+	e2wtypes.Wallet,
+	e2wtypes.Account,
+) {
+	t.Helper()
+	ctx := context.Background()
+
+	wallet, err := distributed.CreateWallet(ctx, "test wallet", scratch.New(), keystorev4.New())
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+	account, err := wallet.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+		"Account 1", share, uint32(len(verificationVector)), verificationVector, participants, []byte("pass"))
+	require.NoError(t, err)
+
+	return wallet, account
+}
+
+// serve serves requests with the server on a loopback listener until the test completes,
+// returning the address of the listener.
+func serve(t *testing.T, server *remote.Server) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Serve(ctx, listener)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-errCh)
+	})
+
+	return listener.Addr().String()
+}
+
+// selfSignedCertificate creates a self-signed certificate for the loopback address.
+func selfSignedCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestNewServer(t *testing.T) {
+	vvec, shares := generateShares(t, 2, 1, 2, 3)
+	wallet, _ := participantWallet(t, vvec, shares[1], map[uint64]string{1: "a", 2: "b", 3: "c"})
+
+	_, err := remote.NewServer(wallet)
+	require.EqualError(t, err, "no authentication supplied")
+	_, err = remote.NewServer(wallet, remote.WithBearerTokens(""))
+	require.EqualError(t, err, "bearer token empty")
+	_, err = remote.NewServer(wallet, remote.WithClientCertificateFingerprints([]byte{0x01}))
+	require.EqualError(t, err, "client certificate authentication requires a TLS configuration")
+	_, err = remote.NewServer(wallet, remote.WithBearerTokens("secret"))
+	require.NoError(t, err)
+}
+
+func TestServerSign(t *testing.T) {
+	ctx := context.Background()
+	vvec, shares := generateShares(t, 2, 1, 2, 3)
+	wallet, account := participantWallet(t, vvec, shares[2], map[uint64]string{1: "a", 2: "b", 3: "c"})
+	server, err := remote.NewServer(wallet, remote.WithBearerTokens("secret"), remote.WithPassphrases([]byte("wrong"), []byte("pass")))
+	require.NoError(t, err)
+	address := serve(t, server)
+
+	root := bytes.Repeat([]byte{0x01}, 32)
+	domain := bytes.Repeat([]byte{0x02}, 32)
+	request := func(t *testing.T, compositePublicKey []byte, root []byte) []byte {
+		t.Helper()
+		body, err := json.Marshal(&remote.SignRequest{
+			CompositePublicKey: compositePublicKey,
+			Root:               root,
+			Domain:             domain,
+		})
+		require.NoError(t, err)
+
+		return body
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		body   []byte
+		status int
+		msg    string
+	}{
+		{
+			name:   "NoToken",
+			method: http.MethodPost,
+			body:   request(t, vvec[0], root),
+			status: http.StatusUnauthorized,
+			msg:    "unauthorized",
+		},
+		{
+			name:   "WrongToken",
+			method: http.MethodPost,
+			token:  "wrong",
+			body:   request(t, vvec[0], root),
+			status: http.StatusUnauthorized,
+			msg:    "unauthorized",
+		},
+		{
+			name:   "WrongMethod",
+			method: http.MethodGet,
+			token:  "secret",
+			status: http.StatusMethodNotAllowed,
+			msg:    "method not allowed",
+		},
+		{
+			name:   "InvalidJSON",
+			method: http.MethodPost,
+			token:  "secret",
+			body:   []byte(`{`),
+			status: http.StatusBadRequest,
+			msg:    "invalid request: unexpected EOF",
+		},
+		{
+			name:   "RootInvalid",
+			method: http.MethodPost,
+			token:  "secret",
+			body:   request(t, vvec[0], root[1:]),
+			status: http.StatusBadRequest,
+			msg:    "invalid request: root must be 32 bytes",
+		},
+		{
+			name:   "SigningRootWithRoot",
+			method: http.MethodPost,
+			token:  "secret",
+			body:   []byte(fmt.Sprintf(`{"composite_pubkey":"%#x","root":"%#x","signing_root":"%#x"}`, vvec[0], root, root)),
+			status: http.StatusBadRequest,
+			msg:    "invalid request: signing root cannot be supplied with root or domain",
+		},
+		{
+			name:   "SigningRootInvalid",
+			method: http.MethodPost,
+			token:  "secret",
+			body:   []byte(fmt.Sprintf(`{"composite_pubkey":"%#x","signing_root":"%#x"}`, vvec[0], root[1:])),
+			status: http.StatusBadRequest,
+			msg:    "invalid request: signing root must be 32 bytes",
+		},
+		{
+			name:   "AccountUnknown",
+			method: http.MethodPost,
+			token:  "secret",
+			body:   request(t, vvec[1], root),
+			status: http.StatusNotFound,
+			msg:    "account not found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(ctx, test.method, fmt.Sprintf("http://%s%s", address, remote.SignPath), bytes.NewReader(test.body))
+			require.NoError(t, err)
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, test.status, resp.StatusCode)
+			res := make(map[string]any)
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+			require.Equal(t, test.msg, res["message"])
+		})
+	}
+
+	// sign obtains a signature from the server at the address.
+	sign := func(t *testing.T, address string) (*http.Response, []byte) {
+		t.Helper()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("http://%s%s", address, remote.SignPath), bytes.NewReader(request(t, vvec[0], root)))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		return resp, body
+	}
+
+	t.Run("Good", func(t *testing.T) {
+		resp, body := sign(t, address)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		res := &remote.SignResponse{}
+		require.NoError(t, json.Unmarshal(body, res))
+		require.Equal(t, uint64(2), res.ParticipantID)
+
+		signingRoot, err := remote.SigningRoot(root, domain)
+		require.NoError(t, err)
+		signature, err := e2types.BLSSignatureFromBytes(res.Signature)
+		require.NoError(t, err)
+		require.True(t, signature.Verify(signingRoot, account.PublicKey()))
+	})
+
+	t.Run("Locked", func(t *testing.T) {
+		server, err := remote.NewServer(wallet, remote.WithBearerTokens("secret"))
+		require.NoError(t, err)
+		resp, body := sign(t, serve(t, server))
+		require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		require.Contains(t, string(body), "account is locked")
+	})
+}
+
+func TestServerClientCertificate(t *testing.T) {
+	ctx := context.Background()
+	vvec, shares := generateShares(t, 2, 1, 2, 3)
+	wallet, _ := participantWallet(t, vvec, shares[3], map[uint64]string{1: "a", 2: "b", 3: "c"})
+
+	serverCert := selfSignedCertificate(t)
+	clientCert := selfSignedCertificate(t)
+	otherCert := selfSignedCertificate(t)
+	fingerprint := sha256.Sum256(clientCert.Certificate[0])
+	server, err := remote.NewServer(wallet,
+		remote.WithTLSConfig(&tls.Config{
+			Certificates: []tls.Certificate{serverCert},
+			MinVersion:   tls.VersionTLS13,
+		}),
+		remote.WithClientCertificateFingerprints(fingerprint[:]),
+		remote.WithPassphrases([]byte("pass")),
+	)
+	require.NoError(t, err)
+	address := serve(t, server)
+
+	serverCertificate, err := x509.ParseCertificate(serverCert.Certificate[0])
+	require.NoError(t, err)
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(serverCertificate)
+
+	body, err := json.Marshal(&remote.SignRequest{
+		CompositePublicKey: vvec[0],
+		Root:               bytes.Repeat([]byte{0x01}, 32),
+		Domain:             bytes.Repeat([]byte{0x02}, 32),
+	})
+	require.NoError(t, err)
+
+	// sign obtains a signature from the server using the given client certificates.
+	sign := func(t *testing.T, certificates ...tls.Certificate) int {
+		t.Helper()
+		client := &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					Certificates: certificates,
+					RootCAs:      rootCAs,
+					MinVersion:   tls.VersionTLS13,
+				},
+			},
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("https://%s%s", address, remote.SignPath), bytes.NewReader(body))
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			res := &remote.SignResponse{}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(res))
+			require.Equal(t, uint64(3), res.ParticipantID)
+		}
+
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusUnauthorized, sign(t))
+	require.Equal(t, http.StatusUnauthorized, sign(t, otherCert))
+	require.Equal(t, http.StatusOK, sign(t, clientCert))
+}
@@ -0,0 +1,153 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+)
+
+// maxResponseSize is the maximum size of a response body.
+const maxResponseSize = 64 * 1024
+
+// HTTPTransport obtains partial signatures from participants running a Server.
+type HTTPTransport struct {
+	bearerToken string
+	tlsConfig   *tls.Config
+	timeout     time.Duration
+	// clients holds HTTP clients by the certificate fingerprint they require.
+	clients      map[string]*http.Client
+	clientsMutex sync.Mutex
+}
+
+// NewHTTPTransport creates a new HTTP transport.
+// Participant endpoints without a scheme are contacted over HTTPS.  If a participant has a
+// certificate fingerprint then the participant's certificate must match it, in place of
+// verification against a certificate authority.
+func NewHTTPTransport(opts ...TransportOption) *HTTPTransport {
+	options := parseTransportOptions(opts)
+
+	return &HTTPTransport{
+		bearerToken: options.bearerToken,
+		tlsConfig:   options.tlsConfig,
+		timeout:     options.timeout,
+		clients:     make(map[string]*http.Client),
+	}
+}
+
+// Sign requests a partial signature from the participant.
+func (t *HTTPTransport) Sign(ctx context.Context, participant *distributed.Participant, req *SignRequest) (*SignResponse, error) {
+	if participant.Endpoint == "" {
+		return nil, errors.New("participant has no endpoint")
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal request")
+	}
+
+	url := participant.Endpoint
+	if !strings.Contains(url, "://") {
+		url = "https://" + url
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(url, "/")+SignPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if t.bearerToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+t.bearerToken)
+	}
+
+	httpResp, err := t.client(participant.CertificateFingerprint).Do(httpReq)
+	if err != nil {
+		return nil, errors.Wrap(err, "request failed")
+	}
+	defer httpResp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(httpResp.Body, maxResponseSize))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read response")
+	}
+
+	if httpResp.StatusCode != http.StatusOK {
+		errResp := &errorResponseJSON{}
+		if err := json.Unmarshal(respBody, errResp); err == nil && errResp.Message != "" {
+			return nil, fmt.Errorf("request failed with status %d: %s", httpResp.StatusCode, errResp.Message)
+		}
+
+		return nil, fmt.Errorf("request failed with status %d", httpResp.StatusCode)
+	}
+	resp := &SignResponse{}
+	if err := json.Unmarshal(respBody, resp); err != nil {
+		return nil, errors.Wrap(err, "invalid response")
+	}
+
+	return resp, nil
+}
+
+// client returns the HTTP client for a participant with the given certificate fingerprint.
+func (t *HTTPTransport) client(fingerprint []byte) *http.Client {
+	t.clientsMutex.Lock()
+	defer t.clientsMutex.Unlock()
+
+	if client, exists := t.clients[string(fingerprint)]; exists {
+		return client
+	}
+
+	var tlsConfig *tls.Config
+	if t.tlsConfig != nil {
+		tlsConfig = t.tlsConfig.Clone()
+	}
+	if len(fingerprint) > 0 {
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		// The certificate is authenticated by its fingerprint rather than a certificate authority.
+		tlsConfig.InsecureSkipVerify = true
+		expected := append([]byte(nil), fingerprint...)
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return errors.New("no server certificate")
+			}
+			actual := sha256.Sum256(state.PeerCertificates[0].Raw)
+			if subtle.ConstantTimeCompare(actual[:], expected) != 1 {
+				return errors.New("server certificate fingerprint mismatch")
+			}
+
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   t.timeout,
+	}
+	t.clients[string(fingerprint)] = client
+
+	return client
+}
@@ -0,0 +1,172 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SignRequest is a request for a participant's partial signature.
+type SignRequest struct {
+	// CompositePublicKey is the composite public key of the distributed account to sign with.
+	CompositePublicKey []byte
+	// Root is the 32-byte root of the object to sign.
+	Root []byte
+	// Domain is the 32-byte signature domain.
+	Domain []byte
+	// SigningRoot is the 32-byte signing root, supplied in place of the root and domain.
+	SigningRoot []byte
+}
+
+// signRequestJSON is the JSON representation of a sign request.
+type signRequestJSON struct {
+	CompositePubkey string `json:"composite_pubkey"`
+	Root            string `json:"root,omitempty"`
+	Domain          string `json:"domain,omitempty"`
+	SigningRoot     string `json:"signing_root,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r *SignRequest) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&signRequestJSON{
+		CompositePubkey: fmt.Sprintf("%#x", r.CompositePublicKey),
+		Root:            fmt.Sprintf("%#x", r.Root),
+		Domain:          fmt.Sprintf("%#x", r.Domain),
+		SigningRoot:     fmt.Sprintf("%#x", r.SigningRoot),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *SignRequest) UnmarshalJSON(input []byte) error {
+	var data signRequestJSON
+	if err := json.Unmarshal(input, &data); err != nil {
+		return errors.Wrap(err, "invalid JSON")
+	}
+	var err error
+	if r.CompositePublicKey, err = decodeHex(data.CompositePubkey, "composite public key"); err != nil {
+		return err
+	}
+	if data.SigningRoot != "" {
+		if data.Root != "" || data.Domain != "" {
+			return errors.New("signing root cannot be supplied with root or domain")
+		}
+		if r.SigningRoot, err = decodeHex(data.SigningRoot, "signing root"); err != nil {
+			return err
+		}
+
+		return nil
+	}
+	if r.Root, err = decodeHex(data.Root, "root"); err != nil {
+		return err
+	}
+	if r.Domain, err = decodeHex(data.Domain, "domain"); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// signingRoot returns the signing root of the request.
+func (r *SignRequest) signingRoot() ([]byte, error) {
+	if r.SigningRoot != nil {
+		if len(r.SigningRoot) != 32 {
+			return nil, errors.New("signing root must be 32 bytes")
+		}
+
+		return r.SigningRoot, nil
+	}
+
+	return SigningRoot(r.Root, r.Domain)
+}
+
+// SignResponse is a participant's response to a sign request.
+type SignResponse struct {
+	// ParticipantID is the ID of the participant that provided the signature.
+	ParticipantID uint64
+	// Signature is the participant's partial signature.
+	Signature []byte
+}
+
+// signResponseJSON is the JSON representation of a sign response.
+type signResponseJSON struct {
+	ParticipantID string `json:"participant_id"`
+	Signature     string `json:"signature"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r *SignResponse) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&signResponseJSON{
+		ParticipantID: fmt.Sprintf("%d", r.ParticipantID),
+		Signature:     fmt.Sprintf("%#x", r.Signature),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *SignResponse) UnmarshalJSON(input []byte) error {
+	var data signResponseJSON
+	if err := json.Unmarshal(input, &data); err != nil {
+		return errors.Wrap(err, "invalid JSON")
+	}
+	participantID, err := strconv.ParseUint(data.ParticipantID, 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "invalid participant ID")
+	}
+	r.ParticipantID = participantID
+	if r.Signature, err = decodeHex(data.Signature, "signature"); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// errorResponseJSON is the JSON representation of an error response.
+type errorResponseJSON struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// SigningRoot returns the signing root for an object root and domain, being the
+// hash tree root of the Ethereum consensus SigningData container.
+func SigningRoot(root []byte, domain []byte) ([]byte, error) {
+	if len(root) != 32 {
+		return nil, errors.New("root must be 32 bytes")
+	}
+	if len(domain) != 32 {
+		return nil, errors.New("domain must be 32 bytes")
+	}
+	// The hash tree root of a container of two 32-byte fields is the hash of their concatenation.
+	signingRoot := sha256.Sum256(append(append(make([]byte, 0, 64), root...), domain...))
+
+	return signingRoot[:], nil
+}
+
+// decodeHex decodes a hex string, with or without 0x prefix.
+func decodeHex(input string, name string) ([]byte, error) {
+	if input == "" {
+		return nil, fmt.Errorf("%s missing", name)
+	}
+	res, err := hex.DecodeString(strings.TrimPrefix(input, "0x"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s", name)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,223 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+const (
+	// Web3SignerPublicKeysPath is the path of the Web3Signer endpoint that lists public keys.
+	Web3SignerPublicKeysPath = "/api/v1/eth2/publicKeys"
+	// Web3SignerSignPath is the path of the Web3Signer signing endpoint, to which the public key is appended.
+	Web3SignerSignPath = "/api/v1/eth2/sign/"
+	// Web3SignerUpcheckPath is the path of the Web3Signer liveness endpoint.
+	Web3SignerUpcheckPath = "/upcheck"
+)
+
+// web3SignerTypes are the types of signing request defined by Web3Signer.
+var web3SignerTypes = map[string]bool{
+	"AGGREGATE_AND_PROOF":                   true,
+	"AGGREGATE_AND_PROOF_V2":                true,
+	"AGGREGATION_SLOT":                      true,
+	"ATTESTATION":                           true,
+	"BLOCK":                                 true,
+	"BLOCK_V2":                              true,
+	"DEPOSIT":                               true,
+	"RANDAO_REVEAL":                         true,
+	"SYNC_COMMITTEE_CONTRIBUTION_AND_PROOF": true,
+	"SYNC_COMMITTEE_MESSAGE":                true,
+	"SYNC_COMMITTEE_SELECTION_PROOF":        true,
+	"VALIDATOR_REGISTRATION":                true,
+	"VOLUNTARY_EXIT":                        true,
+}
+
+// Web3SignerHandler implements the Web3Signer Ethereum consensus signing API for the
+// accounts of a distributed wallet, identifying accounts by their composite public key.
+//
+// By default the handler is in partial mode, and signs with the wallet's accounts to provide
+// partial signatures.  If a threshold client is supplied with WithThresholdClient() the handler
+// is in combined mode, and provides composite signatures obtained from the accounts' participants.
+//
+// Signing requests must contain the signing root; signing roots are not calculated from the
+// objects in the request.  The handler does not provide slashing protection, nor does it
+// authenticate requests.
+type Web3SignerHandler struct {
+	accounts *accountCache
+	client   *Client
+	mux      *http.ServeMux
+}
+
+// web3SignerSignRequestJSON is the JSON representation of the parts of a Web3Signer signing request
+// used by the handler.
+type web3SignerSignRequestJSON struct {
+	Type        string `json:"type"`
+	SigningRoot string `json:"signingRoot"`
+}
+
+// web3SignerSignResponseJSON is the JSON representation of a Web3Signer signing response.
+type web3SignerSignResponseJSON struct {
+	Signature string `json:"signature"`
+}
+
+// NewWeb3SignerHandler creates a new Web3Signer handler for the accounts in the wallet.
+func NewWeb3SignerHandler(wallet e2wtypes.Wallet, opts ...Web3SignerOption) (*Web3SignerHandler, error) {
+	options := parseWeb3SignerOptions(opts)
+
+	accounts, err := newAccountCache(wallet, options.passphrases)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Web3SignerHandler{
+		accounts: accounts,
+		client:   options.client,
+		mux:      http.NewServeMux(),
+	}
+	h.mux.HandleFunc(Web3SignerPublicKeysPath, h.handlePublicKeys)
+	h.mux.HandleFunc(Web3SignerSignPath, h.handleSign)
+	h.mux.HandleFunc(Web3SignerUpcheckPath, h.handleUpcheck)
+
+	return h, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Web3SignerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// handleUpcheck handles a liveness request.
+func (h *Web3SignerHandler) handleUpcheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write([]byte("OK"))
+}
+
+// handlePublicKeys handles a request for the public keys of the accounts.
+func (h *Web3SignerHandler) handlePublicKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	// Watch-only accounts can provide composite signatures, as these are obtained from their participants.
+	compositePubKeys := h.accounts.compositePublicKeys(r.Context(), h.client != nil)
+	res := make([]string, len(compositePubKeys))
+	for i := range compositePubKeys {
+		res[i] = fmt.Sprintf("%#x", compositePubKeys[i])
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleSign handles a signing request.
+func (h *Web3SignerHandler) handleSign(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	compositePubKey, err := decodeHex(strings.TrimPrefix(r.URL.Path, Web3SignerSignPath), "identifier")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+
+		return
+	}
+	req := &web3SignerSignRequestJSON{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+
+		return
+	}
+	if !web3SignerTypes[req.Type] {
+		http.Error(w, fmt.Sprintf("invalid request: unsupported type %q", req.Type), http.StatusBadRequest)
+
+		return
+	}
+	signingRoot, err := decodeHex(req.SigningRoot, "signing root")
+	if err == nil && len(signingRoot) != 32 {
+		err = errors.New("signing root must be 32 bytes")
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+
+		return
+	}
+
+	var signature e2types.Signature
+	if h.client != nil {
+		signature, err = h.combinedSignature(r, compositePubKey, signingRoot)
+	} else {
+		signature, err = h.partialSignature(r, compositePubKey, signingRoot)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, distributed.ErrNotFound):
+			http.Error(w, "public key not found", http.StatusNotFound)
+		case errors.Is(err, distributed.ErrLocked):
+			http.Error(w, "account is locked", http.StatusServiceUnavailable)
+		default:
+			http.Error(w, fmt.Sprintf("failed to sign: %v", err), http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		writeJSON(w, http.StatusOK, &web3SignerSignResponseJSON{
+			Signature: fmt.Sprintf("%#x", signature.Marshal()),
+		})
+
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write([]byte(fmt.Sprintf("%#x", signature.Marshal())))
+}
+
+// partialSignature provides the partial signature of the account over the signing root.
+func (h *Web3SignerHandler) partialSignature(r *http.Request, compositePubKey []byte, signingRoot []byte) (e2types.Signature, error) {
+	account, err := h.accounts.account(r.Context(), compositePubKey, true)
+	if err != nil {
+		return nil, err
+	}
+	signer, isSigner := account.(e2wtypes.AccountSigner)
+	if !isSigner {
+		return nil, errors.New("account cannot sign")
+	}
+
+	return signer.Sign(r.Context(), signingRoot)
+}
+
+// combinedSignature provides the composite signature of the account over the signing root.
+func (h *Web3SignerHandler) combinedSignature(r *http.Request, compositePubKey []byte, signingRoot []byte) (e2types.Signature, error) {
+	account, err := h.accounts.account(r.Context(), compositePubKey, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.client.SignSigningRoot(r.Context(), account, signingRoot)
+}
@@ -0,0 +1,252 @@
+// Copyright © 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	"github.com/wealdtech/go-eth2-wallet-distributed/remote"
+)
+
+// web3SignerRequest makes a request of the handler, returning the status and body of the response.
+func web3SignerRequest(t *testing.T, handler http.Handler, method string, path string, body string, accept string) (int, string) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	res, err := io.ReadAll(rec.Result().Body)
+	require.NoError(t, err)
+
+	return rec.Code, strings.TrimSpace(string(res))
+}
+
+func TestWeb3SignerPartial(t *testing.T) {
+	vvec, shares := generateShares(t, 2, 1, 2, 3)
+	wallet, account := participantWallet(t, vvec, shares[2], map[uint64]string{1: "a", 2: "b", 3: "c"})
+	handler, err := remote.NewWeb3SignerHandler(wallet, remote.WithWeb3SignerPassphrases([]byte("pass")))
+	require.NoError(t, err)
+
+	signingRoot := bytes.Repeat([]byte{0x01}, 32)
+	signPath := fmt.Sprintf("%s%#x", remote.Web3SignerSignPath, vvec[0])
+	signBody := fmt.Sprintf(`{"type":"ATTESTATION","fork_info":{},"signingRoot":"%#x","attestation":{}}`, signingRoot)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		res    string
+	}{
+		{
+			name:   "Upcheck",
+			method: http.MethodGet,
+			path:   remote.Web3SignerUpcheckPath,
+			status: http.StatusOK,
+			res:    "OK",
+		},
+		{
+			name:   "PublicKeys",
+			method: http.MethodGet,
+			path:   remote.Web3SignerPublicKeysPath,
+			status: http.StatusOK,
+			res:    fmt.Sprintf(`["%#x"]`, vvec[0]),
+		},
+		{
+			name:   "SignWrongMethod",
+			method: http.MethodGet,
+			path:   signPath,
+			status: http.StatusMethodNotAllowed,
+			res:    "method not allowed",
+		},
+		{
+			name:   "SignIdentifierInvalid",
+			method: http.MethodPost,
+			path:   remote.Web3SignerSignPath + "0xinvalid",
+			body:   signBody,
+			status: http.StatusBadRequest,
+			res:    "invalid request: invalid identifier: encoding/hex: invalid byte: U+0069 'i'",
+		},
+		{
+			name:   "SignInvalidJSON",
+			method: http.MethodPost,
+			path:   signPath,
+			body:   `{`,
+			status: http.StatusBadRequest,
+			res:    "invalid request: unexpected EOF",
+		},
+		{
+			name:   "SignTypeInvalid",
+			method: http.MethodPost,
+			path:   signPath,
+			body:   fmt.Sprintf(`{"type":"UNKNOWN","signingRoot":"%#x"}`, signingRoot),
+			status: http.StatusBadRequest,
+			res:    `invalid request: unsupported type "UNKNOWN"`,
+		},
+		{
+			name:   "SignSigningRootMissing",
+			method: http.MethodPost,
+			path:   signPath,
+			body:   `{"type":"ATTESTATION"}`,
+			status: http.StatusBadRequest,
+			res:    "invalid request: signing root missing",
+		},
+		{
+			name:   "SignSigningRootShort",
+			method: http.MethodPost,
+			path:   signPath,
+			body:   fmt.Sprintf(`{"type":"ATTESTATION","signingRoot":"%#x"}`, signingRoot[1:]),
+			status: http.StatusBadRequest,
+			res:    "invalid request: signing root must be 32 bytes",
+		},
+		{
+			name:   "SignUnknownKey",
+			method: http.MethodPost,
+			path:   fmt.Sprintf("%s%#x", remote.Web3SignerSignPath, vvec[1]),
+			body:   signBody,
+			status: http.StatusNotFound,
+			res:    "public key not found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, res := web3SignerRequest(t, handler, test.method, test.path, test.body, "")
+			require.Equal(t, test.status, status)
+			require.Equal(t, test.res, res)
+		})
+	}
+
+	t.Run("SignText", func(t *testing.T) {
+		status, res := web3SignerRequest(t, handler, http.MethodPost, signPath, signBody, "")
+		require.Equal(t, http.StatusOK, status)
+		signatureBytes, err := hex.DecodeString(strings.TrimPrefix(res, "0x"))
+		require.NoError(t, err)
+		signature, err := e2types.BLSSignatureFromBytes(signatureBytes)
+		require.NoError(t, err)
+		require.True(t, signature.Verify(signingRoot, account.PublicKey()))
+	})
+
+	t.Run("SignJSON", func(t *testing.T) {
+		status, res := web3SignerRequest(t, handler, http.MethodPost, signPath, signBody, "application/json")
+		require.Equal(t, http.StatusOK, status)
+		data := make(map[string]string)
+		require.NoError(t, json.Unmarshal([]byte(res), &data))
+		signatureBytes, err := hex.DecodeString(strings.TrimPrefix(data["signature"], "0x"))
+		require.NoError(t, err)
+		signature, err := e2types.BLSSignatureFromBytes(signatureBytes)
+		require.NoError(t, err)
+		require.True(t, signature.Verify(signingRoot, account.PublicKey()))
+	})
+
+	t.Run("Locked", func(t *testing.T) {
+		handler, err := remote.NewWeb3SignerHandler(wallet)
+		require.NoError(t, err)
+		status, res := web3SignerRequest(t, handler, http.MethodPost, signPath, signBody, "")
+		require.Equal(t, http.StatusServiceUnavailable, status)
+		require.Equal(t, "account is locked", res)
+	})
+}
+
+func TestWeb3SignerCombined(t *testing.T) {
+	ctx := context.Background()
+	vvec, shares := generateShares(t, 2, 1, 2, 3)
+
+	// Participants are remote servers, and the handler's wallet holds the account of the first participant.
+	participants := make(map[uint64]*distributed.Participant)
+	for _, participantID := range []uint64{1, 2, 3} {
+		wallet, _ := participantWallet(t, vvec, shares[participantID], map[uint64]string{1: "a", 2: "b", 3: "c"})
+		server, err := remote.NewServer(wallet, remote.WithBearerTokens("secret"), remote.WithPassphrases([]byte("pass")))
+		require.NoError(t, err)
+		participants[participantID] = &distributed.Participant{Endpoint: fmt.Sprintf("http://%s", serve(t, server))}
+	}
+	wallet, account := participantWallet(t, vvec, shares[1], map[uint64]string{1: "a", 2: "b", 3: "c"})
+	require.NoError(t, account.(distributed.AccountParticipantsSetter).SetParticipants(ctx, participants))
+
+	client, err := remote.NewClient(remote.NewHTTPTransport(remote.WithBearerToken("secret")))
+	require.NoError(t, err)
+	handler, err := remote.NewWeb3SignerHandler(wallet, remote.WithThresholdClient(client))
+	require.NoError(t, err)
+
+	signingRoot := bytes.Repeat([]byte{0x01}, 32)
+	status, res := web3SignerRequest(t, handler, http.MethodPost,
+		fmt.Sprintf("%s%#x", remote.Web3SignerSignPath, vvec[0]),
+		fmt.Sprintf(`{"type":"BLOCK_V2","signingRoot":"%#x"}`, signingRoot),
+		"")
+	require.Equal(t, http.StatusOK, status)
+	signatureBytes, err := hex.DecodeString(strings.TrimPrefix(res, "0x"))
+	require.NoError(t, err)
+	signature, err := e2types.BLSSignatureFromBytes(signatureBytes)
+	require.NoError(t, err)
+	compositePubKey, err := e2types.BLSPublicKeyFromBytes(vvec[0])
+	require.NoError(t, err)
+	require.True(t, signature.Verify(signingRoot, compositePubKey))
+}
+
+func TestWeb3SignerCombinedWatchOnly(t *testing.T) {
+	ctx := context.Background()
+	// All participants are required to sign, so that no requests to participants are abandoned.
+	vvec, shares := generateShares(t, 3, 1, 2, 3)
+
+	participants := make(map[uint64]*distributed.Participant)
+	for _, participantID := range []uint64{1, 2, 3} {
+		wallet, _ := participantWallet(t, vvec, shares[participantID], map[uint64]string{1: "a", 2: "b", 3: "c"})
+		server, err := remote.NewServer(wallet, remote.WithBearerTokens("secret"), remote.WithPassphrases([]byte("pass")))
+		require.NoError(t, err)
+		participants[participantID] = &distributed.Participant{Endpoint: fmt.Sprintf("http://%s", serve(t, server))}
+	}
+	// The handler's wallet holds the account without a share.
+	wallet, account := participantWallet(t, vvec, nil, map[uint64]string{1: "a", 2: "b", 3: "c"})
+	require.True(t, account.(distributed.AccountWatchOnlyProvider).WatchOnly())
+	require.NoError(t, account.(distributed.AccountParticipantsSetter).SetParticipants(ctx, participants))
+
+	client, err := remote.NewClient(remote.NewHTTPTransport(remote.WithBearerToken("secret")))
+	require.NoError(t, err)
+	handler, err := remote.NewWeb3SignerHandler(wallet, remote.WithThresholdClient(client))
+	require.NoError(t, err)
+
+	status, res := web3SignerRequest(t, handler, http.MethodGet, remote.Web3SignerPublicKeysPath, "", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, fmt.Sprintf(`["%#x"]`, vvec[0]), res)
+
+	signingRoot := bytes.Repeat([]byte{0x01}, 32)
+	status, res = web3SignerRequest(t, handler, http.MethodPost,
+		fmt.Sprintf("%s%#x", remote.Web3SignerSignPath, vvec[0]),
+		fmt.Sprintf(`{"type":"BLOCK_V2","signingRoot":"%#x"}`, signingRoot),
+		"")
+	require.Equal(t, http.StatusOK, status)
+	signatureBytes, err := hex.DecodeString(strings.TrimPrefix(res, "0x"))
+	require.NoError(t, err)
+	signature, err := e2types.BLSSignatureFromBytes(signatureBytes)
+	require.NoError(t, err)
+	compositePubKey, err := e2types.BLSPublicKeyFromBytes(vvec[0])
+	require.NoError(t, err)
+	require.True(t, signature.Verify(signingRoot, compositePubKey))
+}
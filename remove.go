@@ -0,0 +1,69 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// WalletAccountRemover is the interface for wallets that can remove accounts.
+type WalletAccountRemover interface {
+	// RemoveAccount removes the account with the given name from the wallet.
+	RemoveAccount(ctx context.Context, name string) error
+}
+
+// RemoveAccount removes the account with the given name from the wallet.
+// The wallet must be unlocked, and its store must be able to remove accounts.
+// If the wallet has a batch the account's entry in the batch is ignored from then on,
+// however its encrypted secret key remains in the batch until BatchWallet is called again.
+func (w *wallet) RemoveAccount(ctx context.Context, name string) error {
+	unlocked, err := w.IsUnlocked(ctx)
+	if err != nil {
+		return err
+	}
+	if !unlocked {
+		return newLockedError("wallet", w.name, "wallet must be unlocked to remove accounts")
+	}
+	remover, isRemover := w.store.(AccountRemover)
+	if !isRemover {
+		return fmt.Errorf("store %s cannot remove accounts", w.store.Name())
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	defer w.resetAccountKeys()
+
+	id, exists := w.index.ID(name)
+	if !exists {
+		return newNotFoundError("account", name, nil, "no account with name %q", name)
+	}
+
+	// Remove the account from the index first, so that a failure to remove the stored account
+	// leaves the account outside of the wallet rather than the index referring to a missing account.
+	w.index.Remove(id, name)
+	if err := w.storeAccountsIndex(); err != nil {
+		w.index.Add(id, name)
+
+		return errors.Wrap(err, "failed to store account index")
+	}
+	delete(w.accounts, id)
+	if err := remover.RemoveAccount(w.id, id); err != nil {
+		return errors.Wrapf(err, "failed to remove account %q", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+// Copyright © 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	scratch "github.com/wealdtech/go-eth2-wallet-store-scratch"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+// removingStore is a store that supports removal of accounts.
+type removingStore struct {
+	e2wtypes.Store
+	removed map[uuid.UUID]bool
+}
+
+func newRemovingStore() *removingStore {
+	return &removingStore{
+		Store:   scratch.New(),
+		removed: make(map[uuid.UUID]bool),
+	}
+}
+
+func (s *removingStore) RemoveAccount(_ uuid.UUID, accountID uuid.UUID) error {
+	s.removed[accountID] = true
+
+	return nil
+}
+
+func (s *removingStore) RetrieveAccount(walletID uuid.UUID, accountID uuid.UUID) ([]byte, error) {
+	if s.removed[accountID] {
+		return nil, errors.New("account not found")
+	}
+
+	return s.Store.RetrieveAccount(walletID, accountID)
+}
+
+func (s *removingStore) RetrieveAccounts(walletID uuid.UUID) <-chan []byte {
+	ch := make(chan []byte, 1024)
+	go func() {
+		defer close(ch)
+		for data := range s.Store.RetrieveAccounts(walletID) {
+			info := &struct {
+				ID uuid.UUID `json:"uuid"`
+			}{}
+			if err := json.Unmarshal(data, info); err == nil && s.removed[info.ID] {
+				continue
+			}
+			ch <- data
+		}
+	}()
+
+	return ch
+}
+
+func (s *removingStore) StoreBatch(ctx context.Context, walletID uuid.UUID, walletName string, data []byte) error {
+	return s.Store.(e2wtypes.BatchStorer).StoreBatch(ctx, walletID, walletName, data)
+}
+
+func (s *removingStore) RetrieveBatch(ctx context.Context, walletID uuid.UUID) ([]byte, error) {
+	return s.Store.(e2wtypes.BatchRetriever).RetrieveBatch(ctx, walletID)
+}
+
+// removalWallet creates a wallet with three accounts in the given store.
+func removalWallet(t *testing.T, store e2wtypes.Store) e2wtypes.Wallet {
+	t.Helper()
+	ctx := context.Background()
+
+	wallet, err := distributed.CreateWallet(ctx, "test wallet", store, keystorev4.New())
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+	for i := 1; i <= 3; i++ {
+		vvec, shares := generateShares(t, 2, 1, 2, 3)
+		_, err := wallet.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+			fmt.Sprintf("Account %d", i), shares[1], 2, vvec,
+			map[uint64]string{1: "signer1:443", 2: "signer2:443", 3: "signer3:443"}, []byte("pass"))
+		require.NoError(t, err)
+	}
+
+	return wallet
+}
+
+// accountNames returns the names of the accounts in the wallet.
+func accountNames(ctx context.Context, wallet e2wtypes.Wallet) map[string]bool {
+	res := make(map[string]bool)
+	for account := range wallet.Accounts(ctx) {
+		res[account.Name()] = true
+	}
+
+	return res
+}
+
+func TestRemoveAccount(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("StoreCannotRemove", func(t *testing.T) {
+		wallet := removalWallet(t, scratch.New())
+		err := wallet.(distributed.WalletAccountRemover).RemoveAccount(ctx, "Account 1")
+		require.EqualError(t, err, "store scratch cannot remove accounts")
+	})
+
+	t.Run("Locked", func(t *testing.T) {
+		wallet := removalWallet(t, newRemovingStore())
+		require.NoError(t, wallet.(e2wtypes.WalletLocker).Lock(ctx))
+		err := wallet.(distributed.WalletAccountRemover).RemoveAccount(ctx, "Account 1")
+		require.ErrorIs(t, err, distributed.ErrLocked)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		wallet := removalWallet(t, newRemovingStore())
+		err := wallet.(distributed.WalletAccountRemover).RemoveAccount(ctx, "Account 4")
+		require.ErrorIs(t, err, distributed.ErrNotFound)
+	})
+
+	t.Run("Good", func(t *testing.T) {
+		store := newRemovingStore()
+		wallet := removalWallet(t, store)
+		account, err := wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 2")
+		require.NoError(t, err)
+		pubKey := account.PublicKey().Marshal()
+		// Index the public keys before removal.
+		_, err = wallet.(distributed.WalletAccountByPublicKeyProvider).AccountByPublicKey(ctx, pubKey)
+		require.NoError(t, err)
+
+		require.NoError(t, wallet.(distributed.WalletAccountRemover).RemoveAccount(ctx, "Account 2"))
+		require.Equal(t, map[string]bool{"Account 1": true, "Account 3": true}, accountNames(ctx, wallet))
+		_, err = wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 2")
+		require.ErrorIs(t, err, distributed.ErrNotFound)
+		_, err = wallet.(distributed.WalletAccountByPublicKeyProvider).AccountByPublicKey(ctx, pubKey)
+		require.ErrorIs(t, err, distributed.ErrNotFound)
+
+		reopened, err := distributed.OpenWallet(ctx, "test wallet", store, keystorev4.New())
+		require.NoError(t, err)
+		require.Equal(t, map[string]bool{"Account 1": true, "Account 3": true}, accountNames(ctx, reopened))
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		store := newRemovingStore()
+		wallet := removalWallet(t, store)
+		require.NoError(t, wallet.(e2wtypes.WalletBatchCreator).BatchWallet(ctx, []string{"pass"}, "batch passphrase"))
+		wallet, err := distributed.OpenWallet(ctx, "test wallet", store, keystorev4.New())
+		require.NoError(t, err)
+		require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+
+		require.NoError(t, wallet.(distributed.WalletAccountRemover).RemoveAccount(ctx, "Account 2"))
+		require.Equal(t, map[string]bool{"Account 1": true, "Account 3": true}, accountNames(ctx, wallet))
+
+		// The stale batch entry is ignored when the wallet is reopened, and remaining accounts can be unlocked.
+		reopened, err := distributed.OpenWallet(ctx, "test wallet", store, keystorev4.New())
+		require.NoError(t, err)
+		require.Equal(t, map[string]bool{"Account 1": true, "Account 3": true}, accountNames(ctx, reopened))
+		account, err := reopened.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 3")
+		require.NoError(t, err)
+		require.NoError(t, account.(e2wtypes.AccountLocker).Unlock(ctx, []byte("batch passphrase")))
+		_, err = account.(e2wtypes.AccountSigner).Sign(ctx, make([]byte, 32))
+		require.NoError(t, err)
+
+		// The stale batch entry does not prevent the batch being exported and imported.
+		dump, err := reopened.(distributed.WalletOptionsExporter).ExportWithOptions(ctx, []byte("dump"), distributed.WithBatch())
+		require.NoError(t, err)
+		store2 := scratch.New()
+		_, err = distributed.Import(ctx, dump, []byte("dump"), store2, keystorev4.New())
+		require.NoError(t, err)
+		imported, err := distributed.OpenWallet(ctx, "test wallet", store2, keystorev4.New())
+		require.NoError(t, err)
+		require.Equal(t, map[string]bool{"Account 1": true, "Account 3": true}, accountNames(ctx, imported))
+		account, err = imported.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 3")
+		require.NoError(t, err)
+		require.NoError(t, account.(e2wtypes.AccountLocker).Unlock(ctx, []byte("batch passphrase")))
+	})
+}
@@ -0,0 +1,144 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed
+
+import (
+	"fmt"
+	"sort"
+
+	bls "github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/pkg/errors"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+)
+
+// AccountSharePublicKeyProvider is the interface for accounts that can provide the public keys
+// of their participants' shares.
+type AccountSharePublicKeyProvider interface {
+	// SharePublicKey provides the public key of the share held by the participant.
+	SharePublicKey(participantID uint64) (e2types.PublicKey, error)
+}
+
+// AccountPartialSignatureVerifier is the interface for accounts that can verify their participants'
+// partial signatures.
+type AccountPartialSignatureVerifier interface {
+	// VerifyPartialSignature verifies the participant's partial signature over the data.
+	VerifyPartialSignature(participantID uint64, data []byte, signature e2types.Signature) error
+}
+
+// AccountPartialSignatureCombiner is the interface for accounts that can combine their participants'
+// partial signatures in to a composite signature.
+type AccountPartialSignatureCombiner interface {
+	// CombinePartialSignatures combines the participants' partial signatures over the data
+	// in to a composite signature.
+	CombinePartialSignatures(data []byte, signatures map[uint64]e2types.Signature) (e2types.Signature, error)
+}
+
+// SharePublicKey provides the public key of the share held by the participant.
+// This is the share public key in the participant's descriptor if present, otherwise
+// it is obtained from the verification vector.
+func (a *account) SharePublicKey(participantID uint64) (e2types.PublicKey, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	return a.sharePublicKey(participantID)
+}
+
+// sharePublicKey provides the public key of the share held by the participant.
+// This assumes that the account mutex is held.
+func (a *account) sharePublicKey(participantID uint64) (e2types.PublicKey, error) {
+	participant, exists := a.participants[participantID]
+	if !exists {
+		return nil, fmt.Errorf("participant %d not in account", participantID)
+	}
+	if participant.SharePublicKey != nil {
+		return participant.SharePublicKey, nil
+	}
+
+	sharePubKey, err := evaluateVerificationVector(a.verificationVector, participantID)
+	if err != nil {
+		return nil, err
+	}
+	res, err := e2types.BLSPublicKeyFromBytes(sharePubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid share public key")
+	}
+
+	return res, nil
+}
+
+// VerifyPartialSignature verifies the participant's partial signature over the data.
+func (a *account) VerifyPartialSignature(participantID uint64, data []byte, signature e2types.Signature) error {
+	sharePubKey, err := a.SharePublicKey(participantID)
+	if err != nil {
+		return err
+	}
+	if !signature.Verify(data, sharePubKey) {
+		return fmt.Errorf("partial signature from participant %d is invalid", participantID)
+	}
+
+	return nil
+}
+
+// CombinePartialSignatures combines the participants' partial signatures over the data in to a
+// composite signature.  At least the signing threshold of signatures must be supplied.  The composite
+// signature is verified against the composite public key, so any invalid partial signature will
+// result in an error.
+func (a *account) CombinePartialSignatures(data []byte, signatures map[uint64]e2types.Signature) (e2types.Signature, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	if uint32(len(signatures)) < a.signingThreshold {
+		return nil, fmt.Errorf("%d partial signatures supplied but %d required", len(signatures), a.signingThreshold)
+	}
+	if len(a.verificationVector) == 0 {
+		return nil, errors.New("account has no verification vector")
+	}
+
+	// Order the participants to make the combination deterministic.
+	participantIDs := make([]uint64, 0, len(signatures))
+	for participantID := range signatures {
+		if _, exists := a.participants[participantID]; !exists {
+			return nil, fmt.Errorf("participant %d not in account", participantID)
+		}
+		participantIDs = append(participantIDs, participantID)
+	}
+	sort.Slice(participantIDs, func(i, j int) bool { return participantIDs[i] < participantIDs[j] })
+
+	sigs := make([]bls.Sign, len(participantIDs))
+	ids := make([]bls.ID, len(participantIDs))
+	for i, participantID := range participantIDs {
+		if err := sigs[i].Deserialize(signatures[participantID].Marshal()); err != nil {
+			return nil, errors.Wrapf(err, "invalid partial signature from participant %d", participantID)
+		}
+		id, err := blsID(participantID)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = *id
+	}
+	var sig bls.Sign
+	if err := sig.Recover(sigs, ids); err != nil {
+		return nil, errors.Wrap(err, "failed to combine partial signatures")
+	}
+
+	res, err := e2types.BLSSignatureFromBytes(sig.Serialize())
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid composite signature")
+	}
+	if !res.Verify(data, a.verificationVector[0]) {
+		return nil, errors.New("composite signature is invalid")
+	}
+
+	return res, nil
+}
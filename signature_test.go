@@ -0,0 +1,74 @@
+// Copyright © 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	scratch "github.com/wealdtech/go-eth2-wallet-store-scratch"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+func TestPartialSignatures(t *testing.T) {
+	ctx := context.Background()
+	wallet, err := distributed.CreateWallet(ctx, "test wallet", scratch.New(), keystorev4.New())
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+
+	vvec, shares := generateShares(t, 2, 1, 2, 3)
+	account, err := wallet.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+		"Account 1", shares[1], 2, vvec, map[uint64]string{1: "signer1:443", 2: "signer2:443", 3: "signer3:443"}, []byte("pass"))
+	require.NoError(t, err)
+	verifier := account.(distributed.AccountPartialSignatureVerifier)
+	combiner := account.(distributed.AccountPartialSignatureCombiner)
+
+	data := []byte("data to sign")
+	partials := make(map[uint64]e2types.Signature)
+	for participantID, share := range shares {
+		key, err := e2types.BLSPrivateKeyFromBytes(share)
+		require.NoError(t, err)
+		partials[participantID] = key.Sign(data)
+
+		sharePubKey, err := account.(distributed.AccountSharePublicKeyProvider).SharePublicKey(participantID)
+		require.NoError(t, err)
+		require.Equal(t, key.PublicKey().Marshal(), sharePubKey.Marshal())
+		require.NoError(t, verifier.VerifyPartialSignature(participantID, data, partials[participantID]))
+	}
+	require.EqualError(t, verifier.VerifyPartialSignature(2, data, partials[1]), "partial signature from participant 2 is invalid")
+	require.EqualError(t, verifier.VerifyPartialSignature(4, data, partials[1]), "participant 4 not in account")
+
+	compositePubKey, err := e2types.BLSPublicKeyFromBytes(vvec[0])
+	require.NoError(t, err)
+	for _, participantIDs := range [][]uint64{{1, 2}, {1, 3}, {2, 3}, {1, 2, 3}} {
+		subset := make(map[uint64]e2types.Signature)
+		for _, participantID := range participantIDs {
+			subset[participantID] = partials[participantID]
+		}
+		signature, err := combiner.CombinePartialSignatures(data, subset)
+		require.NoError(t, err)
+		require.True(t, signature.Verify(data, compositePubKey))
+	}
+
+	_, err = combiner.CombinePartialSignatures(data, map[uint64]e2types.Signature{1: partials[1]})
+	require.EqualError(t, err, "1 partial signatures supplied but 2 required")
+	_, err = combiner.CombinePartialSignatures(data, map[uint64]e2types.Signature{1: partials[1], 4: partials[2]})
+	require.EqualError(t, err, "participant 4 not in account")
+	_, err = combiner.CombinePartialSignatures(data, map[uint64]e2types.Signature{1: partials[1], 2: partials[3]})
+	require.EqualError(t, err, "composite signature is invalid")
+}
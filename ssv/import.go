@@ -0,0 +1,174 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssv
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+// signingThreshold returns the signing threshold for a validator with the given number of operators.
+// SSV clusters have 3f+1 operators, of which 2f+1 are required to sign.
+func signingThreshold(operators int) (uint32, error) {
+	if operators < 4 || (operators-1)%3 != 0 {
+		return 0, fmt.Errorf("%d operators is not a valid cluster size", operators)
+	}
+
+	return uint32(operators - (operators-1)/3), nil
+}
+
+// Entries creates import entries for the validators in the keyshares of which the operator holds a share.
+// The operator's share of each validator is decrypted with its RSA key, and the verification vector of the
+// validator is recovered from the share public keys.  Participant IDs are the operators' IDs, and the
+// signing threshold is that of the cluster size.  Accounts are named after the public keys of their
+// validators.
+func Entries(keyShares *KeyShares, operatorID uint64, key *rsa.PrivateKey, opts ...Option) ([]*distributed.ImportEntry, error) {
+	options := parseOptions(opts)
+
+	entries := make([]*distributed.ImportEntry, 0, len(keyShares.Shares))
+	for i, share := range keyShares.Shares {
+		index := -1
+		for j := range share.OperatorIDs {
+			if share.OperatorIDs[j] == operatorID {
+				index = j
+			}
+		}
+		if index == -1 {
+			continue
+		}
+		entry, err := importEntry(share, index, key, options.endpoints)
+		if err != nil {
+			return nil, errors.Wrapf(err, "share %d", i)
+		}
+		entry.Source = fmt.Sprintf("share %d", i)
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("operator %d does not hold any shares", operatorID)
+	}
+
+	return entries, nil
+}
+
+// importEntry creates the import entry for the share held by the operator at the index.
+func importEntry(share *Share, index int, key *rsa.PrivateKey, endpoints map[uint64]string) (*distributed.ImportEntry, error) {
+	threshold, err := signingThreshold(len(share.OperatorIDs))
+	if err != nil {
+		return nil, err
+	}
+	if len(share.SharePublicKeys) != len(share.OperatorIDs) || len(share.EncryptedKeys) != len(share.OperatorIDs) {
+		return nil, errors.New("share public keys and encrypted keys must have one entry per operator")
+	}
+
+	secret, err := decryptShare(share.EncryptedKeys[index], key)
+	if err != nil {
+		return nil, err
+	}
+	secretKey, err := e2types.BLSPrivateKeyFromBytes(secret)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid secret key")
+	}
+	if !bytes.Equal(secretKey.PublicKey().Marshal(), share.SharePublicKeys[index]) {
+		return nil, errors.New("decrypted share does not match its share public key")
+	}
+
+	sharePublicKeys := make(map[uint64][]byte, len(share.OperatorIDs))
+	participants := make(map[uint64]*distributed.Participant, len(share.OperatorIDs))
+	for i, id := range share.OperatorIDs {
+		if _, exists := participants[id]; exists {
+			return nil, fmt.Errorf("operator %d duplicated", id)
+		}
+		sharePublicKeys[id] = share.SharePublicKeys[i]
+		participantSharePublicKey, err := e2types.BLSPublicKeyFromBytes(share.SharePublicKeys[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid share public key for operator %d", id)
+		}
+		participants[id] = &distributed.Participant{
+			Endpoint:       endpoints[id],
+			SharePublicKey: participantSharePublicKey,
+		}
+	}
+	verificationVector, err := distributed.VerificationVectorFromSharePublicKeys(threshold, sharePublicKeys)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid share public keys")
+	}
+	if !bytes.Equal(verificationVector[0], share.PublicKey) {
+		return nil, fmt.Errorf("share public keys do not correspond to validator public key %#x", share.PublicKey)
+	}
+
+	return &distributed.ImportEntry{
+		Name:               fmt.Sprintf("%#x", share.PublicKey),
+		SecretShare:        secret,
+		SigningThreshold:   threshold,
+		VerificationVector: verificationVector,
+		Participants:       participants,
+	}, nil
+}
+
+// decryptShare decrypts an operator's share with its RSA key.
+// The plaintext is the share's secret key as a hex string.
+func decryptShare(encryptedKey []byte, key *rsa.PrivateKey) ([]byte, error) {
+	plaintext, err := rsa.DecryptPKCS1v15(nil, key, encryptedKey)
+	if err != nil {
+		return nil, errors.New("failed to decrypt share with operator key")
+	}
+	secretHex := strings.TrimPrefix(strings.TrimSpace(string(plaintext)), "0x")
+	if len(secretHex)%2 == 1 {
+		secretHex = "0" + secretHex
+	}
+	secret, err := hex.DecodeString(secretHex)
+	if err != nil || len(secret) > 32 {
+		return nil, errors.New("decrypted share is invalid")
+	}
+
+	// Leading zeros may be omitted from the hex string.
+	return append(make([]byte, 32-len(secret)), secret...), nil
+}
+
+// ImportKeyShares imports the validators in the keyshares of which the operator holds a share in to the
+// wallet.  The wallet must be unlocked.  The accounts are imported with ImportDistributedAccounts,
+// protected by the passphrase, and a result is returned for each validator.
+func ImportKeyShares(ctx context.Context,
+	wallet e2wtypes.Wallet,
+	keyShares *KeyShares,
+	operatorID uint64,
+	key *rsa.PrivateKey,
+	passphrase []byte,
+	opts ...Option,
+) (
+	[]*distributed.ImportResult,
+	error,
+) {
+	importer, isImporter := wallet.(distributed.WalletDistributedAccountsImporter)
+	if !isImporter {
+		return nil, errors.New("wallet cannot import distributed accounts in bulk")
+	}
+	options := parseOptions(opts)
+
+	entries, err := Entries(keyShares, operatorID, key, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return importer.ImportDistributedAccounts(ctx, entries, passphrase, distributed.WithConcurrency(options.concurrency))
+}
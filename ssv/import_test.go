@@ -0,0 +1,255 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssv_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"testing"
+
+	bls "github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	"github.com/wealdtech/go-eth2-wallet-distributed/ssv"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	scratch "github.com/wealdtech/go-eth2-wallet-store-scratch"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+func TestMain(m *testing.M) {
+	if err := e2types.InitBLS(); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// operatorKey is the RSA key of the operator under test.
+var operatorKey *rsa.PrivateKey
+
+func init() {
+	var err error
+	operatorKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// validatorPayload creates the keyshares payload for a new validator held by the operators, with the share
+// of the operator under test encrypted with its key.  It returns the payload and the validator's public key.
+func validatorPayload(t *testing.T, operatorIDs []uint64, operatorID uint64) (map[string]any, []byte) {
+	t.Helper()
+
+	msk := make([]bls.SecretKey, len(operatorIDs)-(len(operatorIDs)-1)/3)
+	for i := range msk {
+		msk[i].SetByCSPRNG()
+	}
+	sharesData := make([]byte, 96)
+	encryptedKeys := make([]byte, 0)
+	for _, id := range operatorIDs {
+		var blsID bls.ID
+		require.NoError(t, blsID.SetDecString(fmt.Sprintf("%d", id)))
+		var share bls.SecretKey
+		require.NoError(t, share.Set(msk, &blsID))
+		sharesData = append(sharesData, share.GetPublicKey().Serialize()...)
+		encryptedKey := make([]byte, 256)
+		if id == operatorID {
+			var err error
+			encryptedKey, err = rsa.EncryptPKCS1v15(rand.Reader, &operatorKey.PublicKey, []byte(share.SerializeToHexStr()))
+			require.NoError(t, err)
+		}
+		encryptedKeys = append(encryptedKeys, encryptedKey...)
+	}
+	sharesData = append(sharesData, encryptedKeys...)
+	publicKey := msk[0].GetPublicKey().Serialize()
+
+	return map[string]any{
+		"publicKey":   fmt.Sprintf("%#x", publicKey),
+		"operatorIds": operatorIDs,
+		"sharesData":  fmt.Sprintf("%#x", sharesData),
+	}, publicKey
+}
+
+// keySharesData creates a keyshares export with the given payloads.
+func keySharesData(t *testing.T, payloads ...map[string]any) []byte {
+	t.Helper()
+
+	shares := make([]map[string]any, len(payloads))
+	for i := range payloads {
+		shares[i] = map[string]any{
+			"data":    map[string]any{"publicKey": payloads[i]["publicKey"]},
+			"payload": payloads[i],
+		}
+	}
+	data, err := json.Marshal(map[string]any{
+		"version": "v1.1.0",
+		"shares":  shares,
+	})
+	require.NoError(t, err)
+
+	return data
+}
+
+func TestParseKeyShares(t *testing.T) {
+	payload, publicKey := validatorPayload(t, []uint64{1, 5, 9, 12}, 5)
+
+	keyShares, err := ssv.ParseKeyShares(keySharesData(t, payload))
+	require.NoError(t, err)
+	require.Len(t, keyShares.Shares, 1)
+	require.Equal(t, publicKey, keyShares.Shares[0].PublicKey)
+	require.Equal(t, []uint64{1, 5, 9, 12}, keyShares.Shares[0].OperatorIDs)
+	require.Len(t, keyShares.Shares[0].SharePublicKeys, 4)
+	require.Len(t, keyShares.Shares[0].EncryptedKeys, 4)
+	require.Len(t, keyShares.Shares[0].EncryptedKeys[1], 256)
+
+	// Exports before version 1.1.0 contain a single payload.
+	data, err := json.Marshal(map[string]any{
+		"version": "v1.0.0",
+		"payload": payload,
+	})
+	require.NoError(t, err)
+	legacyKeyShares, err := ssv.ParseKeyShares(data)
+	require.NoError(t, err)
+	require.Equal(t, keyShares, legacyKeyShares)
+
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{
+			name: "Invalid",
+			data: `{`,
+			err:  "invalid JSON: unexpected end of JSON input",
+		},
+		{
+			name: "SharesMissing",
+			data: `{}`,
+			err:  "shares missing",
+		},
+		{
+			name: "PayloadMissing",
+			data: `{"shares":[{}]}`,
+			err:  "payload for share 0 missing",
+		},
+		{
+			name: "PublicKeyMissing",
+			data: `{"shares":[{"payload":{"operatorIds":[1,2,3,4]}}]}`,
+			err:  "share 0: public key missing",
+		},
+		{
+			name: "OperatorIDsMissing",
+			data: fmt.Sprintf(`{"shares":[{"payload":{"publicKey":"%#x"}}]}`, publicKey),
+			err:  "share 0: operator IDs missing",
+		},
+		{
+			name: "SharesDataShort",
+			data: fmt.Sprintf(`{"shares":[{"payload":{"publicKey":"%#x","operatorIds":[1,2,3,4],"sharesData":"0x0102"}}]}`, publicKey),
+			err:  "share 0: shares data has invalid length 2 for 4 operators",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ssv.ParseKeyShares([]byte(test.data))
+			require.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestParseOperatorKey(t *testing.T) {
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(operatorKey)})
+	pkcs8Bytes, err := x509.MarshalPKCS8PrivateKey(operatorKey)
+	require.NoError(t, err)
+	pkcs8 := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8Bytes})
+
+	for _, data := range [][]byte{
+		pkcs1,
+		pkcs8,
+		[]byte(base64.StdEncoding.EncodeToString(pkcs1) + "\n"),
+	} {
+		key, err := ssv.ParseOperatorKey(data)
+		require.NoError(t, err)
+		require.True(t, key.Equal(operatorKey))
+	}
+
+	_, err = ssv.ParseOperatorKey([]byte("not a key"))
+	require.ErrorContains(t, err, "key is neither PEM nor base64")
+	_, err = ssv.ParseOperatorKey([]byte(base64.StdEncoding.EncodeToString([]byte("not PEM"))))
+	require.EqualError(t, err, "invalid PEM")
+}
+
+func TestImportKeyShares(t *testing.T) {
+	ctx := context.Background()
+	operatorIDs := []uint64{1, 5, 9, 12}
+	payload1, publicKey1 := validatorPayload(t, operatorIDs, 5)
+	payload2, _ := validatorPayload(t, []uint64{1, 2, 3, 4}, 2)
+	payload3, publicKey3 := validatorPayload(t, []uint64{2, 5, 6, 7, 8, 9, 10}, 5)
+	keyShares, err := ssv.ParseKeyShares(keySharesData(t, payload1, payload2, payload3))
+	require.NoError(t, err)
+
+	wallet, err := distributed.CreateWallet(ctx, "test wallet", scratch.New(), keystorev4.New())
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+
+	results, err := ssv.ImportKeyShares(ctx, wallet, keyShares, 5, operatorKey, []byte("pass"),
+		ssv.WithEndpoints(map[uint64]string{5: "localhost:9000"}))
+	require.NoError(t, err)
+	// The operator does not hold a share of the second validator.
+	require.Len(t, results, 2)
+	require.NoError(t, results[0].Err)
+	require.Equal(t, "share 0", results[0].Source)
+	require.NoError(t, results[1].Err)
+	require.Equal(t, "share 2", results[1].Source)
+
+	for i, publicKey := range [][]byte{publicKey1, publicKey3} {
+		account := results[i].Account
+		require.Equal(t, fmt.Sprintf("%#x", publicKey), account.Name())
+		require.Equal(t, publicKey, account.(e2wtypes.AccountCompositePublicKeyProvider).CompositePublicKey().Marshal())
+		participantID, err := account.(distributed.AccountParticipantIDProvider).ParticipantID()
+		require.NoError(t, err)
+		require.Equal(t, uint64(5), participantID)
+		require.Equal(t, "localhost:9000", account.(distributed.AccountParticipantDescriptorsProvider).ParticipantDescriptors()[5].Endpoint)
+		require.NoError(t, account.(e2wtypes.AccountLocker).Unlock(ctx, []byte("pass")))
+	}
+	require.Equal(t, uint32(3), results[0].Account.(e2wtypes.AccountSigningThresholdProvider).SigningThreshold())
+	require.Equal(t, uint32(5), results[1].Account.(e2wtypes.AccountSigningThresholdProvider).SigningThreshold())
+
+	_, err = ssv.Entries(keyShares, 3, operatorKey)
+	require.EqualError(t, err, "share 1: failed to decrypt share with operator key")
+	_, err = ssv.Entries(keyShares, 11, operatorKey)
+	require.EqualError(t, err, "operator 11 does not hold any shares")
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, err = ssv.Entries(keyShares, 5, otherKey)
+	require.EqualError(t, err, "share 0: failed to decrypt share with operator key")
+
+	// Share public keys that do not correspond to the validator public key.
+	keyShares.Shares[0].PublicKey = publicKey3
+	_, err = ssv.Entries(keyShares, 5, operatorKey)
+	require.EqualError(t, err, fmt.Sprintf("share 0: share public keys do not correspond to validator public key %#x", publicKey3))
+
+	// Clusters must have 3f+1 operators.
+	keyShares.Shares[0].OperatorIDs = keyShares.Shares[0].OperatorIDs[:3]
+	_, err = ssv.Entries(keyShares, 5, operatorKey)
+	require.EqualError(t, err, "share 0: 3 operators is not a valid cluster size")
+}
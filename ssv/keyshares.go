@@ -0,0 +1,146 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ssv imports distributed validators from SSV keyshares in to distributed wallets.
+package ssv
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// signatureLength is the length of the owner signature at the start of the shares data.
+const signatureLength = 96
+
+// publicKeyLength is the length of a BLS public key.
+const publicKeyLength = 48
+
+// KeyShares contains the information from a keyshares export required to import its validators.
+type KeyShares struct {
+	// Shares are the validators in the export.
+	Shares []*Share
+}
+
+// Share contains the shares of a validator.
+type Share struct {
+	// PublicKey is the public key of the validator.
+	PublicKey []byte
+	// OperatorIDs are the IDs of the operators holding shares of the validator.
+	OperatorIDs []uint64
+	// SharePublicKeys are the public keys of the operators' shares, in the same order as the operator IDs.
+	SharePublicKeys [][]byte
+	// EncryptedKeys are the operators' shares, each encrypted with the operator's RSA key, in the
+	// same order as the operator IDs.
+	EncryptedKeys [][]byte
+}
+
+// keySharesJSON is the JSON representation of the parts of a keyshares export used here.
+// Exports from version 1.1.0 contain a list of shares; earlier exports contain a single share.
+type keySharesJSON struct {
+	Shares  []*shareJSON `json:"shares"`
+	Payload *payloadJSON `json:"payload"`
+}
+
+type shareJSON struct {
+	Payload *payloadJSON `json:"payload"`
+}
+
+type payloadJSON struct {
+	PublicKey   string   `json:"publicKey"`
+	OperatorIDs []uint64 `json:"operatorIds"`
+	SharesData  string   `json:"sharesData"`
+}
+
+// ParseKeyShares parses a keyshares export, as created by the SSV key tools.
+// Shares are obtained from the payload of each validator; the owner signature is not checked.
+func ParseKeyShares(data []byte) (*KeyShares, error) {
+	keySharesData := &keySharesJSON{}
+	if err := json.Unmarshal(data, keySharesData); err != nil {
+		return nil, errors.Wrap(err, "invalid JSON")
+	}
+
+	payloads := make([]*payloadJSON, 0, len(keySharesData.Shares))
+	for i, share := range keySharesData.Shares {
+		if share == nil || share.Payload == nil {
+			return nil, fmt.Errorf("payload for share %d missing", i)
+		}
+		payloads = append(payloads, share.Payload)
+	}
+	if len(payloads) == 0 {
+		if keySharesData.Payload == nil {
+			return nil, errors.New("shares missing")
+		}
+		payloads = append(payloads, keySharesData.Payload)
+	}
+
+	keyShares := &KeyShares{
+		Shares: make([]*Share, len(payloads)),
+	}
+	for i, payload := range payloads {
+		share, err := payload.share()
+		if err != nil {
+			return nil, errors.Wrapf(err, "share %d", i)
+		}
+		keyShares.Shares[i] = share
+	}
+
+	return keyShares, nil
+}
+
+// share obtains a share from its payload.
+func (p *payloadJSON) share() (*Share, error) {
+	publicKey, err := hex.DecodeString(strings.TrimPrefix(p.PublicKey, "0x"))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid public key")
+	}
+	if len(publicKey) != publicKeyLength {
+		return nil, errors.New("public key missing")
+	}
+	operators := len(p.OperatorIDs)
+	if operators == 0 {
+		return nil, errors.New("operator IDs missing")
+	}
+	sharesData, err := hex.DecodeString(strings.TrimPrefix(p.SharesData, "0x"))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid shares data")
+	}
+
+	// Shares data is the owner signature followed by the share public keys then the encrypted keys.
+	encryptedKeysLength := len(sharesData) - signatureLength - operators*publicKeyLength
+	if encryptedKeysLength <= 0 || encryptedKeysLength%operators != 0 {
+		return nil, fmt.Errorf("shares data has invalid length %d for %d operators", len(sharesData), operators)
+	}
+	encryptedKeyLength := encryptedKeysLength / operators
+
+	share := &Share{
+		PublicKey:       publicKey,
+		OperatorIDs:     p.OperatorIDs,
+		SharePublicKeys: make([][]byte, operators),
+		EncryptedKeys:   make([][]byte, operators),
+	}
+	offset := signatureLength
+	for i := range share.SharePublicKeys {
+		share.SharePublicKeys[i] = sharesData[offset : offset+publicKeyLength]
+		offset += publicKeyLength
+	}
+	for i := range share.EncryptedKeys {
+		share.EncryptedKeys[i] = sharesData[offset : offset+encryptedKeyLength]
+		offset += encryptedKeyLength
+	}
+
+	return share, nil
+}
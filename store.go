@@ -0,0 +1,30 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed
+
+import (
+	"github.com/google/uuid"
+)
+
+// AccountRemover is the interface for stores that can remove accounts.
+type AccountRemover interface {
+	// RemoveAccount removes an account from the store.
+	RemoveAccount(walletID uuid.UUID, accountID uuid.UUID) error
+}
+
+// WalletRemover is the interface for stores that can remove wallets.
+type WalletRemover interface {
+	// RemoveWallet removes a wallet from the store.
+	RemoveWallet(walletID uuid.UUID) error
+}
@@ -0,0 +1,227 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	bls "github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/pkg/errors"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+)
+
+// ThresholdPolicy defines the signing thresholds that a wallet accepts for new accounts,
+// in relation to the number of participants.  Regardless of policy the signing threshold
+// must be at least 1 and cannot exceed the number of participants.
+type ThresholdPolicy int
+
+const (
+	// ThresholdHonestMajority requires the signing threshold to be more than half of the participants.
+	ThresholdHonestMajority ThresholdPolicy = iota
+	// ThresholdSupermajority requires the signing threshold to be at least two thirds of the participants.
+	ThresholdSupermajority
+	// ThresholdAny accepts any signing threshold.
+	ThresholdAny
+)
+
+// allows returns true if the policy allows the signing threshold for the number of participants.
+func (p ThresholdPolicy) allows(signingThreshold uint32, participants int) bool {
+	if signingThreshold == 0 {
+		return false
+	}
+	threshold := uint64(signingThreshold)
+	n := uint64(participants)
+	switch p {
+	case ThresholdHonestMajority:
+		return threshold > n/2
+	case ThresholdSupermajority:
+		return 3*threshold >= 2*n
+	default:
+		return true
+	}
+}
+
+// validateParticipants checks the participants and signing threshold of an account against the policy.
+// Participant IDs are unique by virtue of being map keys, but must be non-zero as participants'
+// shares are evaluated at their IDs.
+func (p ThresholdPolicy) validateParticipants(signingThreshold uint32, participants map[uint64]*Participant) error {
+	if len(participants) == 0 {
+		return errors.New("participants missing")
+	}
+	for k, v := range participants {
+		if k == 0 {
+			return errors.New("participant ID 0 invalid")
+		}
+		if v == nil {
+			return fmt.Errorf("participant %d missing", k)
+		}
+	}
+	if signingThreshold > uint32(len(participants)) {
+		return fmt.Errorf("signing threshold %d exceeds number of participants %d", signingThreshold, len(participants))
+	}
+	if !p.allows(signingThreshold, len(participants)) {
+		return errors.New("invalid signing threshold:participant ratio")
+	}
+
+	return nil
+}
+
+// blsID returns the BLS ID for a participant.
+func blsID(participantID uint64) (*bls.ID, error) {
+	var id bls.ID
+	if err := id.SetDecString(fmt.Sprintf("%d", participantID)); err != nil {
+		return nil, errors.Wrapf(err, "failed to set ID for participant %d", participantID)
+	}
+
+	return &id, nil
+}
+
+// evaluateVerificationVector returns the public key of the share held by a participant,
+// obtained by evaluating the verification vector at the participant's ID.
+func evaluateVerificationVector(verificationVector []e2types.PublicKey, participantID uint64) ([]byte, error) {
+	if len(verificationVector) == 0 {
+		return nil, errors.New("verification vector missing")
+	}
+	mpk := make([]bls.PublicKey, len(verificationVector))
+	for i := range verificationVector {
+		if err := mpk[i].Deserialize(verificationVector[i].Marshal()); err != nil {
+			return nil, errors.Wrapf(err, "invalid verification vector element %d", i)
+		}
+	}
+	id, err := blsID(participantID)
+	if err != nil {
+		return nil, err
+	}
+	var pubKey bls.PublicKey
+	if err := pubKey.Set(mpk, id); err != nil {
+		return nil, errors.Wrap(err, "failed to evaluate verification vector")
+	}
+
+	return pubKey.Serialize(), nil
+}
+
+// VerificationVectorFromSharePublicKeys recovers the verification vector of a distributed account from
+// the public keys of its participants' shares, keyed by participant ID.  At least signingThreshold share
+// public keys must be supplied; the verification vector is interpolated from those with the lowest
+// participant IDs, and any others are checked against it.
+func VerificationVectorFromSharePublicKeys(signingThreshold uint32, sharePublicKeys map[uint64][]byte) ([][]byte, error) {
+	if signingThreshold == 0 {
+		return nil, errors.New("signing threshold must be at least 1")
+	}
+	if uint32(len(sharePublicKeys)) < signingThreshold {
+		return nil, fmt.Errorf("%d share public keys supplied but %d required", len(sharePublicKeys), signingThreshold)
+	}
+	ids := make([]uint64, 0, len(sharePublicKeys))
+	for id := range sharePublicKeys {
+		if id == 0 {
+			return nil, errors.New("participant ID 0 invalid")
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	// Interpolate the coefficients of the polynomial in G1 from the first signingThreshold points.
+	points := ids[:signingThreshold]
+	xs := make([]bls.Fr, len(points))
+	ys := make([]bls.G1, len(points))
+	for i, id := range points {
+		x, err := blsID(id)
+		if err != nil {
+			return nil, err
+		}
+		// IDs are elements of the same field as the coefficients, and share its serialization.
+		if err := xs[i].Deserialize(x.Serialize()); err != nil {
+			return nil, errors.Wrapf(err, "failed to set coordinate for participant %d", id)
+		}
+		var pubKey bls.PublicKey
+		if err := pubKey.Deserialize(sharePublicKeys[id]); err != nil {
+			return nil, errors.Wrapf(err, "invalid share public key for participant %d", id)
+		}
+		ys[i] = *bls.CastFromPublicKey(&pubKey)
+	}
+	coefficients := make([][]bls.Fr, len(points))
+	for i := range points {
+		coefficients[i] = lagrangeBasisCoefficients(xs, i)
+	}
+	verificationVector := make([][]byte, len(points))
+	scalars := make([]bls.Fr, len(points))
+	for k := range verificationVector {
+		for i := range points {
+			scalars[i] = coefficients[i][k]
+		}
+		var coefficient bls.G1
+		bls.G1MulVec(&coefficient, ys, scalars)
+		verificationVector[k] = bls.CastToPublicKey(&coefficient).Serialize()
+	}
+
+	// Ensure that the remaining share public keys are consistent with the verification vector.
+	if len(ids) > len(points) {
+		vvec := make([]e2types.PublicKey, len(verificationVector))
+		for i := range verificationVector {
+			var err error
+			vvec[i], err = e2types.BLSPublicKeyFromBytes(verificationVector[i])
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to obtain verification vector")
+			}
+		}
+		for _, id := range ids[len(points):] {
+			sharePubKey, err := evaluateVerificationVector(vvec, id)
+			if err != nil {
+				return nil, err
+			}
+			if !bytes.Equal(sharePubKey, sharePublicKeys[id]) {
+				return nil, fmt.Errorf("share public key for participant %d is inconsistent with the others", id)
+			}
+		}
+	}
+
+	return verificationVector, nil
+}
+
+// lagrangeBasisCoefficients returns the coefficients, lowest order first, of the Lagrange basis
+// polynomial that is 1 at xs[i] and 0 at the other points.
+func lagrangeBasisCoefficients(xs []bls.Fr, i int) []bls.Fr {
+	res := make([]bls.Fr, len(xs))
+	res[0].SetInt64(1)
+	var denominator bls.Fr
+	denominator.SetInt64(1)
+	degree := 0
+	for m := range xs {
+		if m == i {
+			continue
+		}
+		// Multiply the polynomial by (x - xs[m]).
+		var term bls.Fr
+		for k := degree + 1; k > 0; k-- {
+			bls.FrMul(&term, &res[k], &xs[m])
+			bls.FrSub(&res[k], &res[k-1], &term)
+		}
+		bls.FrMul(&term, &res[0], &xs[m])
+		bls.FrNeg(&res[0], &term)
+		degree++
+
+		var diff bls.Fr
+		bls.FrSub(&diff, &xs[i], &xs[m])
+		bls.FrMul(&denominator, &denominator, &diff)
+	}
+	var inverse bls.Fr
+	bls.FrInv(&inverse, &denominator)
+	for k := range res {
+		bls.FrMul(&res[k], &res[k], &inverse)
+	}
+
+	return res
+}
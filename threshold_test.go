@@ -0,0 +1,265 @@
+// Copyright © 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	bls "github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	scratch "github.com/wealdtech/go-eth2-wallet-store-scratch"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+func TestThresholdPolicy(t *testing.T) {
+	ctx := context.Background()
+	vvec := [][]byte{
+		_byteArray("a0633864987df6f7a0f40fbecbe0d15fe5317c00adccc0b816266bcf3d3d1ab6a365b3d79461b5da5a8ea09e37644731"),
+		_byteArray("a7430ae4717f511e473ed87a6510c5dfdbf289b7c7c4e083270da487aa146b0291ee701e0c38033aa157a612b8fd488b"),
+		_byteArray("b5e95fbcf45c9f2730f04abab22672dddca4b10e32467ab8522d7d153a382c9656d28268b5e75361e4e39a5d12513d8c"),
+	}
+
+	// participants returns n participants.
+	participants := func(n int) map[uint64]string {
+		res := make(map[uint64]string, n)
+		for i := 1; i <= n; i++ {
+			res[uint64(i)] = fmt.Sprintf("signer%d:443", i)
+		}
+
+		return res
+	}
+
+	tests := []struct {
+		name             string
+		policy           []distributed.Option
+		signingThreshold uint32
+		participants     int
+		err              string
+	}{
+		{
+			name:             "DefaultMajority",
+			signingThreshold: 3,
+			participants:     5,
+		},
+		{
+			name:             "DefaultMinority",
+			signingThreshold: 2,
+			participants:     5,
+			err:              "invalid signing threshold:participant ratio",
+		},
+		{
+			name:             "HonestMajorityMajority",
+			policy:           []distributed.Option{distributed.WithThresholdPolicy(distributed.ThresholdHonestMajority)},
+			signingThreshold: 2,
+			participants:     3,
+		},
+		{
+			name:             "HonestMajorityHalf",
+			policy:           []distributed.Option{distributed.WithThresholdPolicy(distributed.ThresholdHonestMajority)},
+			signingThreshold: 2,
+			participants:     4,
+			err:              "invalid signing threshold:participant ratio",
+		},
+		{
+			name:             "SupermajorityTwoThirds",
+			policy:           []distributed.Option{distributed.WithThresholdPolicy(distributed.ThresholdSupermajority)},
+			signingThreshold: 3,
+			participants:     4,
+		},
+		{
+			name:             "SupermajorityMajority",
+			policy:           []distributed.Option{distributed.WithThresholdPolicy(distributed.ThresholdSupermajority)},
+			signingThreshold: 3,
+			participants:     5,
+			err:              "invalid signing threshold:participant ratio",
+		},
+		{
+			name:             "AnyMinority",
+			policy:           []distributed.Option{distributed.WithThresholdPolicy(distributed.ThresholdAny)},
+			signingThreshold: 1,
+			participants:     3,
+		},
+		{
+			name:             "AnyTooHigh",
+			policy:           []distributed.Option{distributed.WithThresholdPolicy(distributed.ThresholdAny)},
+			signingThreshold: 3,
+			participants:     2,
+			err:              "signing threshold 3 exceeds number of participants 2",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			wallet, err := distributed.CreateWallet(ctx, "test wallet", scratch.New(), keystorev4.New(), test.policy...)
+			require.NoError(t, err)
+			require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+			_, err = wallet.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+				"Account 1",
+				_byteArray("01e748d098d3bcb477d636f19d510399ae18205fadf9814ee67052f88c1f77c0"),
+				test.signingThreshold,
+				vvec[:test.signingThreshold],
+				participants(test.participants),
+				[]byte("pass"))
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+
+	t.Run("Verify", func(t *testing.T) {
+		store := scratch.New()
+		encryptor := keystorev4.New()
+		wallet, err := distributed.CreateWallet(ctx, "test wallet", store, encryptor,
+			distributed.WithThresholdPolicy(distributed.ThresholdAny))
+		require.NoError(t, err)
+		require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+		_, err = wallet.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+			"Account 1", _byteArray("01e748d098d3bcb477d636f19d510399ae18205fadf9814ee67052f88c1f77c0"), 1, vvec[:1], participants(3), []byte("pass"))
+		require.NoError(t, err)
+
+		// The account is readable by a wallet with a stricter policy, which reports it on verification.
+		wallet, err = distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+		require.NoError(t, err)
+		_, err = wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 1")
+		require.NoError(t, err)
+		report, err := wallet.(distributed.WalletVerifier).Verify(ctx)
+		require.NoError(t, err)
+		require.Len(t, report.Problems, 1)
+		require.Equal(t, distributed.ProblemInvalidThreshold, report.Problems[0].Kind)
+		require.Equal(t, "signing threshold 1 for 3 participants does not satisfy the wallet's threshold policy", report.Problems[0].Description)
+	})
+}
+
+// generateShares generates a distributed key with the given threshold, returning its
+// verification vector and the secret key share for each participant.
+func generateShares(t *testing.T, signingThreshold int, participantIDs ...uint64) ([][]byte, map[uint64][]byte) {
+	t.Helper()
+
+	msk := make([]bls.SecretKey, signingThreshold)
+	for i := range msk {
+		msk[i].SetByCSPRNG()
+	}
+	mpk := bls.GetMasterPublicKey(msk)
+	verificationVector := make([][]byte, len(mpk))
+	for i := range mpk {
+		verificationVector[i] = mpk[i].Serialize()
+	}
+
+	shares := make(map[uint64][]byte, len(participantIDs))
+	for _, participantID := range participantIDs {
+		var id bls.ID
+		require.NoError(t, id.SetDecString(fmt.Sprintf("%d", participantID)))
+		var share bls.SecretKey
+		require.NoError(t, share.Set(msk, &id))
+		shares[participantID] = share.Serialize()
+	}
+
+	return verificationVector, shares
+}
+
+func TestParticipantID(t *testing.T) {
+	ctx := context.Background()
+	wallet, err := distributed.CreateWallet(ctx, "test wallet", scratch.New(), keystorev4.New())
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+	importer := wallet.(distributed.WalletDistributedAccountWithParticipantsImporter)
+
+	vvec, shares := generateShares(t, 2, 1, 2, 3)
+	endpoints := map[uint64]string{1: "signer1:443", 2: "signer2:443", 3: "signer3:443"}
+
+	// From the verification vector.
+	account, err := wallet.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+		"Account 1", shares[2], 2, vvec, endpoints, []byte("pass"))
+	require.NoError(t, err)
+	participantID, err := account.(distributed.AccountParticipantIDProvider).ParticipantID()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), participantID)
+
+	// From the participant descriptors, which take precedence.
+	sharePubKey := account.PublicKey()
+	account, err = importer.ImportDistributedAccountWithParticipants(ctx,
+		"Account 2", shares[2], 2, vvec, map[uint64]*distributed.Participant{
+			1: {Endpoint: "signer1:443"},
+			2: {Endpoint: "signer2:443"},
+			3: {Endpoint: "signer3:443", SharePublicKey: sharePubKey},
+		}, []byte("pass"))
+	require.NoError(t, err)
+	participantID, err = account.(distributed.AccountParticipantIDProvider).ParticipantID()
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), participantID)
+
+	// Share from a different key.
+	_, otherShares := generateShares(t, 2, 1)
+	account, err = wallet.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+		"Account 3", otherShares[1], 2, vvec, endpoints, []byte("pass"))
+	require.NoError(t, err)
+	_, err = account.(distributed.AccountParticipantIDProvider).ParticipantID()
+	require.EqualError(t, err, "account public key does not match any participant")
+}
+
+// sharePublicKeys returns the public keys of secret key shares.
+func sharePublicKeys(t *testing.T, shares map[uint64][]byte) map[uint64][]byte {
+	t.Helper()
+
+	res := make(map[uint64][]byte, len(shares))
+	for id, share := range shares {
+		var secretKey bls.SecretKey
+		require.NoError(t, secretKey.Deserialize(share))
+		res[id] = secretKey.GetPublicKey().Serialize()
+	}
+
+	return res
+}
+
+func TestVerificationVectorFromSharePublicKeys(t *testing.T) {
+	vvec, shares := generateShares(t, 3, 1, 2, 4, 7)
+	pubKeys := sharePublicKeys(t, shares)
+
+	res, err := distributed.VerificationVectorFromSharePublicKeys(3, pubKeys)
+	require.NoError(t, err)
+	require.Equal(t, vvec, res)
+
+	// Any threshold of shares recovers the same verification vector.
+	delete(pubKeys, 1)
+	res, err = distributed.VerificationVectorFromSharePublicKeys(3, pubKeys)
+	require.NoError(t, err)
+	require.Equal(t, vvec, res)
+
+	_, err = distributed.VerificationVectorFromSharePublicKeys(0, pubKeys)
+	require.EqualError(t, err, "signing threshold must be at least 1")
+	_, err = distributed.VerificationVectorFromSharePublicKeys(4, pubKeys)
+	require.EqualError(t, err, "3 share public keys supplied but 4 required")
+	_, err = distributed.VerificationVectorFromSharePublicKeys(3, map[uint64][]byte{0: pubKeys[2], 2: pubKeys[2], 4: pubKeys[4]})
+	require.EqualError(t, err, "participant ID 0 invalid")
+	_, err = distributed.VerificationVectorFromSharePublicKeys(2, map[uint64][]byte{2: []byte{0x01}, 4: pubKeys[4]})
+	require.ErrorContains(t, err, "invalid share public key for participant 2")
+
+	// IDs beyond the range of a signed integer are handled.
+	largeVvec, largeShares := generateShares(t, 2, 1<<63, 1<<64-1)
+	res, err = distributed.VerificationVectorFromSharePublicKeys(2, sharePublicKeys(t, largeShares))
+	require.NoError(t, err)
+	require.Equal(t, largeVvec, res)
+
+	// A share from a different account is detected.
+	_, otherShares := generateShares(t, 3, 1)
+	pubKeys[1] = sharePublicKeys(t, otherShares)[1]
+	_, err = distributed.VerificationVectorFromSharePublicKeys(3, pubKeys)
+	require.EqualError(t, err, "share public key for participant 7 is inconsistent with the others")
+}
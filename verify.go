@@ -0,0 +1,536 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+	"github.com/wealdtech/go-indexer"
+)
+
+// ProblemKind is the kind of a problem found when verifying a wallet.
+type ProblemKind int
+
+const (
+	// ProblemIndexUnreadable is reported when the accounts index cannot be read.
+	ProblemIndexUnreadable ProblemKind = iota
+	// ProblemIndexMissingAccount is reported when the accounts index refers to an account that is not stored.
+	ProblemIndexMissingAccount
+	// ProblemIndexMismatch is reported when an accounts index entry disagrees with the stored account, or is duplicated.
+	ProblemIndexMismatch
+	// ProblemOrphanedAccount is reported when a stored account is not present in the accounts index.
+	ProblemOrphanedAccount
+	// ProblemUnreadableAccount is reported when a stored account cannot be read.
+	ProblemUnreadableAccount
+	// ProblemDuplicateAccountName is reported when more than one stored account has the same name.
+	ProblemDuplicateAccountName
+	// ProblemInvalidThreshold is reported when an account's signing threshold disagrees with its
+	// verification vector or participants.
+	ProblemInvalidThreshold
+	// ProblemBatchUnreadable is reported when the batch cannot be read.
+	ProblemBatchUnreadable
+	// ProblemBatchMismatch is reported when the batch disagrees with the stored accounts.
+	ProblemBatchMismatch
+	// ProblemUndecryptable is reported when a secret key cannot be decrypted with the supplied passphrases.
+	ProblemUndecryptable
+	// ProblemKeyMismatch is reported when a decrypted secret key does not correspond to its public key.
+	ProblemKeyMismatch
+)
+
+// String provides a string representation of the problem kind.
+func (k ProblemKind) String() string {
+	switch k {
+	case ProblemIndexUnreadable:
+		return "index unreadable"
+	case ProblemIndexMissingAccount:
+		return "index missing account"
+	case ProblemIndexMismatch:
+		return "index mismatch"
+	case ProblemOrphanedAccount:
+		return "orphaned account"
+	case ProblemUnreadableAccount:
+		return "unreadable account"
+	case ProblemDuplicateAccountName:
+		return "duplicate account name"
+	case ProblemInvalidThreshold:
+		return "invalid threshold"
+	case ProblemBatchUnreadable:
+		return "batch unreadable"
+	case ProblemBatchMismatch:
+		return "batch mismatch"
+	case ProblemUndecryptable:
+		return "undecryptable"
+	case ProblemKeyMismatch:
+		return "key mismatch"
+	default:
+		return "unknown"
+	}
+}
+
+// VerifyProblem is a problem found when verifying a wallet.
+type VerifyProblem struct {
+	// Kind is the kind of the problem.
+	Kind ProblemKind
+	// AccountID is the ID of the account with the problem, if known.
+	AccountID uuid.UUID
+	// AccountName is the name of the account with the problem, if known.
+	AccountName string
+	// Description describes the problem.
+	Description string
+	// Repaired is true if the problem has been repaired.
+	Repaired bool
+}
+
+// VerifyReport reports the results of verifying a wallet.
+type VerifyReport struct {
+	// Accounts is the number of accounts in the store.
+	Accounts int
+	// Problems contains the problems found.
+	Problems []*VerifyProblem
+}
+
+// OK returns true if the wallet has no outstanding problems.
+func (r *VerifyReport) OK() bool {
+	for _, problem := range r.Problems {
+		if !problem.Repaired {
+			return false
+		}
+	}
+
+	return true
+}
+
+// verifyOptions are the options for verifying a wallet.
+type verifyOptions struct {
+	decrypt     bool
+	passphrases [][]byte
+	repair      bool
+}
+
+// VerifyOption gives options to Verify.
+type VerifyOption interface {
+	apply(*verifyOptions)
+}
+
+type verifyOptionFunc func(*verifyOptions)
+
+func (f verifyOptionFunc) apply(o *verifyOptions) {
+	f(o)
+}
+
+// WithVerifyPassphrases decrypts the secret keys of accounts and the batch with the supplied
+// passphrases, and checks that they correspond to their public keys.
+// Accounts protected by a key wrapper do not require passphrases.
+func WithVerifyPassphrases(passphrases ...[]byte) VerifyOption {
+	return verifyOptionFunc(func(o *verifyOptions) {
+		o.decrypt = true
+		o.passphrases = append(o.passphrases, passphrases...)
+	})
+}
+
+// WithRepair repairs problems where possible.  At current this rebuilds the accounts index
+// from the stored accounts.  The wallet must be unlocked.
+func WithRepair() VerifyOption {
+	return verifyOptionFunc(func(o *verifyOptions) {
+		o.repair = true
+	})
+}
+
+// WalletVerifier is the interface for wallets that can verify their integrity.
+type WalletVerifier interface {
+	// Verify checks the integrity of the wallet and reports any problems found.
+	Verify(ctx context.Context, opts ...VerifyOption) (*VerifyReport, error)
+}
+
+// verifier holds the state of a wallet verification.
+type verifier struct {
+	w        *wallet
+	options  *verifyOptions
+	report   *VerifyReport
+	accounts map[uuid.UUID]*account
+	// identities contains the names of all stored accounts with known IDs, including unreadable accounts.
+	identities map[uuid.UUID]string
+}
+
+// Verify checks the integrity of the wallet and reports any problems found.
+// An error is returned only if the verification itself could not be carried out.
+func (w *wallet) Verify(ctx context.Context, opts ...VerifyOption) (*VerifyReport, error) {
+	options := &verifyOptions{}
+	for _, o := range opts {
+		o.apply(options)
+	}
+	if options.repair {
+		unlocked, err := w.IsUnlocked(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to obtain wallet lock status")
+		}
+		if !unlocked {
+			return nil, newLockedError("wallet", w.name, "wallet must be unlocked to repair")
+		}
+	}
+
+	v := &verifier{
+		w:          w,
+		options:    options,
+		report:     &VerifyReport{Problems: make([]*VerifyProblem, 0)},
+		accounts:   make(map[uuid.UUID]*account),
+		identities: make(map[uuid.UUID]string),
+	}
+
+	v.verifyAccounts(ctx)
+	indexOK := v.verifyIndex()
+	v.verifyBatch(ctx)
+
+	if options.repair && !indexOK {
+		if err := v.repairIndex(); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(v.report.Problems, func(i, j int) bool {
+		pi := v.report.Problems[i]
+		pj := v.report.Problems[j]
+		if pi.Kind != pj.Kind {
+			return pi.Kind < pj.Kind
+		}
+		if pi.AccountName != pj.AccountName {
+			return pi.AccountName < pj.AccountName
+		}
+
+		return pi.AccountID.String() < pj.AccountID.String()
+	})
+
+	return v.report, nil
+}
+
+// problem adds a problem to the report.
+func (v *verifier) problem(kind ProblemKind, id uuid.UUID, name string, format string, args ...any) {
+	v.report.Problems = append(v.report.Problems, &VerifyProblem{
+		Kind:        kind,
+		AccountID:   id,
+		AccountName: name,
+		Description: fmt.Sprintf(format, args...),
+	})
+}
+
+// verifyAccounts checks the individual accounts in the store.
+func (v *verifier) verifyAccounts(ctx context.Context) {
+	names := make(map[string]bool)
+	for data := range v.w.store.RetrieveAccounts(v.w.id) {
+		v.report.Accounts++
+		a, err := deserializeAccount(v.w, data)
+		if err != nil {
+			id, name := accountIdentity(data)
+			if id != uuid.Nil {
+				v.identities[id] = name
+			}
+			v.problem(ProblemUnreadableAccount, id, name, "%v", err)
+
+			continue
+		}
+		v.accounts[a.id] = a
+		v.identities[a.id] = a.name
+		if names[a.name] {
+			v.problem(ProblemDuplicateAccountName, a.id, a.name, "account name %q is used by more than one account", a.name)
+		}
+		names[a.name] = true
+
+		if uint32(len(a.verificationVector)) != a.signingThreshold {
+			v.problem(ProblemInvalidThreshold, a.id, a.name,
+				"signing threshold %d does not match verification vector length %d", a.signingThreshold, len(a.verificationVector))
+		}
+		if a.signingThreshold > uint32(len(a.participants)) {
+			v.problem(ProblemInvalidThreshold, a.id, a.name,
+				"signing threshold %d exceeds number of participants %d", a.signingThreshold, len(a.participants))
+		} else if !v.w.thresholdPolicy.allows(a.signingThreshold, len(a.participants)) {
+			v.problem(ProblemInvalidThreshold, a.id, a.name,
+				"signing threshold %d for %d participants does not satisfy the wallet's threshold policy", a.signingThreshold, len(a.participants))
+		}
+
+		if v.options.decrypt && !a.watchOnly {
+			v.verifyAccountKey(ctx, a)
+		}
+	}
+}
+
+// verifyAccountKey checks that an account's secret key can be decrypted and corresponds to its public key.
+func (v *verifier) verifyAccountKey(ctx context.Context, a *account) {
+	var secretKey []byte
+	var err error
+	if a.keyWrapper != nil {
+		secretKey, err = a.decryptSecret(ctx, nil)
+	} else {
+		err = errors.New("no passphrases supplied")
+		for _, passphrase := range v.options.passphrases {
+			if secretKey, err = a.decryptSecret(ctx, passphrase); err == nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		v.problem(ProblemUndecryptable, a.id, a.name, "secret key could not be decrypted")
+
+		return
+	}
+
+	privateKey, err := e2types.BLSPrivateKeyFromBytes(secretKey)
+	if err != nil {
+		v.problem(ProblemKeyMismatch, a.id, a.name, "invalid secret key: %v", err)
+
+		return
+	}
+	if !bytes.Equal(privateKey.PublicKey().Marshal(), a.publicKey.Marshal()) {
+		v.problem(ProblemKeyMismatch, a.id, a.name, "secret key does not correspond to public key")
+	}
+}
+
+// verifyIndex checks the stored accounts index against the stored accounts.
+// It returns false if any problems were found.
+func (v *verifier) verifyIndex() bool {
+	problems := len(v.report.Problems)
+
+	serializedIndex, err := v.w.store.RetrieveAccountsIndex(v.w.id)
+	if err != nil {
+		v.problem(ProblemIndexUnreadable, uuid.Nil, "", "failed to retrieve accounts index: %v", err)
+
+		return false
+	}
+	entries := make([]*indexEntry, 0)
+	if err := json.Unmarshal(serializedIndex, &entries); err != nil {
+		v.problem(ProblemIndexUnreadable, uuid.Nil, "", "failed to unmarshal accounts index: %v", err)
+
+		return false
+	}
+
+	indexIDs := make(map[uuid.UUID]bool, len(entries))
+	indexNames := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if indexIDs[entry.ID] {
+			v.problem(ProblemIndexMismatch, entry.ID, entry.Name, "account ID %s is present more than once in the index", entry.ID)
+		}
+		if indexNames[entry.Name] {
+			v.problem(ProblemIndexMismatch, entry.ID, entry.Name, "account name %q is present more than once in the index", entry.Name)
+		}
+		indexIDs[entry.ID] = true
+		indexNames[entry.Name] = true
+
+		name, exists := v.identities[entry.ID]
+		switch {
+		case !exists:
+			v.problem(ProblemIndexMissingAccount, entry.ID, entry.Name, "index refers to account that is not stored")
+		case name != entry.Name:
+			v.problem(ProblemIndexMismatch, entry.ID, name, "index has name %q for account", entry.Name)
+		}
+	}
+
+	for id, name := range v.identities {
+		if !indexIDs[id] {
+			v.problem(ProblemOrphanedAccount, id, name, "account is not present in the index")
+		}
+	}
+
+	return len(v.report.Problems) == problems
+}
+
+// verifyBatch checks the batch, if present, against the stored accounts.
+func (v *verifier) verifyBatch(ctx context.Context) {
+	batchRetriever, isBatchRetriever := v.w.store.(e2wtypes.BatchRetriever)
+	if !isBatchRetriever {
+		return
+	}
+	serializedBatch, err := batchRetriever.RetrieveBatch(ctx, v.w.id)
+	if err != nil {
+		// Stores do not distinguish between a missing batch and a failure to retrieve it,
+		// so assume that there is no batch.
+		return
+	}
+	b := &batch{
+		keyWrapper: v.w.keyWrapper,
+	}
+	if err := json.Unmarshal(serializedBatch, b); err != nil {
+		v.problem(ProblemBatchUnreadable, uuid.Nil, "", "failed to unmarshal batch: %v", err)
+
+		return
+	}
+	if len(b.entries) == 0 {
+		// An empty batch is equivalent to no batch.
+		return
+	}
+
+	batched := make(map[uuid.UUID]bool, len(b.entries))
+	for _, entry := range b.entries {
+		batched[entry.id] = true
+		a, exists := v.accounts[entry.id]
+		if !exists {
+			if _, known := v.identities[entry.id]; !known {
+				v.problem(ProblemBatchMismatch, entry.id, entry.name, "batch contains account that is not stored")
+			}
+
+			continue
+		}
+		if difference := batchEntryDifference(entry, a); difference != "" {
+			v.problem(ProblemBatchMismatch, a.id, a.name, "batch entry has different %s", difference)
+		}
+	}
+	for id, a := range v.accounts {
+		if !batched[id] {
+			v.problem(ProblemBatchMismatch, a.id, a.name, "account is not present in batch")
+		}
+	}
+
+	if v.options.decrypt {
+		v.verifyBatchKeys(ctx, b)
+	}
+}
+
+// batchEntryDifference returns the name of the first field in which a batch entry differs from
+// its account, or an empty string if they match.
+func batchEntryDifference(entry *batchEntry, a *account) string {
+	if entry.name != a.name {
+		return "name"
+	}
+	if !bytes.Equal(entry.pubkey, a.publicKey.Marshal()) {
+		return "public key"
+	}
+	if entry.signingThreshold != a.signingThreshold {
+		return "signing threshold"
+	}
+	if entry.watchOnly != a.watchOnly {
+		return "watch-only flag"
+	}
+	verificationVector := a.storedVerificationVector()
+	if len(entry.verificationVector) != len(verificationVector) {
+		return "verification vector"
+	}
+	for i := range entry.verificationVector {
+		if !bytes.Equal(entry.verificationVector[i], verificationVector[i]) {
+			return "verification vector"
+		}
+	}
+	if len(entry.participants) != len(a.participants) {
+		return "participants"
+	}
+	for k, v := range a.participants {
+		if !entry.participants[k].equal(v) {
+			return "participants"
+		}
+	}
+	if len(entry.metadata) != len(a.metadata) {
+		return "metadata"
+	}
+	for k, v := range a.metadata {
+		if value, exists := entry.metadata[k]; !exists || value != v {
+			return "metadata"
+		}
+	}
+
+	return ""
+}
+
+// verifyBatchKeys checks that the batch's secret keys can be decrypted and correspond to their public keys.
+func (v *verifier) verifyBatchKeys(ctx context.Context, b *batch) {
+	var secretKeys []byte
+	var err error
+	if b.keyWrapper != nil {
+		secretKeys, err = b.decrypt(ctx, nil)
+	} else {
+		err = errors.New("no passphrases supplied")
+		for _, passphrase := range v.options.passphrases {
+			if secretKeys, err = b.decrypt(ctx, passphrase); err == nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		v.problem(ProblemUndecryptable, uuid.Nil, "", "batch could not be decrypted")
+
+		return
+	}
+
+	for i, entry := range b.entries {
+		if entry.watchOnly {
+			continue
+		}
+		privateKey, err := e2types.BLSPrivateKeyFromBytes(secretKeys[i*32 : (i+1)*32])
+		if err != nil {
+			v.problem(ProblemKeyMismatch, entry.id, entry.name, "invalid batch secret key: %v", err)
+
+			continue
+		}
+		if !bytes.Equal(privateKey.PublicKey().Marshal(), entry.pubkey) {
+			v.problem(ProblemKeyMismatch, entry.id, entry.name, "batch secret key does not correspond to public key")
+		}
+	}
+}
+
+// repairIndex rebuilds the accounts index from the stored accounts.
+// Unreadable accounts are included if their ID and name are known, so that they remain visible.
+// Where more than one account has the same name only one of them is included.
+func (v *verifier) repairIndex() error {
+	ids := make([]uuid.UUID, 0, len(v.identities))
+	for id := range v.identities {
+		ids = append(ids, id)
+	}
+	// Sort to make the choice between accounts with duplicate names deterministic.
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].String() < ids[j].String()
+	})
+
+	index := indexer.New()
+	for _, id := range ids {
+		name := v.identities[id]
+		if name == "" || index.NameKnown(name) {
+			continue
+		}
+		index.Add(id, name)
+	}
+
+	v.w.mutex.Lock()
+	defer v.w.mutex.Unlock()
+	v.w.index = index
+	if err := v.w.storeAccountsIndex(); err != nil {
+		return errors.Wrap(err, "failed to repair accounts index")
+	}
+
+	// The rebuilt index leaves out accounts without names and all but one of the accounts that
+	// share a name, so problems with those accounts remain.
+	indexed := func(id uuid.UUID) bool {
+		storedName, stored := v.identities[id]
+		if !stored {
+			return true
+		}
+		name, exists := index.Name(id)
+
+		return exists && name == storedName
+	}
+	for _, problem := range v.report.Problems {
+		switch problem.Kind {
+		case ProblemIndexUnreadable, ProblemIndexMissingAccount:
+			problem.Repaired = true
+		case ProblemIndexMismatch, ProblemOrphanedAccount:
+			problem.Repaired = indexed(problem.AccountID)
+		default:
+		}
+	}
+
+	return nil
+}
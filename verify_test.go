@@ -0,0 +1,222 @@
+// Copyright © 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	scratch "github.com/wealdtech/go-eth2-wallet-store-scratch"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	encryptor := keystorev4.New()
+	participants := map[uint64]string{1: "foo", 2: "bar", 3: "baz"}
+
+	// setup creates a wallet with two accounts.
+	setup := func(t *testing.T) (e2wtypes.Store, e2wtypes.Wallet, e2wtypes.Account, e2wtypes.Account) {
+		t.Helper()
+		store := scratch.New()
+		wallet, err := distributed.CreateWallet(ctx, "test wallet", store, encryptor)
+		require.NoError(t, err)
+		require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+		importer := wallet.(e2wtypes.WalletDistributedAccountImporter)
+		account1, err := importer.ImportDistributedAccount(ctx,
+			"Account 1",
+			_byteArray("01e748d098d3bcb477d636f19d510399ae18205fadf9814ee67052f88c1f77c0"),
+			3,
+			[][]byte{
+				_byteArray("a0633864987df6f7a0f40fbecbe0d15fe5317c00adccc0b816266bcf3d3d1ab6a365b3d79461b5da5a8ea09e37644731"),
+				_byteArray("a7430ae4717f511e473ed87a6510c5dfdbf289b7c7c4e083270da487aa146b0291ee701e0c38033aa157a612b8fd488b"),
+				_byteArray("b5e95fbcf45c9f2730f04abab22672dddca4b10e32467ab8522d7d153a382c9656d28268b5e75361e4e39a5d12513d8c"),
+			},
+			participants,
+			[]byte("pass"))
+		require.NoError(t, err)
+		account2, err := importer.ImportDistributedAccount(ctx,
+			"Account 2",
+			_byteArray("376880b8079dca3bbd06c93958b5208929cbc169c9ce4caf8731be10e94f710e"),
+			3,
+			[][]byte{
+				_byteArray("a0633864987df6f7a0f40fbecbe0d15fe5317c00adccc0b816266bcf3d3d1ab6a365b3d79461b5da5a8ea09e37644731"),
+				_byteArray("b13d6e14cce66b3827b816c974e8f52a76e86611de58bbcdac116a9e97b00240a29714646202a65ae72df480bdfa5329"),
+				_byteArray("81a00aee312320aa82316ea14b6615eb56f531ecdabc1effca2a55d0282f3c2463124b792da5ec0207d16119360bd896"),
+			},
+			participants,
+			[]byte("pass"))
+		require.NoError(t, err)
+
+		return store, wallet, account1, account2
+	}
+
+	// kinds returns the kinds of problems in a report.
+	kinds := func(report *distributed.VerifyReport) []distributed.ProblemKind {
+		res := make([]distributed.ProblemKind, 0, len(report.Problems))
+		for _, problem := range report.Problems {
+			res = append(res, problem.Kind)
+		}
+
+		return res
+	}
+
+	// modify replaces text in a stored account.
+	modify := func(t *testing.T, store e2wtypes.Store, wallet e2wtypes.Wallet, account e2wtypes.Account, old string, new string) {
+		t.Helper()
+		data, err := store.RetrieveAccount(wallet.ID(), account.ID())
+		require.NoError(t, err)
+		require.Contains(t, string(data), old)
+		data = []byte(strings.Replace(string(data), old, new, 1))
+		require.NoError(t, store.StoreAccount(wallet.ID(), account.ID(), data))
+	}
+
+	t.Run("Good", func(t *testing.T) {
+		_, wallet, _, _ := setup(t)
+		require.NoError(t, wallet.(e2wtypes.WalletBatchCreator).BatchWallet(ctx, []string{"pass"}, "batch"))
+		report, err := wallet.(distributed.WalletVerifier).Verify(ctx,
+			distributed.WithVerifyPassphrases([]byte("pass"), []byte("batch")))
+		require.NoError(t, err)
+		require.Equal(t, 2, report.Accounts)
+		require.Empty(t, report.Problems)
+		require.True(t, report.OK())
+	})
+
+	t.Run("OrphanedAccount", func(t *testing.T) {
+		store, wallet, account1, account2 := setup(t)
+		index := fmt.Sprintf(`[{"uuid":"%s","name":"Account 1"},{"uuid":"%s","name":"Account 3"}]`, account1.ID(), uuid.New())
+		require.NoError(t, store.StoreAccountsIndex(wallet.ID(), []byte(index)))
+
+		report, err := wallet.(distributed.WalletVerifier).Verify(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []distributed.ProblemKind{distributed.ProblemIndexMissingAccount, distributed.ProblemOrphanedAccount}, kinds(report))
+		require.Equal(t, "Account 3", report.Problems[0].AccountName)
+		require.Equal(t, account2.ID(), report.Problems[1].AccountID)
+		require.False(t, report.OK())
+
+		// Repair, and confirm the orphaned account is available again.
+		report, err = wallet.(distributed.WalletVerifier).Verify(ctx, distributed.WithRepair())
+		require.NoError(t, err)
+		require.Len(t, report.Problems, 2)
+		require.True(t, report.OK())
+		wallet, err = distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+		require.NoError(t, err)
+		_, err = wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 2")
+		require.NoError(t, err)
+		report, err = wallet.(distributed.WalletVerifier).Verify(ctx)
+		require.NoError(t, err)
+		require.Empty(t, report.Problems)
+	})
+
+	t.Run("RepairLocked", func(t *testing.T) {
+		store, wallet, _, _ := setup(t)
+		wallet, err := distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+		require.NoError(t, err)
+		_, err = wallet.(distributed.WalletVerifier).Verify(ctx, distributed.WithRepair())
+		require.ErrorIs(t, err, distributed.ErrLocked)
+	})
+
+	t.Run("IndexMismatch", func(t *testing.T) {
+		store, wallet, account1, account2 := setup(t)
+		index := fmt.Sprintf(`[{"uuid":"%s","name":"Account 1"},{"uuid":"%s","name":"Account 3"}]`, account1.ID(), account2.ID())
+		require.NoError(t, store.StoreAccountsIndex(wallet.ID(), []byte(index)))
+
+		report, err := wallet.(distributed.WalletVerifier).Verify(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []distributed.ProblemKind{distributed.ProblemIndexMismatch}, kinds(report))
+		require.Equal(t, `index has name "Account 3" for account`, report.Problems[0].Description)
+	})
+
+	t.Run("RepairUnnamedAccount", func(t *testing.T) {
+		store, wallet, _, account2 := setup(t)
+		modify(t, store, wallet, account2, `"name":"Account 2"`, `"name":""`)
+
+		// The account cannot be added to the rebuilt index, so its index problem remains.
+		report, err := wallet.(distributed.WalletVerifier).Verify(ctx, distributed.WithRepair())
+		require.NoError(t, err)
+		require.Equal(t, []distributed.ProblemKind{distributed.ProblemIndexMismatch}, kinds(report))
+		require.Equal(t, account2.ID(), report.Problems[0].AccountID)
+		require.False(t, report.Problems[0].Repaired)
+		require.False(t, report.OK())
+	})
+
+	t.Run("UnreadableAccount", func(t *testing.T) {
+		store, wallet, _, account2 := setup(t)
+		modify(t, store, wallet, account2, `"pubkey":"`, `"pubkey":"00`)
+
+		report, err := wallet.(distributed.WalletVerifier).Verify(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []distributed.ProblemKind{distributed.ProblemUnreadableAccount}, kinds(report))
+		require.Equal(t, account2.ID(), report.Problems[0].AccountID)
+		require.Equal(t, "Account 2", report.Problems[0].AccountName)
+	})
+
+	t.Run("InvalidThreshold", func(t *testing.T) {
+		store, wallet, account1, _ := setup(t)
+		modify(t, store, wallet, account1, `"signing_threshold":3`, `"signing_threshold":2`)
+
+		report, err := wallet.(distributed.WalletVerifier).Verify(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []distributed.ProblemKind{distributed.ProblemInvalidThreshold}, kinds(report))
+		require.Equal(t, "signing threshold 2 does not match verification vector length 3", report.Problems[0].Description)
+	})
+
+	t.Run("BatchMismatch", func(t *testing.T) {
+		_, wallet, _, _ := setup(t)
+		require.NoError(t, wallet.(e2wtypes.WalletBatchCreator).BatchWallet(ctx, []string{"pass"}, "batch"))
+		account3, err := wallet.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+			"Account 3",
+			_byteArray("0a660b6379a25e095590edeb7688a8506653e58310336efcfc98a9e34e485faa"),
+			3,
+			[][]byte{
+				_byteArray("b5d7a0bffb025cca463898a7ff56a613402e40d43ee293b45ee9f7811c17047a43273a0cf843d75995d1150140f6b2ef"),
+				_byteArray("b82aa608cd126ff401a458be48944dc84c999cce084fd6c8da816e5548964fc1d71b05d52c528e5ce3657778c573cc31"),
+				_byteArray("a4da59f92bea77d3950cb578c2b8c8ee65e12040e9efd4c82cb4b0ac6138fef5d8f4bb53971bafdf6285f22f91b22b2f"),
+			},
+			participants,
+			[]byte("pass"))
+		require.NoError(t, err)
+		require.NotNil(t, account3)
+
+		report, err := wallet.(distributed.WalletVerifier).Verify(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []distributed.ProblemKind{distributed.ProblemBatchMismatch}, kinds(report))
+		require.Equal(t, "account is not present in batch", report.Problems[0].Description)
+	})
+
+	t.Run("Undecryptable", func(t *testing.T) {
+		_, wallet, _, _ := setup(t)
+		report, err := wallet.(distributed.WalletVerifier).Verify(ctx, distributed.WithVerifyPassphrases([]byte("wrong")))
+		require.NoError(t, err)
+		require.Equal(t, []distributed.ProblemKind{distributed.ProblemUndecryptable, distributed.ProblemUndecryptable}, kinds(report))
+	})
+
+	t.Run("KeyMismatch", func(t *testing.T) {
+		store, wallet, account1, account2 := setup(t)
+		modify(t, store, wallet, account1,
+			fmt.Sprintf(`"pubkey":"%x"`, account1.PublicKey().Marshal()),
+			fmt.Sprintf(`"pubkey":"%x"`, account2.PublicKey().Marshal()))
+
+		report, err := wallet.(distributed.WalletVerifier).Verify(ctx, distributed.WithVerifyPassphrases([]byte("pass")))
+		require.NoError(t, err)
+		require.Equal(t, []distributed.ProblemKind{distributed.ProblemKeyMismatch}, kinds(report))
+		require.Equal(t, "Account 1", report.Problems[0].AccountName)
+	})
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
@@ -35,32 +36,50 @@ const (
 
 // wallet contains the details of the wallet.
 type wallet struct {
-	id             uuid.UUID
-	name           string
-	version        uint
-	store          e2wtypes.Store
-	encryptor      e2wtypes.Encryptor
-	unlocked       bool
-	index          *indexer.Index
-	batch          *batch
-	accounts       map[uuid.UUID]*account
-	mutex          sync.Mutex
-	batchMutex     sync.Mutex
-	batchDecrypted bool
+	id                 uuid.UUID
+	name               string
+	version            uint
+	store              e2wtypes.Store
+	encryptor          e2wtypes.Encryptor
+	keyWrapper         KeyWrapper
+	unlocked           bool
+	index              *indexer.Index
+	batch              *batch
+	batchRetrieved     bool
+	batchErr           error
+	batchRetryAttempts int
+	batchRetryInterval time.Duration
+	thresholdPolicy    ThresholdPolicy
+	accounts           map[uuid.UUID]*account
+	mutex              sync.Mutex
+	batchMutex         sync.Mutex
+	batchDecrypted     bool
+	incomplete         bool
+	// publicKeys and compositePublicKeys index accounts by their keys, and are built on first use.
+	keysMutex           sync.RWMutex
+	keysIndexed         bool
+	publicKeys          map[string]uuid.UUID
+	compositePublicKeys map[string][]uuid.UUID
 }
 
 // newWallet creates a new wallet.
-func newWallet() (*wallet, error) {
+func newWallet(opts ...Option) (*wallet, error) {
+	options := parseOptions(opts)
+
 	id, err := uuid.NewRandom()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to generate ID")
 	}
 
 	return &wallet{
-		id:       id,
-		version:  version,
-		index:    indexer.New(),
-		accounts: make(map[uuid.UUID]*account),
+		id:                 id,
+		version:            version,
+		keyWrapper:         options.keyWrapper,
+		batchRetryAttempts: options.batchRetryAttempts,
+		batchRetryInterval: options.batchRetryInterval,
+		thresholdPolicy:    options.thresholdPolicy,
+		index:              indexer.New(),
+		accounts:           make(map[uuid.UUID]*account),
 	}, nil
 }
 
@@ -71,6 +90,9 @@ func (w *wallet) MarshalJSON() ([]byte, error) {
 	data["name"] = w.name
 	data["version"] = w.version
 	data["type"] = walletType
+	if w.incomplete {
+		data["incomplete"] = true
+	}
 
 	res, err := json.Marshal(data)
 	if err != nil {
@@ -128,19 +150,34 @@ func (w *wallet) UnmarshalJSON(data []byte) error {
 	} else {
 		return errors.New("wallet version missing")
 	}
+	if val, exists := v["incomplete"]; exists {
+		incomplete, ok := val.(bool)
+		if !ok {
+			return errors.New("wallet incomplete flag invalid")
+		}
+		w.incomplete = incomplete
+	}
 
 	return nil
 }
 
 // CreateWallet creates a new wallet with the given name and stores it in the provided store.
 // This will error if the wallet already exists.
-func CreateWallet(_ context.Context, name string, store e2wtypes.Store, encryptor e2wtypes.Encryptor) (e2wtypes.Wallet, error) {
+func CreateWallet(_ context.Context,
+	name string,
+	store e2wtypes.Store,
+	encryptor e2wtypes.Encryptor,
+	opts ...Option,
+) (
+	e2wtypes.Wallet,
+	error,
+) {
 	// First, try to access the wallet to ensure there's nothing there.
 	if _, err := store.RetrieveWallet(name); err == nil {
-		return nil, fmt.Errorf("wallet %q already exists", name)
+		return nil, newAlreadyExistsError("wallet", name, "wallet %q already exists", name)
 	}
 
-	w, err := newWallet()
+	w, err := newWallet(opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -153,13 +190,21 @@ func CreateWallet(_ context.Context, name string, store e2wtypes.Store, encrypto
 }
 
 // OpenWallet opens an existing wallet with the given name.
-func OpenWallet(ctx context.Context, name string, store e2wtypes.Store, encryptor e2wtypes.Encryptor) (e2wtypes.Wallet, error) {
+func OpenWallet(ctx context.Context,
+	name string,
+	store e2wtypes.Store,
+	encryptor e2wtypes.Encryptor,
+	opts ...Option,
+) (
+	e2wtypes.Wallet,
+	error,
+) {
 	data, err := store.RetrieveWallet(name)
 	if err != nil {
-		return nil, errors.Wrapf(err, "wallet %q does not exist", name)
+		return nil, newNotFoundError("wallet", name, err, "wallet %q does not exist", name)
 	}
 
-	return DeserializeWallet(ctx, data, store, encryptor)
+	return DeserializeWallet(ctx, data, store, encryptor, opts...)
 }
 
 // DeserializeWallet deserializes a wallet from its byte-level representation.
@@ -167,21 +212,25 @@ func DeserializeWallet(ctx context.Context,
 	data []byte,
 	store e2wtypes.Store,
 	encryptor e2wtypes.Encryptor,
+	opts ...Option,
 ) (
 	e2wtypes.Wallet,
 	error,
 ) {
-	wallet, err := newWallet()
+	wallet, err := newWallet(opts...)
 	if err != nil {
 		return nil, err
 	}
 	if err := json.Unmarshal(data, wallet); err != nil {
-		return nil, errors.Wrap(err, "wallet corrupt")
+		return nil, newCorruptDataError(err, "wallet corrupt")
+	}
+	if wallet.incomplete {
+		return nil, newCorruptDataError(nil, fmt.Sprintf("wallet %q is incomplete; import it again or delete it from the store", wallet.name))
 	}
 	wallet.store = store
 	wallet.encryptor = encryptor
 	if err := wallet.retrieveAccountsIndex(ctx); err != nil {
-		return nil, errors.Wrap(err, "wallet index corrupt")
+		return nil, newCorruptDataError(err, "wallet index corrupt")
 	}
 
 	return wallet, nil
@@ -243,6 +292,7 @@ func (w *wallet) storeWallet() error {
 }
 
 // ImportDistributedAccount creates a new distributed account in the wallet from provided data.
+// If the private key is empty the account is watch-only, and the passphrase is not used.
 // The only rule for names is that they cannot start with an underscore (_) character.
 // This will error if an account with the name already exists.
 func (w *wallet) ImportDistributedAccount(ctx context.Context,
@@ -256,70 +306,57 @@ func (w *wallet) ImportDistributedAccount(ctx context.Context,
 	e2wtypes.Account,
 	error,
 ) {
-	if name == "" {
-		return nil, errors.New("account name missing")
-	}
-	if strings.HasPrefix(name, "_") {
-		return nil, fmt.Errorf("invalid account name %q", name)
-	}
-	if len(privatekey) == 0 {
-		return nil, errors.New("private key missing")
-	}
-	if len(verificationVector) == 0 {
-		return nil, errors.New("verification vector missing")
-	}
-	if len(participants) == 0 {
-		return nil, errors.New("participants missing")
-	}
-	if signingThreshold <= uint32(len(participants)/2) {
-		return nil, errors.New("invalid signing threshold:participant ratio")
-	}
-	if uint32(len(verificationVector)) != signingThreshold {
-		return nil, errors.New("verification vector invalid")
+	return w.ImportDistributedAccountWithParticipants(ctx,
+		name,
+		privatekey,
+		signingThreshold,
+		verificationVector,
+		endpointParticipants(participants),
+		passphrase)
+}
+
+// ImportDistributedAccountWithParticipants creates a new distributed account in the wallet from provided data,
+// with full descriptors of its participants.
+// If the private key is empty the account is watch-only, and the passphrase is not used.
+// The only rule for names is that they cannot start with an underscore (_) character.
+// This will error if an account with the name already exists.
+func (w *wallet) ImportDistributedAccountWithParticipants(ctx context.Context,
+	name string,
+	privatekey []byte,
+	signingThreshold uint32,
+	verificationVector [][]byte,
+	participants map[uint64]*Participant,
+	passphrase []byte,
+) (
+	e2wtypes.Account,
+	error,
+) {
+	if err := w.validateImport(name, privatekey, signingThreshold, verificationVector, participants); err != nil {
+		return nil, err
 	}
 	unlocked, err := w.IsUnlocked(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to obtain wallet lock status")
 	}
 	if !unlocked {
-		return nil, errors.New("wallet must be unlocked to create accounts")
+		return nil, newLockedError("wallet", w.name, "wallet must be unlocked to create accounts")
 	}
 
 	// Ensure that we don't already have an account with this name.
 	if _, err := w.AccountByName(ctx, name); err == nil {
-		return nil, fmt.Errorf("account with name %q already exists", name)
+		return nil, newAlreadyExistsError("account", name, "account with name %q already exists", name)
 	}
 
-	a, err := newAccount()
+	a, secret, err := w.newImportedAccount(name, privatekey, signingThreshold, verificationVector, participants)
 	if err != nil {
 		return nil, err
 	}
-	a.name = name
-	privateKey, err := e2types.BLSPrivateKeyFromBytes(privatekey)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to obtain BLS private key")
-	}
-	a.publicKey = privateKey.PublicKey()
-	// Encrypt the private key.
-	a.signingThreshold = signingThreshold
-	a.verificationVector = make([]e2types.PublicKey, len(verificationVector))
-	for i := range verificationVector {
-		a.verificationVector[i], err = e2types.BLSPublicKeyFromBytes(verificationVector[i])
+	if !a.watchOnly {
+		a.crypto, err = w.encryptSecret(ctx, secret, string(passphrase))
 		if err != nil {
-			return nil, errors.Wrapf(err, "failed to obtain BLS public key for verification vector %d", i)
+			return nil, errors.Wrap(err, "failed to encrypt private key")
 		}
 	}
-	a.participants = make(map[uint64]string, len(participants))
-	for k, v := range participants {
-		a.participants[k] = v
-	}
-	a.crypto, err = w.encryptor.Encrypt(privateKey.Marshal(), string(passphrase))
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to encrypt private key")
-	}
-	a.encryptor = w.encryptor
-	a.version = w.encryptor.Version()
-	a.wallet = w
 
 	// Have to update the index first so that storeAccount() stores the
 	// index with the new account present, but be ready to revert if it fails.
@@ -332,114 +369,128 @@ func (w *wallet) ImportDistributedAccount(ctx context.Context,
 	}
 	w.accounts[a.id] = a
 	w.mutex.Unlock()
+	w.addAccountKeys(a)
 
 	return a, nil
 }
 
-func (w *wallet) retrieveBatchIfRequired(ctx context.Context) error {
-	var err error
-
-	if w.batch == nil {
-		// Batch not retrieved, try to retrieve it now.
-		if _, isBatchRetriever := w.store.(e2wtypes.BatchRetriever); isBatchRetriever {
-			err = w.retrieveAccountsBatch(ctx)
-		}
+// validateImport validates the data for a distributed account to be imported.
+func (w *wallet) validateImport(name string,
+	privatekey []byte,
+	signingThreshold uint32,
+	verificationVector [][]byte,
+	participants map[uint64]*Participant,
+) error {
+	if name == "" {
+		return errors.New("account name missing")
+	}
+	if strings.HasPrefix(name, "_") {
+		return fmt.Errorf("invalid account name %q", name)
+	}
+	if len(verificationVector) == 0 && participantSharePublicKeys(participants) == nil {
+		return errors.New("verification vector missing")
+	}
+	if err := w.thresholdPolicy.validateParticipants(signingThreshold, participants); err != nil {
+		return err
+	}
+	if len(verificationVector) > 0 && uint32(len(verificationVector)) != signingThreshold {
+		return errors.New("verification vector invalid")
 	}
 
-	return err
+	return nil
 }
 
-// Accounts provides all accounts in the wallet.
-func (w *wallet) Accounts(ctx context.Context) <-chan e2wtypes.Account {
-	ch := make(chan e2wtypes.Account, 1024)
-
-	go func(ch chan e2wtypes.Account) {
-		_ = w.retrieveBatchIfRequired(ctx)
-
-		if w.batch != nil && len(w.batch.entries) > 0 {
-			// Batch present, use pre-loaded accounts.
-			for _, account := range w.accounts {
-				ch <- account
-			}
-			close(ch)
-
-			return
+// newImportedAccount creates a distributed account for the wallet from validated data, returning
+// the account along with its secret key.  The secret key is not yet encrypted.
+func (w *wallet) newImportedAccount(name string,
+	privatekey []byte,
+	signingThreshold uint32,
+	verificationVector [][]byte,
+	participants map[uint64]*Participant,
+) (
+	*account,
+	[]byte,
+	error,
+) {
+	a, err := newAccount()
+	if err != nil {
+		return nil, nil, err
+	}
+	a.name = name
+	a.signingThreshold = signingThreshold
+	if len(verificationVector) == 0 {
+		// Account is described by the share public keys of its participants.
+		a.verificationVector, err = verificationVectorFromParticipants(signingThreshold, participants)
+		if err != nil {
+			return nil, nil, err
 		}
-
-		// No batch; fall back to individual accounts on the store.
-		for data := range w.store.RetrieveAccounts(w.ID()) {
-			if account, err := deserializeAccount(w, data); err == nil {
-				ch <- account
+		a.publicShares = true
+	} else {
+		a.verificationVector = make([]e2types.PublicKey, len(verificationVector))
+		for i := range verificationVector {
+			a.verificationVector[i], err = e2types.BLSPublicKeyFromBytes(verificationVector[i])
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "failed to obtain BLS public key for verification vector %d", i)
 			}
 		}
-		close(ch)
-	}(ch)
-
-	return ch
-}
-
-// Export exports the entire wallet, protected by an additional passphrase.
-func (w *wallet) Export(ctx context.Context, passphrase []byte) ([]byte, error) {
-	w.mutex.Lock()
-	defer w.mutex.Unlock()
-
-	type walletExt struct {
-		Wallet   *wallet    `json:"wallet"`
-		Accounts []*account `json:"accounts"`
 	}
+	a.participants = copyParticipants(participants)
+	a.wallet = w
 
-	accounts := make([]*account, 0)
-	for data := range w.store.RetrieveAccounts(w.ID()) {
-		account, err := deserializeAccount(w, data)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to deserialize account")
-		}
-		accounts = append(accounts, account)
-	}
+	if len(privatekey) == 0 {
+		// Watch-only account, identified by its composite public key.
+		a.watchOnly = true
+		a.publicKey = a.verificationVector[0]
 
-	ext := &walletExt{
-		Wallet:   w,
-		Accounts: accounts,
+		return a, nil, nil
 	}
 
-	data, err := json.Marshal(ext)
+	privateKey, err := e2types.BLSPrivateKeyFromBytes(privatekey)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to marshal wallet for export")
+		return nil, nil, errors.Wrap(err, "failed to obtain BLS private key")
+	}
+	a.publicKey = privateKey.PublicKey()
+	a.encryptor = w.encryptor
+	a.keyWrapper = w.keyWrapper
+	if a.keyWrapper == nil {
+		a.version = w.encryptor.Version()
 	}
 
-	res, err := ecodec.Encrypt(data, passphrase)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to encrypt export")
+	return a, privateKey.Marshal(), nil
+}
+
+// retrieveBatchIfRequired retrieves the batch if it has not yet been retrieved,
+// returning the error from the most recent retrieval.
+func (w *wallet) retrieveBatchIfRequired(ctx context.Context) error {
+	if _, isBatchRetriever := w.store.(e2wtypes.BatchRetriever); !isBatchRetriever {
+		return nil
 	}
 
-	return res, nil
+	return w.retrieveAccountsBatch(ctx)
 }
 
-// Import imports the entire wallet, protected by an additional passphrase.
+// Import imports a wallet, protected by an additional passphrase.
+// If the export contains a batch it is also imported.
 func Import(ctx context.Context,
 	encryptedData []byte,
 	passphrase []byte,
 	store e2wtypes.Store,
 	encryptor e2wtypes.Encryptor,
+	opts ...Option,
 ) (
 	e2wtypes.Wallet,
 	error,
 ) {
-	type walletExt struct {
-		Wallet   *wallet    `json:"wallet"`
-		Accounts []*account `json:"accounts"`
-	}
-
 	data, err := ecodec.Decrypt(encryptedData, passphrase)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to decrypt wallet")
+		return nil, exportDecryptError(encryptedData, err)
 	}
 
-	wallet, err := newWallet()
+	wallet, err := newWallet(opts...)
 	if err != nil {
 		return nil, err
 	}
-	ext := &walletExt{
+	ext := &walletExport{
 		Wallet: wallet,
 	}
 	ext.Wallet.store = store
@@ -448,39 +499,124 @@ func Import(ctx context.Context,
 		return nil, errors.Wrap(err, "failed to unmarshal wallet")
 	}
 
-	// See if the wallet already exists.
-	if _, err := OpenWallet(ctx, ext.Wallet.Name(), store, encryptor); err == nil {
-		return nil, fmt.Errorf("wallet %q already exists", ext.Wallet.Name())
-	}
-
-	// Store the wallet.
-	if err := ext.Wallet.storeWallet(); err != nil {
-		return nil, errors.Wrapf(err, "failed to store wallet %q", ext.Wallet.Name())
+	// Validate the wallet and its accounts before storing anything.
+	if ext.Wallet.name == "" {
+		return nil, errors.New("wallet name missing")
 	}
-
-	// Create the accounts.
-	for _, acc := range ext.Accounts {
-		acc.wallet = ext.Wallet
+	accounts := make([]*account, 0, len(ext.Accounts))
+	for i, accountData := range ext.Accounts {
+		acc, err := deserializeAccount(ext.Wallet, accountData)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid account %d", i)
+		}
 		acc.encryptor = encryptor
+		if acc.name == "" || strings.HasPrefix(acc.name, "_") {
+			return nil, fmt.Errorf("invalid account name %q", acc.name)
+		}
+		if ext.Wallet.index.NameKnown(acc.name) {
+			return nil, fmt.Errorf("duplicate account name %q", acc.name)
+		}
+		if ext.Wallet.index.IDKnown(acc.id) {
+			return nil, fmt.Errorf("duplicate account ID %s", acc.id)
+		}
 		ext.Wallet.index.Add(acc.id, acc.name)
-		if err := acc.storeAccount(ctx); err != nil {
-			return nil, errors.Wrapf(err, "failed to store account %q", acc.Name())
+		accounts = append(accounts, acc)
+	}
+	if len(ext.Batch) > 0 {
+		if err := ext.Wallet.validateBatch(ext.Batch); err != nil {
+			return nil, errors.Wrap(err, "invalid batch")
+		}
+	}
+
+	// See if the wallet already exists.  A wallet left incomplete by a failed
+	// import of the same wallet can be replaced.
+	if existing, err := store.RetrieveWallet(ext.Wallet.Name()); err == nil {
+		if !isIncompleteWallet(existing, ext.Wallet.ID()) {
+			return nil, newAlreadyExistsError("wallet", ext.Wallet.Name(), "wallet %q already exists", ext.Wallet.Name())
 		}
 	}
 
-	if err := ext.Wallet.storeAccountsIndex(); err != nil {
-		return nil, errors.Wrap(err, "failed to store wallet index")
+	if err := ext.Wallet.storeImport(ctx, accounts, ext.Batch); err != nil {
+		if !ext.Wallet.rollbackImport(accounts) {
+			return nil, errors.Wrapf(err, "incomplete wallet %q left in store; import it again or delete it from the store", ext.Wallet.Name())
+		}
+
+		return nil, err
 	}
 
 	return ext.Wallet, nil
 }
 
+// storeImport stores an imported wallet along with its accounts and batch.
+// The wallet is marked as incomplete until all of its data has been stored.
+func (w *wallet) storeImport(ctx context.Context, accounts []*account, batch []byte) error {
+	w.incomplete = true
+	if err := w.storeWallet(); err != nil {
+		return errors.Wrapf(err, "failed to store wallet %q", w.Name())
+	}
+
+	for _, acc := range accounts {
+		data, err := json.Marshal(acc)
+		if err != nil {
+			return errors.Wrapf(err, "failed to marshal account %q", acc.Name())
+		}
+		if err := w.store.StoreAccount(w.id, acc.id, data); err != nil {
+			return errors.Wrapf(err, "failed to store account %q", acc.Name())
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := w.storeBatch(ctx, batch); err != nil {
+			return errors.Wrap(err, "failed to import batch")
+		}
+	}
+
+	w.incomplete = false
+	if err := w.storeWallet(); err != nil {
+		return errors.Wrapf(err, "failed to store wallet %q", w.Name())
+	}
+
+	return nil
+}
+
+// rollbackImport removes as much of a failed import as the store allows.
+// Anything that cannot be removed is left marked as incomplete.
+// It returns false if the wallet itself could not be removed, in which case the
+// wallet cannot be opened until it is imported again or deleted from the store.
+func (w *wallet) rollbackImport(accounts []*account) bool {
+	if accountRemover, isAccountRemover := w.store.(AccountRemover); isAccountRemover {
+		for _, acc := range accounts {
+			_ = accountRemover.RemoveAccount(w.id, acc.id)
+		}
+	}
+	walletRemover, isWalletRemover := w.store.(WalletRemover)
+	if !isWalletRemover {
+		return false
+	}
+
+	return walletRemover.RemoveWallet(w.id) == nil
+}
+
+// isIncompleteWallet returns true if the data is for the wallet with the given
+// ID and the wallet is marked as incomplete.
+func isIncompleteWallet(data []byte, id uuid.UUID) bool {
+	w, err := newWallet()
+	if err != nil {
+		return false
+	}
+	if err := json.Unmarshal(data, w); err != nil {
+		return false
+	}
+
+	return w.incomplete && w.id == id
+}
+
 // AccountByName provides a single account from the wallet given its name.
 // This will error if the account is not found.
 func (w *wallet) AccountByName(ctx context.Context, name string) (e2wtypes.Account, error) {
 	id, exists := w.index.ID(name)
 	if !exists {
-		return nil, fmt.Errorf("no account with name %q", name)
+		return nil, newNotFoundError("account", name, nil, "no account with name %q", name)
 	}
 
 	return w.AccountByID(ctx, id)
@@ -501,7 +637,7 @@ func (w *wallet) AccountByID(ctx context.Context, id uuid.UUID) (e2wtypes.Accoun
 	// No batch or account not in batch; fall back to individual account on the store.
 	data, err := w.store.RetrieveAccount(w.id, id)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to retrieve account")
+		return nil, newNotFoundError("account", id.String(), err, "failed to retrieve account")
 	}
 	res, err := deserializeAccount(w, data)
 	if err != nil {
@@ -522,10 +658,13 @@ func (w *wallet) retrieveAccountsIndex(ctx context.Context) error {
 	serializedIndex, err := w.store.RetrieveAccountsIndex(w.id)
 	if err != nil {
 		// Attempt to recreate the index.
-		w.index = indexer.New()
+		// The index is unset while it is recreated, so that batch entries are not filtered by it.
+		w.index = nil
+		index := indexer.New()
 		for account := range w.Accounts(ctx) {
-			w.index.Add(account.ID(), account.Name())
+			index.Add(account.ID(), account.Name())
 		}
+		w.index = index
 		if err := w.storeAccountsIndex(); err != nil {
 			return err
 		}
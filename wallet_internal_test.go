@@ -96,6 +96,11 @@ func TestUnmarshalWallet(t *testing.T) {
 			input: []byte(`{"uuid":"c9958061-63d4-4a80-bcf3-25f3dda22340","name":"Bad","type":"distributed","version":"1"}`),
 			err:   errors.New("wallet version invalid"),
 		},
+		{
+			name:  "WrongIncomplete",
+			input: []byte(`{"uuid":"c9958061-63d4-4a80-bcf3-25f3dda22340","name":"Bad","type":"distributed","version":1,"incomplete":"true"}`),
+			err:   errors.New("wallet incomplete flag invalid"),
+		},
 		{
 			name:       "Good",
 			input:      []byte(`{"uuid":"c9958061-63d4-4a80-bcf3-25f3dda22340","name":"Good","type":"distributed","version":1}`),
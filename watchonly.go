@@ -0,0 +1,31 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed
+
+// AccountWatchOnlyProvider is the interface for accounts that can state if they are watch-only.
+type AccountWatchOnlyProvider interface {
+	// WatchOnly returns true if the account does not hold a share of the secret key.
+	WatchOnly() bool
+}
+
+// WatchOnly returns true if the account does not hold a share of the secret key.
+// Watch-only accounts are imported without a private key.  They hold the signing threshold,
+// participants and verification vector of the account, so can verify and combine partial
+// signatures, but cannot be unlocked or sign.  Their public key is the composite public key.
+func (a *account) WatchOnly() bool {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	return a.watchOnly
+}
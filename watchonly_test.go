@@ -0,0 +1,130 @@
+// Copyright 2023 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributed_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	distributed "github.com/wealdtech/go-eth2-wallet-distributed"
+	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
+	scratch "github.com/wealdtech/go-eth2-wallet-store-scratch"
+	e2wtypes "github.com/wealdtech/go-eth2-wallet-types/v2"
+)
+
+func TestWatchOnly(t *testing.T) {
+	ctx := context.Background()
+	store := scratch.New()
+	encryptor := keystorev4.New()
+	wallet, err := distributed.CreateWallet(ctx, "test wallet", store, encryptor)
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+
+	vvec, shares := generateShares(t, 2, 1, 2, 3)
+	endpoints := map[uint64]string{1: "signer1:443", 2: "signer2:443", 3: "signer3:443"}
+	account, err := wallet.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+		"Account 1", nil, 2, vvec, endpoints, nil)
+	require.NoError(t, err)
+	require.True(t, account.(distributed.AccountWatchOnlyProvider).WatchOnly())
+	require.Equal(t, vvec[0], account.PublicKey().Marshal())
+	require.Equal(t, vvec[0], account.(e2wtypes.AccountCompositePublicKeyProvider).CompositePublicKey().Marshal())
+
+	// The stored account has no crypto.
+	data, err := store.RetrieveAccount(wallet.ID(), account.ID())
+	require.NoError(t, err)
+	stored := make(map[string]any)
+	require.NoError(t, json.Unmarshal(data, &stored))
+	require.NotContains(t, stored, "crypto")
+	require.Equal(t, true, stored["watch_only"])
+
+	// The account cannot be unlocked or sign.
+	err = account.(e2wtypes.AccountLocker).Unlock(ctx, nil)
+	require.ErrorIs(t, err, distributed.ErrWatchOnly)
+	require.EqualError(t, err, "watch-only account cannot be unlocked")
+	_, err = account.(e2wtypes.AccountSigner).Sign(ctx, []byte("data"))
+	require.ErrorIs(t, err, distributed.ErrWatchOnly)
+	_, err = account.(e2wtypes.AccountPrivateKeyProvider).PrivateKey(ctx)
+	require.ErrorIs(t, err, distributed.ErrWatchOnly)
+	_, err = account.(distributed.AccountParticipantIDProvider).ParticipantID()
+	require.ErrorIs(t, err, distributed.ErrWatchOnly)
+	err = account.(distributed.AccountPassphraseChanger).ChangePassphrase(ctx, nil, []byte("new"))
+	require.ErrorIs(t, err, distributed.ErrWatchOnly)
+
+	// Partial signatures can be verified and combined.
+	message := []byte("data to sign")
+	partials := make(map[uint64]e2types.Signature)
+	for participantID, share := range shares {
+		key, err := e2types.BLSPrivateKeyFromBytes(share)
+		require.NoError(t, err)
+		partials[participantID] = key.Sign(message)
+		require.NoError(t, account.(distributed.AccountPartialSignatureVerifier).VerifyPartialSignature(participantID, message, partials[participantID]))
+	}
+	signature, err := account.(distributed.AccountPartialSignatureCombiner).CombinePartialSignatures(message,
+		map[uint64]e2types.Signature{1: partials[1], 3: partials[3]})
+	require.NoError(t, err)
+	require.True(t, signature.Verify(message, account.PublicKey()))
+
+	// Watch-only accounts are batched alongside accounts with secret keys.
+	_, err = wallet.(e2wtypes.WalletDistributedAccountImporter).ImportDistributedAccount(ctx,
+		"Account 2", shares[2], 2, vvec, endpoints, []byte("pass"))
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletBatchCreator).BatchWallet(ctx, []string{"pass"}, "batch"))
+	wallet, err = distributed.OpenWallet(ctx, "test wallet", store, encryptor)
+	require.NoError(t, err)
+	obtained, err := wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 1")
+	require.NoError(t, err)
+	require.True(t, obtained.(distributed.AccountWatchOnlyProvider).WatchOnly())
+	require.ErrorIs(t, obtained.(e2wtypes.AccountLocker).Unlock(ctx, []byte("batch")), distributed.ErrWatchOnly)
+	obtained, err = wallet.(e2wtypes.WalletAccountByNameProvider).AccountByName(ctx, "Account 2")
+	require.NoError(t, err)
+	require.NoError(t, obtained.(e2wtypes.AccountLocker).Unlock(ctx, []byte("batch")))
+	_, err = obtained.(e2wtypes.AccountSigner).Sign(ctx, message)
+	require.NoError(t, err)
+
+	report, err := wallet.(distributed.WalletVerifier).Verify(ctx,
+		distributed.WithVerifyPassphrases([]byte("pass"), []byte("batch")))
+	require.NoError(t, err)
+	require.Empty(t, report.Problems)
+}
+
+func TestWatchOnlyBatchRefresh(t *testing.T) {
+	ctx := context.Background()
+	store := scratch.New()
+	encryptor := keystorev4.New()
+	wallet, err := distributed.CreateWallet(ctx, "test wallet", store, encryptor)
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletLocker).Unlock(ctx, nil))
+
+	vvec, shares := generateShares(t, 2, 1, 2, 3)
+	endpoints := map[uint64]string{1: "signer1:443", 2: "signer2:443", 3: "signer3:443"}
+	importer := wallet.(e2wtypes.WalletDistributedAccountImporter)
+	_, err = importer.ImportDistributedAccount(ctx, "Account 1", shares[2], 2, vvec, endpoints, []byte("pass"))
+	require.NoError(t, err)
+	require.NoError(t, wallet.(e2wtypes.WalletBatchCreator).BatchWallet(ctx, []string{"pass"}, "batch"))
+
+	// Watch-only account added after the batch was created.
+	_, err = importer.ImportDistributedAccount(ctx, "Account 2", nil, 2, vvec, endpoints, nil)
+	require.NoError(t, err)
+
+	// Refreshing the batch only replaces the accounts from the batch.
+	require.NoError(t, wallet.(distributed.WalletBatchRefresher).RefreshBatch(ctx))
+	names := make([]string, 0)
+	for account := range wallet.Accounts(ctx) {
+		names = append(names, account.Name())
+	}
+	require.ElementsMatch(t, []string{"Account 1", "Account 2"}, names)
+}